@@ -0,0 +1,176 @@
+package conformance
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/neet-007/glox/pkg/utils"
+)
+
+// Outcome is what running a script did, as glox run with the default
+// plain diagnostics writes it.
+type Outcome struct {
+	Stdout   string
+	Stderr   string
+	ExitCode int
+}
+
+// Executor runs a script the way glox does and captures what it wrote.
+type Executor func(file string, source []byte) Outcome
+
+// Check compares what a script did with what its annotations expect and
+// returns the differences, none when it conforms.
+func Check(expect Expectations, outcome Outcome) []string {
+	differences := []string{}
+
+	differences = append(differences, diffOrdered("stdout", expect.Output, lines(outcome.Stdout))...)
+
+	// the indented notes under an error, like its stack trace, are not
+	// part of the expectations
+	reported := []string{}
+	for _, line := range lines(outcome.Stderr) {
+		if !strings.HasPrefix(line, "    ") {
+			reported = append(reported, line)
+		}
+	}
+
+	if expect.RuntimeError != "" {
+		differences = append(differences, diffRuntimeError(expect.RuntimeError, expect.RuntimeErrorLine, reported)...)
+	} else {
+		// compile errors can come in any order
+		differences = append(differences, diffUnordered("stderr", expect.Errors, reported)...)
+	}
+
+	if expect.ExitCode != outcome.ExitCode {
+		differences = append(differences, fmt.Sprintf("exit code: expected %d, got %d", expect.ExitCode, outcome.ExitCode))
+	}
+
+	return differences
+}
+
+func lines(output string) []string {
+	if output == "" {
+		return []string{}
+	}
+	return strings.Split(strings.TrimSuffix(output, "\n"), "\n")
+}
+
+// diffRuntimeError checks that the one error reported is the runtime error
+// expected, "[line N] Error at 'x': message" with the token left open as
+// the annotations do not give it.
+func diffRuntimeError(message string, line int, got []string) []string {
+	if len(got) == 0 {
+		return []string{fmt.Sprintf("stderr: missing runtime error %q at line %d", message, line)}
+	}
+
+	differences := []string{}
+	prefix := fmt.Sprintf("[line %d] Error", line)
+	if !strings.HasPrefix(got[0], prefix) || !strings.HasSuffix(got[0], ": "+message) {
+		differences = append(differences, fmt.Sprintf("stderr line 1: expected runtime error %q at line %d, got %q", message, line, got[0]))
+	}
+	for i, extra := range got[1:] {
+		differences = append(differences, fmt.Sprintf("stderr line %d: unexpected %q", i+2, extra))
+	}
+	return differences
+}
+
+func diffOrdered(stream string, expected []string, got []string) []string {
+	differences := []string{}
+	for i := 0; i < max(len(expected), len(got)); i++ {
+		switch {
+		case i >= len(got):
+			differences = append(differences, fmt.Sprintf("%s line %d: missing %q", stream, i+1, expected[i]))
+		case i >= len(expected):
+			differences = append(differences, fmt.Sprintf("%s line %d: unexpected %q", stream, i+1, got[i]))
+		case expected[i] != got[i]:
+			differences = append(differences, fmt.Sprintf("%s line %d: expected %q, got %q", stream, i+1, expected[i], got[i]))
+		}
+	}
+	return differences
+}
+
+func diffUnordered(stream string, expected []string, got []string) []string {
+	missing := map[string]int{}
+	for _, line := range expected {
+		missing[line]++
+	}
+
+	differences := []string{}
+	for _, line := range got {
+		if missing[line] > 0 {
+			missing[line]--
+			continue
+		}
+		differences = append(differences, fmt.Sprintf("%s: unexpected %q", stream, line))
+	}
+	for _, line := range expected {
+		if missing[line] > 0 {
+			missing[line]--
+			differences = append(differences, fmt.Sprintf("%s: missing %q", stream, line))
+		}
+	}
+	return differences
+}
+
+// Result is how one test file went.
+type Result struct {
+	File string `json:"file"`
+	// Skipped is set for files that are not tests.
+	Skipped     bool          `json:"skipped"`
+	Differences []string      `json:"differences"`
+	Duration    time.Duration `json:"-"`
+}
+
+func (r Result) Passed() bool {
+	return len(r.Differences) == 0
+}
+
+// Runner runs the test files of a Crafting Interpreters style suite.
+type Runner struct {
+	// Execute runs each test.
+	Execute Executor
+}
+
+// RunFile runs one test file and checks it against its annotations.
+func (r *Runner) RunFile(file string) (result Result) {
+	start := time.Now()
+	result.File = file
+	defer func() {
+		result.Duration = time.Since(start)
+	}()
+
+	source, err := os.ReadFile(file)
+	if err != nil {
+		result.Differences = []string{err.Error()}
+		return result
+	}
+
+	expect, err := ParseExpectations(source)
+	if err != nil {
+		result.Differences = []string{err.Error()}
+		return result
+	}
+	if expect.Skip {
+		result.Skipped = true
+		return result
+	}
+
+	result.Differences = Check(expect, r.Execute(file, source))
+	return result
+}
+
+// Run runs every .lox file under paths.
+func (r *Runner) Run(paths []string) ([]Result, error) {
+	files, err := utils.LoxFiles(paths)
+	if err != nil {
+		return nil, err
+	}
+
+	results := []Result{}
+	for _, file := range files {
+		results = append(results, r.RunFile(file))
+	}
+	return results, nil
+}
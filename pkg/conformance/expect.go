@@ -0,0 +1,89 @@
+package conformance
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"regexp"
+)
+
+// The annotations of the Crafting Interpreters test suite, as read by its
+// test.py for the java implementation.
+var (
+	expectedOutput       = regexp.MustCompile(`// expect: ?(.*)`)
+	expectedError        = regexp.MustCompile(`// (Error.*)`)
+	expectedErrorAtLine  = regexp.MustCompile(`// \[((java|c) )?line (\d+)\] (Error.*)`)
+	expectedRuntimeError = regexp.MustCompile(`// expect runtime error: (.+)`)
+	nonTest              = regexp.MustCompile(`// nontest`)
+)
+
+// Expectations is what a test file says running it does.
+type Expectations struct {
+	// Output is the lines printed, in order.
+	Output []string
+	// Errors are the compile errors reported, as "[line N] Error ...".
+	Errors []string
+	// RuntimeError is the message of the runtime error that ends the
+	// script, raised at RuntimeErrorLine.
+	RuntimeError     string
+	RuntimeErrorLine int
+	// ExitCode is 65 with compile errors, 70 with a runtime error and 0
+	// otherwise.
+	ExitCode int
+	// Skip is set for files marked "// nontest", which are not tests.
+	Skip bool
+}
+
+// ParseExpectations reads the annotations of a test file.
+func ParseExpectations(source []byte) (Expectations, error) {
+	expect := Expectations{Output: []string{}, Errors: []string{}}
+
+	lines := bufio.NewScanner(bytes.NewReader(source))
+	lines.Buffer(nil, len(source)+1)
+	for line := 1; lines.Scan(); line++ {
+		text := lines.Text()
+
+		if nonTest.MatchString(text) {
+			expect.Skip = true
+			return expect, nil
+		}
+
+		if match := expectedOutput.FindStringSubmatch(text); match != nil {
+			expect.Output = append(expect.Output, match[1])
+			continue
+		}
+
+		if match := expectedErrorAtLine.FindStringSubmatch(text); match != nil {
+			// the errors of the c implementation are not ours
+			if match[2] != "c" {
+				expect.Errors = append(expect.Errors, fmt.Sprintf("[line %s] %s", match[3], match[4]))
+				expect.ExitCode = 65
+			}
+			continue
+		}
+
+		if match := expectedError.FindStringSubmatch(text); match != nil {
+			expect.Errors = append(expect.Errors, fmt.Sprintf("[line %d] %s", line, match[1]))
+			expect.ExitCode = 65
+			continue
+		}
+
+		if match := expectedRuntimeError.FindStringSubmatch(text); match != nil {
+			if expect.RuntimeError != "" {
+				return expect, fmt.Errorf("line %d: a test can only expect one runtime error", line)
+			}
+			expect.RuntimeError = match[1]
+			expect.RuntimeErrorLine = line
+			expect.ExitCode = 70
+		}
+	}
+
+	if err := lines.Err(); err != nil {
+		return expect, err
+	}
+	if expect.RuntimeError != "" && len(expect.Errors) > 0 {
+		return expect, fmt.Errorf("a test cannot expect both compile errors and a runtime error")
+	}
+
+	return expect, nil
+}
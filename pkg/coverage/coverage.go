@@ -0,0 +1,209 @@
+package coverage
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"text/tabwriter"
+
+	"github.com/neet-007/glox/pkg/parser"
+	"github.com/neet-007/glox/pkg/scanner"
+	"github.com/neet-007/glox/pkg/trace"
+)
+
+// Coverage is a trace.Tracer that counts how often each statement of a
+// script ran and which way each if, while, and and or went.
+type Coverage struct {
+	file       string
+	statements map[position]int
+	branches   []*branchPoint
+	branchAt   map[position]*branchPoint
+}
+
+type position struct {
+	line   int
+	column int
+}
+
+// branchPoint is an if, a while, an and or an or. taken counts the two
+// ways it can go, see trace.Branch.
+type branchPoint struct {
+	position
+	taken [2]int
+}
+
+// New makes the coverage of the script file, whose statements are stmts.
+func New(file string, stmts []parser.Stmt) *Coverage {
+	c := &Coverage{
+		file:       file,
+		statements: map[position]int{},
+		branchAt:   map[position]*branchPoint{},
+	}
+
+	var visit func(node any) bool
+	visit = func(node any) bool {
+		switch node := node.(type) {
+		case parser.Block:
+			// only a scope, the statements in it are what runs
+		case parser.Class:
+			// methods are not statements, only their bodies run
+			c.addStatement(node)
+			for _, method := range node.Methods {
+				parser.Inspect(method.Body, visit)
+			}
+			return false
+		case parser.IfStmt:
+			c.addStatement(node)
+			c.addBranch(node.Keyword)
+		case parser.WhileStmt:
+			c.addStatement(node)
+			c.addBranch(node.Keyword)
+		case parser.Logical:
+			c.addBranch(node.Operator)
+		case parser.Stmt:
+			c.addStatement(node)
+		}
+		return true
+	}
+	parser.Inspect(stmts, visit)
+
+	return c
+}
+
+func at(token scanner.Token) position {
+	return position{line: token.Line, column: token.Column}
+}
+
+func (c *Coverage) addStatement(stmt parser.Stmt) {
+	c.statements[at(parser.StmtToken(stmt))] = 0
+}
+
+func (c *Coverage) addBranch(token scanner.Token) {
+	point := &branchPoint{position: at(token)}
+	c.branches = append(c.branches, point)
+	c.branchAt[point.position] = point
+}
+
+func (c *Coverage) Trace(event trace.Event) {
+	if event.File != c.file {
+		return
+	}
+	position := position{line: event.Line, column: event.Column}
+
+	switch event.Kind {
+	case trace.Statement:
+		if count, ok := c.statements[position]; ok {
+			c.statements[position] = count + 1
+		}
+	case trace.Branch:
+		branch, ok := event.Value.(int)
+		if point := c.branchAt[position]; point != nil && ok && branch >= 0 && branch < len(point.taken) {
+			point.taken[branch]++
+		}
+	}
+}
+
+// lines returns how often each line with a statement ran, the most any
+// statement starting on it ran, and the lines in order.
+func (c *Coverage) lines() (map[int]int, []int) {
+	hits := map[int]int{}
+	for position, count := range c.statements {
+		if previous, ok := hits[position.line]; !ok || count > previous {
+			hits[position.line] = count
+		}
+	}
+
+	lines := []int{}
+	for line := range hits {
+		lines = append(lines, line)
+	}
+	sort.Ints(lines)
+	return hits, lines
+}
+
+// Summary is how much of a script ran.
+type Summary struct {
+	File        string
+	Lines       int
+	LinesHit    int
+	Branches    int
+	BranchesHit int
+}
+
+func (c *Coverage) Summary() Summary {
+	summary := Summary{File: c.file}
+
+	hits, lines := c.lines()
+	summary.Lines = len(lines)
+	for _, line := range lines {
+		if hits[line] > 0 {
+			summary.LinesHit++
+		}
+	}
+
+	for _, point := range c.branches {
+		for _, taken := range point.taken {
+			summary.Branches++
+			if taken > 0 {
+				summary.BranchesHit++
+			}
+		}
+	}
+
+	return summary
+}
+
+// WriteLCOV writes the coverage as an LCOV tracefile, as read by genhtml
+// and most editors.
+func (c *Coverage) WriteLCOV(w io.Writer) error {
+	summary := c.Summary()
+	hits, lines := c.lines()
+
+	fmt.Fprintf(w, "TN:\nSF:%s\n", c.file)
+
+	points := append([]*branchPoint{}, c.branches...)
+	sort.SliceStable(points, func(i, j int) bool {
+		if points[i].line != points[j].line {
+			return points[i].line < points[j].line
+		}
+		return points[i].column < points[j].column
+	})
+	for block, point := range points {
+		reached := point.taken[0]+point.taken[1] > 0
+		for branch, taken := range point.taken {
+			count := "-"
+			if reached {
+				count = fmt.Sprint(taken)
+			}
+			fmt.Fprintf(w, "BRDA:%d,%d,%d,%s\n", point.line, block, branch, count)
+		}
+	}
+	fmt.Fprintf(w, "BRF:%d\nBRH:%d\n", summary.Branches, summary.BranchesHit)
+
+	for _, line := range lines {
+		fmt.Fprintf(w, "DA:%d,%d\n", line, hits[line])
+	}
+	_, err := fmt.Fprintf(w, "LF:%d\nLH:%d\nend_of_record\n", summary.Lines, summary.LinesHit)
+	return err
+}
+
+// WriteSummary writes a table of the lines and branches covered.
+func WriteSummary(w io.Writer, summaries ...Summary) error {
+	table := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(table, "file\tlines\t\tbranches")
+	for _, summary := range summaries {
+		fmt.Fprintf(table, "%s\t%d/%d\t%s\t%d/%d\t%s\n",
+			summary.File,
+			summary.LinesHit, summary.Lines, percent(summary.LinesHit, summary.Lines),
+			summary.BranchesHit, summary.Branches, percent(summary.BranchesHit, summary.Branches),
+		)
+	}
+	return table.Flush()
+}
+
+func percent(hit int, total int) string {
+	if total == 0 {
+		return "-"
+	}
+	return fmt.Sprintf("%.1f%%", 100*float64(hit)/float64(total))
+}
@@ -0,0 +1,113 @@
+package coverage_test
+
+import (
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/neet-007/glox/pkg/coverage"
+	"github.com/neet-007/glox/pkg/diagnostics"
+	"github.com/neet-007/glox/pkg/interpreter"
+	"github.com/neet-007/glox/pkg/parser"
+	"github.com/neet-007/glox/pkg/resolver"
+	"github.com/neet-007/glox/pkg/scanner"
+)
+
+// cover runs source and returns its coverage.
+func cover(t *testing.T, source string) *coverage.Coverage {
+	t.Helper()
+	tokens, errs := scanner.NewFileScanner("test.lox", []byte(source), false).Scan()
+	if len(errs) > 0 {
+		t.Fatal(errs[0])
+	}
+	stmts, errs := parser.NewParser(tokens, false).Parse()
+	if len(errs) > 0 {
+		t.Fatal(errs[0])
+	}
+
+	covered := coverage.New("test.lox", stmts)
+	interpreter_ := interpreter.NewInterpreter()
+	interpreter_.Stdout = io.Discard
+	interpreter_.Tracer = covered
+	for _, err := range resolver.NewResolver(interpreter_).Resolve(stmts) {
+		if err.Severity == diagnostics.Error {
+			t.Fatal(err)
+		}
+	}
+	if err := interpreter_.Interpret(stmts); err != nil {
+		t.Fatal(err)
+	}
+	return covered
+}
+
+func TestLCOV(t *testing.T) {
+	// the then branch of the if and the right of the and are never taken
+	covered := cover(t, `fun sign(n) {
+  if (n < 0) {
+    return -1;
+  }
+  return 1;
+}
+var i = 0;
+while (i < 3) {
+  print sign(i);
+  i = i + 1;
+}
+print i > 5 and sign(i);
+`)
+
+	var out strings.Builder
+	if err := covered.WriteLCOV(&out); err != nil {
+		t.Fatal(err)
+	}
+	want := `TN:
+SF:test.lox
+BRDA:2,0,0,0
+BRDA:2,0,1,3
+BRDA:8,1,0,3
+BRDA:8,1,1,1
+BRDA:12,2,0,0
+BRDA:12,2,1,1
+BRF:6
+BRH:4
+DA:1,1
+DA:2,3
+DA:3,0
+DA:5,3
+DA:7,1
+DA:8,1
+DA:9,3
+DA:10,3
+DA:12,1
+LF:9
+LH:8
+end_of_record
+`
+	if got := out.String(); got != want {
+		t.Errorf("got\n%s\nwant\n%s", got, want)
+	}
+
+	summary := covered.Summary()
+	if want := (coverage.Summary{File: "test.lox", Lines: 9, LinesHit: 8, Branches: 6, BranchesHit: 4}); summary != want {
+		t.Errorf("got %+v, want %+v", summary, want)
+	}
+}
+
+func TestLCOVUnreached(t *testing.T) {
+	// a function never called has its lines at 0 and its branches at "-"
+	covered := cover(t, `fun f(n) {
+  if (n) print n;
+}
+print 1;
+`)
+
+	var out strings.Builder
+	if err := covered.WriteLCOV(&out); err != nil {
+		t.Fatal(err)
+	}
+	for _, line := range []string{"BRDA:2,0,0,-", "BRDA:2,0,1,-", "DA:1,1", "DA:2,0", "DA:4,1", "LH:2"} {
+		if !strings.Contains(out.String(), line+"\n") {
+			t.Errorf("no %s in\n%s", line, out.String())
+		}
+	}
+}
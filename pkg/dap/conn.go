@@ -0,0 +1,66 @@
+package dap
+
+import (
+	"io"
+	"sync"
+
+	"github.com/neet-007/glox/pkg/framing"
+)
+
+// conn reads and writes messages framed by a Content-Length header. The
+// script writes output events from its own goroutine, so writes lock.
+type conn struct {
+	reader *framing.Reader
+	mu     sync.Mutex
+	writer *framing.Writer
+	seq    int
+}
+
+func newConn(reader io.Reader, writer io.Writer) *conn {
+	return &conn{
+		reader: framing.NewReader(reader),
+		writer: framing.NewWriter(writer),
+	}
+}
+
+func (c *conn) read() ([]byte, error) {
+	return c.reader.ReadMessage()
+}
+
+// write sends a response or an event, filling in its sequence number.
+func (c *conn) write(build func(seq int) any) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.seq++
+	return c.writer.WriteMessage(build(c.seq))
+}
+
+func (c *conn) respond(request request, body any, failure error) error {
+	return c.write(func(seq int) any {
+		resp := response{
+			Seq:        seq,
+			Type:       "response",
+			RequestSeq: request.Seq,
+			Success:    failure == nil,
+			Command:    request.Command,
+			Body:       body,
+		}
+		if failure != nil {
+			resp.Message = failure.Error()
+			resp.Body = nil
+		}
+		return resp
+	})
+}
+
+func (c *conn) event(name string, body any) error {
+	return c.write(func(seq int) any {
+		return event{
+			Seq:   seq,
+			Type:  "event",
+			Event: name,
+			Body:  body,
+		}
+	})
+}
@@ -0,0 +1,584 @@
+package dap
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync/atomic"
+
+	"github.com/neet-007/glox/pkg/debugger"
+	"github.com/neet-007/glox/pkg/interpreter"
+	"github.com/neet-007/glox/pkg/parser"
+	"github.com/neet-007/glox/pkg/runtime"
+)
+
+// Runner compiles and runs the script file with interpreter, reports
+// compile and runtime errors to stderr and returns the exit code.
+type Runner func(interpreter *interpreter.Interpreter, file string, source []byte, stderr io.Writer) int
+
+// lox has no threads, the script is always thread 1.
+const threadID = 1
+
+var errNotStopped = errors.New("the script is not stopped")
+
+// Server is a debug adapter for lox speaking DAP over a reader and a
+// writer, usually stdin and stdout. Requests are handled on the goroutine
+// calling Run while the script runs on its own, blocked on a channel
+// whenever it is stopped so the two never touch the interpreter at once.
+type Server struct {
+	conn   *conn
+	run    Runner
+	logger *log.Logger
+	// lineOffset and columnOffset turn the 1 based positions of the
+	// interpreter into the ones the client asked for.
+	lineOffset   int
+	columnOffset int
+
+	program    string
+	source     []byte
+	noDebug    bool
+	launched   bool
+	configured bool
+	stepper    *debugger.Stepper
+	// entry and pausing name the next stop "entry" or "pause".
+	entry   bool
+	pausing bool
+
+	interpreter *interpreter.Interpreter
+	running     bool
+	stopped     bool
+	evaluating  bool
+	quit        atomic.Bool
+	stops       chan debugger.Reason
+	resume      chan bool
+	done        chan int
+	closed      chan struct{}
+	// handles are what the variables references of the current stop point
+	// at, an environment or a value with members. Reference n is handles[n-1].
+	handles []any
+}
+
+func NewServer(reader io.Reader, writer io.Writer, logger *log.Logger, run Runner) *Server {
+	if logger == nil {
+		logger = log.New(io.Discard, "", 0)
+	}
+
+	return &Server{
+		conn:    newConn(reader, writer),
+		run:     run,
+		logger:  logger,
+		stepper: debugger.NewStepper(),
+		stops:   make(chan debugger.Reason),
+		resume:  make(chan bool),
+		done:    make(chan int),
+		closed:  make(chan struct{}),
+	}
+}
+
+// Run serves until the client disconnects or closes the connection and
+// returns the exit code, 1 when the connection closed first.
+func (s *Server) Run() int {
+	defer close(s.closed)
+
+	requests := make(chan request)
+	readErr := make(chan error, 1)
+	go func() {
+		for {
+			body, err := s.conn.read()
+			if err != nil {
+				readErr <- err
+				return
+			}
+
+			var req request
+			if err := json.Unmarshal(body, &req); err != nil {
+				s.logger.Printf("bad message: %v", err)
+				continue
+			}
+
+			select {
+			case requests <- req:
+			case <-s.closed:
+				return
+			}
+		}
+	}()
+
+	for {
+		select {
+		case req := <-requests:
+			if s.handle(req) {
+				return 0
+			}
+		case err := <-readErr:
+			if !errors.Is(err, io.EOF) {
+				s.logger.Printf("read: %v", err)
+			}
+			s.stopScript()
+			return 1
+		case reason := <-s.stops:
+			s.stop(reason)
+		case code := <-s.done:
+			s.finished(code)
+		}
+	}
+}
+
+// handle answers req and reports whether the client disconnected.
+func (s *Server) handle(req request) bool {
+	s.logger.Printf("<- %s", req.Command)
+
+	var body any
+	var err error
+	switch req.Command {
+	case "initialize":
+		body, err = s.initialize(req)
+		if err == nil {
+			defer s.conn.event("initialized", nil)
+		}
+	case "launch":
+		err = s.launch(req)
+		if err == nil && s.configured {
+			defer s.start()
+		}
+	case "setBreakpoints":
+		body, err = s.setBreakpoints(req)
+	case "configurationDone":
+		s.configured = true
+		if s.launched {
+			defer s.start()
+		}
+	case "threads":
+		body = ThreadsResponseBody{Threads: []Thread{{ID: threadID, Name: "main"}}}
+	case "stackTrace":
+		body, err = s.stackTrace(req)
+	case "scopes":
+		body, err = s.scopes(req)
+	case "variables":
+		body, err = s.variables(req)
+	case "evaluate":
+		body, err = s.evaluate(req)
+	case "continue":
+		err = s.resumeWith(s.stepper.Continue)
+		body = ContinueResponseBody{AllThreadsContinued: true}
+	case "next":
+		err = s.resumeWith(func() { s.stepper.StepOver(s.interpreter.CallDepth()) })
+	case "stepIn":
+		err = s.resumeWith(s.stepper.StepIn)
+	case "stepOut":
+		err = s.resumeWith(func() { s.stepper.StepOut(s.interpreter.CallDepth()) })
+	case "pause":
+		if s.running && !s.stopped {
+			s.pausing = true
+			s.stepper.StepIn()
+		}
+	case "terminate":
+		s.stopScript()
+	case "disconnect":
+		s.stopScript()
+		s.reply(req, nil, nil)
+		return true
+	default:
+		err = fmt.Errorf("unsupported command %s", req.Command)
+	}
+
+	s.reply(req, body, err)
+	return false
+}
+
+func (s *Server) reply(req request, body any, failure error) {
+	if err := s.conn.respond(req, body, failure); err != nil {
+		s.logger.Printf("write: %v", err)
+	}
+}
+
+func decode(req request, out any) error {
+	if len(req.Arguments) == 0 {
+		return nil
+	}
+	if err := json.Unmarshal(req.Arguments, out); err != nil {
+		return fmt.Errorf("bad arguments to %s: %w", req.Command, err)
+	}
+	return nil
+}
+
+func (s *Server) initialize(req request) (any, error) {
+	var args InitializeArguments
+	if err := decode(req, &args); err != nil {
+		return nil, err
+	}
+	if args.LinesStartAt1 != nil && !*args.LinesStartAt1 {
+		s.lineOffset = -1
+	}
+	if args.ColumnsStartAt1 != nil && !*args.ColumnsStartAt1 {
+		s.columnOffset = -1
+	}
+
+	return Capabilities{
+		SupportsConfigurationDoneRequest: true,
+		SupportsEvaluateForHovers:        true,
+		SupportsTerminateRequest:         true,
+	}, nil
+}
+
+func (s *Server) launch(req request) error {
+	var args LaunchArguments
+	if err := decode(req, &args); err != nil {
+		return err
+	}
+	if s.launched {
+		return errors.New("the script is already launched")
+	}
+	if args.Program == "" {
+		return errors.New("launch needs a program")
+	}
+
+	source, err := os.ReadFile(args.Program)
+	if err != nil {
+		return fmt.Errorf("failed to open %s: %w", args.Program, err)
+	}
+
+	s.program = args.Program
+	s.source = source
+	s.noDebug = args.NoDebug
+	s.launched = true
+	s.entry = args.StopOnEntry && !args.NoDebug
+	if !s.entry {
+		s.stepper.Continue()
+	}
+	if s.noDebug {
+		s.stepper.ClearBreakpoints()
+	}
+	return nil
+}
+
+func (s *Server) setBreakpoints(req request) (any, error) {
+	var args SetBreakpointsArguments
+	if err := decode(req, &args); err != nil {
+		return nil, err
+	}
+
+	// there is one script, every breakpoint is taken to be in it
+	breakpoints := []Breakpoint{}
+	s.stepper.ClearBreakpoints()
+	for _, requested := range args.Breakpoints {
+		line := requested.Line - s.lineOffset
+		breakpoint := Breakpoint{
+			Verified: !s.noDebug,
+			Source:   &args.Source,
+			Line:     requested.Line,
+		}
+		if s.noDebug {
+			breakpoint.Message = "the script was launched without debugging"
+		} else {
+			s.stepper.SetBreakpoint(line)
+		}
+		breakpoints = append(breakpoints, breakpoint)
+	}
+
+	return SetBreakpointsResponseBody{Breakpoints: breakpoints}, nil
+}
+
+// start runs the script once it is launched and configured.
+func (s *Server) start() {
+	if s.running || s.interpreter != nil {
+		return
+	}
+
+	s.interpreter = interpreter.NewInterpreter()
+	s.interpreter.Stdout = output{conn: s.conn, category: "stdout"}
+	s.interpreter.Hook = hook{server: s}
+	s.running = true
+
+	interpreter, program, source := s.interpreter, s.program, s.source
+	go func() {
+		s.done <- s.run(interpreter, program, source, output{conn: s.conn, category: "stderr"})
+	}()
+}
+
+// stop is called on the Run goroutine when the script stopped.
+func (s *Server) stop(reason debugger.Reason) {
+	s.stopped = true
+	s.handles = nil
+
+	name := "step"
+	switch {
+	case s.entry:
+		name = "entry"
+	case s.pausing:
+		name = "pause"
+	case reason == debugger.Breakpoint:
+		name = "breakpoint"
+	}
+	s.entry, s.pausing = false, false
+
+	s.conn.event("stopped", StoppedEventBody{
+		Reason:            name,
+		ThreadID:          threadID,
+		AllThreadsStopped: true,
+	})
+}
+
+func (s *Server) resumeWith(step func()) error {
+	if !s.stopped {
+		return errNotStopped
+	}
+
+	step()
+	s.stopped = false
+	s.handles = nil
+	s.resume <- true
+	return nil
+}
+
+func (s *Server) finished(code int) {
+	s.running = false
+	s.stopped = false
+	s.conn.event("exited", ExitedEventBody{ExitCode: code})
+	s.conn.event("terminated", nil)
+}
+
+// stopScript ends a running script at its next statement and waits for it.
+func (s *Server) stopScript() {
+	if !s.running {
+		return
+	}
+
+	s.quit.Store(true)
+	if s.stopped {
+		s.stopped = false
+		s.resume <- false
+	}
+	for {
+		select {
+		case <-s.stops:
+			s.resume <- false
+		case code := <-s.done:
+			s.finished(code)
+			return
+		}
+	}
+}
+
+func (s *Server) stackTrace(req request) (any, error) {
+	var args StackTraceArguments
+	if err := decode(req, &args); err != nil {
+		return nil, err
+	}
+	if !s.stopped {
+		return nil, errNotStopped
+	}
+
+	frames := s.interpreter.Frames()
+	stackFrames := []StackFrame{}
+	for n, frame := range frames {
+		if n < args.StartFrame || (args.Levels > 0 && n >= args.StartFrame+args.Levels) {
+			continue
+		}
+
+		name := "main"
+		if frame.Function != "" {
+			name = frame.Function
+		}
+		stackFrames = append(stackFrames, StackFrame{
+			ID:   n + 1,
+			Name: name,
+			Source: &Source{
+				Name: filepath.Base(s.program),
+				Path: s.program,
+			},
+			Line:   frame.Line + s.lineOffset,
+			Column: max(frame.Column, 1) + s.columnOffset,
+		})
+	}
+
+	return StackTraceResponseBody{
+		StackFrames: stackFrames,
+		TotalFrames: len(frames),
+	}, nil
+}
+
+// frame returns the frame with id, the top one when id is nil.
+func (s *Server) frame(id *int) (interpreter.Frame, error) {
+	if !s.stopped {
+		return interpreter.Frame{}, errNotStopped
+	}
+
+	frames := s.interpreter.Frames()
+	n := 0
+	if id != nil {
+		n = *id - 1
+	}
+	if n < 0 || n >= len(frames) {
+		return interpreter.Frame{}, fmt.Errorf("unknown frame %d", n+1)
+	}
+	return frames[n], nil
+}
+
+func (s *Server) scopes(req request) (any, error) {
+	var args ScopesArguments
+	if err := decode(req, &args); err != nil {
+		return nil, err
+	}
+	frame, err := s.frame(&args.FrameID)
+	if err != nil {
+		return nil, err
+	}
+
+	scopes := []Scope{}
+	globals := s.interpreter.Globals()
+	depth := 0
+	for environment := frame.Environment; environment != nil; environment = environment.Enclosing {
+		scope := Scope{
+			Name:               fmt.Sprintf("Scope %d", depth),
+			VariablesReference: s.reference(environment),
+		}
+		depth++
+		if environment == globals {
+			scope.Name = "Globals"
+		} else if len(environment.Values()) == 0 {
+			continue
+		} else if len(scopes) == 0 {
+			scope.PresentationHint = "locals"
+		}
+		scopes = append(scopes, scope)
+	}
+
+	return ScopesResponseBody{Scopes: scopes}, nil
+}
+
+func (s *Server) variables(req request) (any, error) {
+	var args VariablesArguments
+	if err := decode(req, &args); err != nil {
+		return nil, err
+	}
+	if !s.stopped {
+		return nil, errNotStopped
+	}
+	if args.VariablesReference < 1 || args.VariablesReference > len(s.handles) {
+		return nil, fmt.Errorf("unknown variables reference %d", args.VariablesReference)
+	}
+
+	variables := []Variable{}
+	switch handle := s.handles[args.VariablesReference-1].(type) {
+	case *runtime.Environment:
+		values := handle.Values()
+		names := []string{}
+		for name := range values {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+
+		for _, name := range names {
+			variables = append(variables, s.variable(name, values[name]))
+		}
+	default:
+		for _, member := range interpreter.Members(handle) {
+			variables = append(variables, s.variable(member.Name, member.Value))
+		}
+	}
+
+	return VariablesResponseBody{Variables: variables}, nil
+}
+
+func (s *Server) variable(name string, value any) Variable {
+	variable := Variable{
+		Name:  name,
+		Value: debugger.Show(value),
+	}
+	if len(interpreter.Members(value)) > 0 {
+		variable.VariablesReference = s.reference(value)
+	}
+	return variable
+}
+
+// reference hands out a variables reference for handle, valid until the
+// script runs again.
+func (s *Server) reference(handle any) int {
+	s.handles = append(s.handles, handle)
+	return len(s.handles)
+}
+
+func (s *Server) evaluate(req request) (any, error) {
+	var args EvaluateArguments
+	if err := decode(req, &args); err != nil {
+		return nil, err
+	}
+	frame, err := s.frame(args.FrameID)
+	if err != nil {
+		return nil, err
+	}
+
+	expr, err := debugger.ParseExpression(args.Expression)
+	if err != nil {
+		return nil, err
+	}
+
+	s.evaluating = true
+	value, err := s.interpreter.Evaluate(expr, frame.Environment)
+	s.evaluating = false
+	if err != nil {
+		if runtimeErr, ok := err.(*runtime.RuntimeError); ok {
+			return nil, errors.New(runtimeErr.Message)
+		}
+		return nil, err
+	}
+
+	result := EvaluateResponseBody{
+		Result: debugger.Show(value),
+	}
+	if len(interpreter.Members(value)) > 0 {
+		result.VariablesReference = s.reference(value)
+	}
+	return result, nil
+}
+
+// hook is the interpreter.Hook of the script. It runs on the script's
+// goroutine and, on a stop, hands over to Run until told to go on.
+type hook struct {
+	server *Server
+}
+
+func (h hook) Statement(stmt parser.Stmt) error {
+	s := h.server
+	// statements run by evaluate are on the Run goroutine, never stop them
+	if s.evaluating {
+		return nil
+	}
+	if s.quit.Load() {
+		return debugger.ErrQuit
+	}
+
+	reason := s.stepper.Stop(stmt, s.interpreter.CallDepth())
+	if reason == debugger.NotStopped {
+		return nil
+	}
+
+	s.stops <- reason
+	if !<-s.resume {
+		return debugger.ErrQuit
+	}
+	return nil
+}
+
+// output sends what the script writes to the client as output events.
+type output struct {
+	conn     *conn
+	category string
+}
+
+func (o output) Write(p []byte) (int, error) {
+	err := o.conn.event("output", OutputEventBody{
+		Category: o.category,
+		Output:   string(p),
+	})
+	if err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
@@ -0,0 +1,339 @@
+package dap
+
+import (
+	"encoding/json"
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/neet-007/glox/pkg/diagnostics"
+	"github.com/neet-007/glox/pkg/interpreter"
+	"github.com/neet-007/glox/pkg/parser"
+	"github.com/neet-007/glox/pkg/resolver"
+	"github.com/neet-007/glox/pkg/scanner"
+)
+
+const script = `fun add(a, b) {
+  var sum = a + b;
+  return sum;
+}
+var x = 1;
+var y = add(x, 2);
+print y;
+var l = [1, 2];
+print l[1];
+`
+
+// runner runs the script the way glox does, without the optimizer.
+func runner(interpreter_ *interpreter.Interpreter, file string, source []byte, stderr io.Writer) int {
+	tokens, errs := scanner.NewFileScanner(file, source, false).Scan()
+	if len(errs) > 0 {
+		return 65
+	}
+	stmts, errs := parser.NewParser(tokens, false).Parse()
+	if len(errs) > 0 {
+		return 65
+	}
+	for _, err := range resolver.NewResolver(interpreter_).Resolve(stmts) {
+		if err.Severity == diagnostics.Error {
+			return 65
+		}
+	}
+	if err := interpreter_.Interpret(stmts); err != nil {
+		io.WriteString(stderr, err.Error())
+		return 70
+	}
+	return 0
+}
+
+// incoming is a response or an event from the adapter.
+type incoming struct {
+	Type       string          `json:"type"`
+	RequestSeq int             `json:"request_seq"`
+	Success    bool            `json:"success"`
+	Command    string          `json:"command"`
+	Message    string          `json:"message"`
+	Event      string          `json:"event"`
+	Body       json.RawMessage `json:"body"`
+}
+
+// client is a scripted DAP client driving a Server over a pipe.
+type client struct {
+	t        *testing.T
+	conn     *conn
+	raw      net.Conn
+	seq      int
+	messages chan incoming
+	// events are the ones read while waiting for something else.
+	events []incoming
+	done   chan int
+}
+
+func newClient(t *testing.T) *client {
+	t.Helper()
+	serverSide, clientSide := net.Pipe()
+	c := &client{
+		t:        t,
+		conn:     newConn(clientSide, clientSide),
+		raw:      clientSide,
+		messages: make(chan incoming, 64),
+		done:     make(chan int, 1),
+	}
+	go func() {
+		c.done <- NewServer(serverSide, serverSide, nil, runner).Run()
+		serverSide.Close()
+	}()
+	// net.Pipe blocks a write until the other side reads it, so everything
+	// the adapter sends is read as soon as it is written.
+	go func() {
+		defer close(c.messages)
+		for {
+			body, err := c.conn.read()
+			if err != nil {
+				return
+			}
+			var message incoming
+			if err := json.Unmarshal(body, &message); err != nil {
+				return
+			}
+			c.messages <- message
+		}
+	}()
+	t.Cleanup(func() { clientSide.Close() })
+	return c
+}
+
+func (c *client) next(waitingFor string) incoming {
+	c.t.Helper()
+	select {
+	case message, ok := <-c.messages:
+		if !ok {
+			c.t.Fatalf("connection closed waiting for %s", waitingFor)
+		}
+		return message
+	case <-time.After(5 * time.Second):
+		c.t.Fatalf("timed out waiting for %s", waitingFor)
+	}
+	return incoming{}
+}
+
+// request sends command and decodes the body of its response into out.
+func (c *client) request(command string, arguments any, out any) {
+	c.t.Helper()
+	c.seq++
+	encoded, err := json.Marshal(arguments)
+	if err != nil {
+		c.t.Fatal(err)
+	}
+	c.raw.SetWriteDeadline(time.Now().Add(5 * time.Second))
+	err = c.conn.write(func(int) any {
+		return request{Seq: c.seq, Type: "request", Command: command, Arguments: encoded}
+	})
+	if err != nil {
+		c.t.Fatalf("write: %v", err)
+	}
+
+	for {
+		message := c.next(command)
+		if message.Type == "event" {
+			c.events = append(c.events, message)
+			continue
+		}
+		if message.RequestSeq != c.seq || message.Command != command {
+			c.t.Fatalf("got a response to %s, want one to %s", message.Command, command)
+		}
+		if !message.Success {
+			c.t.Fatalf("%s failed: %s", command, message.Message)
+		}
+		if out != nil {
+			if err := json.Unmarshal(message.Body, out); err != nil {
+				c.t.Fatalf("%s body %s: %v", command, message.Body, err)
+			}
+		}
+		return
+	}
+}
+
+// event waits for the event name, dropping the ones before it, and decodes
+// its body into out.
+func (c *client) event(name string, out any) {
+	c.t.Helper()
+	for {
+		var message incoming
+		if len(c.events) > 0 {
+			message, c.events = c.events[0], c.events[1:]
+		} else {
+			message = c.next(name + " event")
+		}
+		if message.Type != "event" {
+			c.t.Fatalf("got a response to %s waiting for a %s event", message.Command, name)
+		}
+		if message.Event != name {
+			if name == "stopped" && message.Event == "exited" {
+				c.t.Fatal("the script exited before it stopped")
+			}
+			continue
+		}
+		if out != nil {
+			if err := json.Unmarshal(message.Body, out); err != nil {
+				c.t.Fatal(err)
+			}
+		}
+		return
+	}
+}
+
+// stopped waits for the script to stop for reason and returns its stack.
+func (c *client) stopped(reason string) []StackFrame {
+	c.t.Helper()
+	var stopped StoppedEventBody
+	c.event("stopped", &stopped)
+	if stopped.Reason != reason || stopped.ThreadID != threadID {
+		c.t.Fatalf("stopped for %q on thread %d, want %q on %d", stopped.Reason, stopped.ThreadID, reason, threadID)
+	}
+
+	var trace StackTraceResponseBody
+	c.request("stackTrace", StackTraceArguments{ThreadID: threadID}, &trace)
+	if len(trace.StackFrames) == 0 {
+		c.t.Fatal("empty stack trace")
+	}
+	return trace.StackFrames
+}
+
+// variables returns the variables of reference as name=value strings.
+func (c *client) variables(reference int) map[string]Variable {
+	c.t.Helper()
+	var body VariablesResponseBody
+	c.request("variables", VariablesArguments{VariablesReference: reference}, &body)
+	variables := map[string]Variable{}
+	for _, variable := range body.Variables {
+		variables[variable.Name] = variable
+	}
+	return variables
+}
+
+func expectFrame(t *testing.T, frames []StackFrame, name string, line int, depth int) {
+	t.Helper()
+	if frames[0].Name != name || frames[0].Line != line || len(frames) != depth {
+		t.Fatalf("stopped in %s at line %d with %d frames, want %s at line %d with %d", frames[0].Name, frames[0].Line, len(frames), name, line, depth)
+	}
+}
+
+func TestServer(t *testing.T) {
+	program := filepath.Join(t.TempDir(), "script.lox")
+	if err := os.WriteFile(program, []byte(script), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	c := newClient(t)
+
+	var capabilities Capabilities
+	c.request("initialize", InitializeArguments{ClientID: "test"}, &capabilities)
+	if !capabilities.SupportsConfigurationDoneRequest {
+		t.Error("configurationDone is not supported")
+	}
+	c.event("initialized", nil)
+
+	c.request("launch", LaunchArguments{Program: program}, nil)
+	var breakpoints SetBreakpointsResponseBody
+	c.request("setBreakpoints", SetBreakpointsArguments{
+		Source:      Source{Path: program},
+		Breakpoints: []SourceBreakpoint{{Line: 6}, {Line: 9}},
+	}, &breakpoints)
+	if len(breakpoints.Breakpoints) != 2 || !breakpoints.Breakpoints[0].Verified {
+		t.Fatalf("got breakpoints %+v, want two verified", breakpoints.Breakpoints)
+	}
+	c.request("configurationDone", nil, nil)
+
+	expectFrame(t, c.stopped("breakpoint"), "main", 6, 1)
+
+	c.request("stepIn", nil, nil)
+	frames := c.stopped("step")
+	expectFrame(t, frames, "add", 2, 2)
+
+	var scopes ScopesResponseBody
+	c.request("scopes", ScopesArguments{FrameID: frames[0].ID}, &scopes)
+	if len(scopes.Scopes) == 0 || scopes.Scopes[0].PresentationHint != "locals" {
+		t.Fatalf("got scopes %+v, want the locals first", scopes.Scopes)
+	}
+	locals := c.variables(scopes.Scopes[0].VariablesReference)
+	if locals["a"].Value != "1" || locals["b"].Value != "2" {
+		t.Errorf("got locals %+v, want a = 1 and b = 2", locals)
+	}
+
+	c.request("next", nil, nil)
+	frames = c.stopped("step")
+	expectFrame(t, frames, "add", 3, 2)
+
+	var evaluated EvaluateResponseBody
+	c.request("evaluate", EvaluateArguments{Expression: "sum * 10", FrameID: &frames[0].ID}, &evaluated)
+	if evaluated.Result != "30" {
+		t.Errorf("sum * 10 evaluated to %s, want 30", evaluated.Result)
+	}
+	// the caller's frame sees x
+	c.request("evaluate", EvaluateArguments{Expression: "x", FrameID: &frames[1].ID}, &evaluated)
+	if evaluated.Result != "1" {
+		t.Errorf("x evaluated to %s, want 1", evaluated.Result)
+	}
+
+	c.request("stepOut", nil, nil)
+	expectFrame(t, c.stopped("step"), "main", 7, 1)
+
+	c.request("continue", nil, nil)
+	var output OutputEventBody
+	c.event("output", &output)
+	if output.Category != "stdout" || output.Output != "3\n" {
+		t.Errorf("got output %+v, want 3 on stdout", output)
+	}
+
+	frames = c.stopped("breakpoint")
+	expectFrame(t, frames, "main", 9, 1)
+	c.request("scopes", ScopesArguments{FrameID: frames[0].ID}, &scopes)
+	globals := c.variables(scopes.Scopes[len(scopes.Scopes)-1].VariablesReference)
+	if globals["y"].Value != "3" {
+		t.Errorf("y is %s, want 3", globals["y"].Value)
+	}
+	list := globals["l"]
+	if list.VariablesReference == 0 {
+		t.Fatal("the list l has no variables reference")
+	}
+	if items := c.variables(list.VariablesReference); items["[0]"].Value != "1" || items["[1]"].Value != "2" {
+		t.Errorf("got items %+v, want [0] = 1 and [1] = 2", items)
+	}
+
+	c.request("continue", nil, nil)
+	var exited ExitedEventBody
+	c.event("exited", &exited)
+	if exited.ExitCode != 0 {
+		t.Errorf("exit code %d, want 0", exited.ExitCode)
+	}
+	c.event("terminated", nil)
+
+	c.request("disconnect", nil, nil)
+	if code := <-c.done; code != 0 {
+		t.Errorf("adapter exited with %d, want 0", code)
+	}
+}
+
+func TestNotStopped(t *testing.T) {
+	c := newClient(t)
+	c.request("initialize", InitializeArguments{}, nil)
+
+	c.seq++
+	c.conn.write(func(int) any {
+		return request{Seq: c.seq, Type: "request", Command: "continue"}
+	})
+	for {
+		message := c.next("continue")
+		if message.Type != "response" {
+			continue
+		}
+		if message.Success || !strings.Contains(message.Message, errNotStopped.Error()) {
+			t.Errorf("continue with nothing running: %+v", message)
+		}
+		break
+	}
+}
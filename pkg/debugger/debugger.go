@@ -0,0 +1,282 @@
+package debugger
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/neet-007/glox/pkg/interpreter"
+	"github.com/neet-007/glox/pkg/parser"
+	"github.com/neet-007/glox/pkg/runtime"
+	"github.com/neet-007/glox/pkg/scanner"
+)
+
+// ErrQuit stops the script when the user quits the debugger.
+var ErrQuit = errors.New("debugger quit")
+
+// Session is an interactive debugger. It is an interpreter.Hook that
+// pauses before statements and reads commands until told to go on.
+type Session struct {
+	interpreter *interpreter.Interpreter
+	file        string
+	lines       []string
+	stepper     *Stepper
+	// frame is the frame selected for print and env, 0 being the top.
+	frame      int
+	evaluating bool
+	in         *bufio.Scanner
+	out        io.Writer
+}
+
+// NewSession makes a debugger for file, whose text is source. It starts
+// paused on the first statement.
+func NewSession(interpreter *interpreter.Interpreter, file string, source []byte, in io.Reader, out io.Writer) *Session {
+	return &Session{
+		interpreter: interpreter,
+		file:        file,
+		lines:       strings.Split(string(source), "\n"),
+		stepper:     NewStepper(),
+		in:          bufio.NewScanner(in),
+		out:         out,
+	}
+}
+
+// Continue makes the session run to the first breakpoint rather than
+// stopping on the first statement.
+func (s *Session) Continue() {
+	s.stepper.Continue()
+}
+
+func (s *Session) SetBreakpoint(line int) {
+	s.stepper.SetBreakpoint(line)
+}
+
+func (s *Session) Statement(stmt parser.Stmt) error {
+	if s.evaluating {
+		return nil
+	}
+	if s.stepper.Stop(stmt, s.interpreter.CallDepth()) == NotStopped {
+		return nil
+	}
+
+	s.frame = 0
+	s.showLocation(parser.StmtToken(stmt).Line)
+	return s.prompt()
+}
+
+// prompt reads commands until one resumes the script.
+func (s *Session) prompt() error {
+	for {
+		fmt.Fprint(s.out, "(glox) ")
+		if !s.in.Scan() {
+			return ErrQuit
+		}
+
+		command, argument, _ := strings.Cut(strings.TrimSpace(s.in.Text()), " ")
+		argument = strings.TrimSpace(argument)
+
+		switch command {
+		case "":
+		case "c", "continue":
+			s.stepper.Continue()
+			return nil
+		case "s", "step":
+			s.stepper.StepIn()
+			return nil
+		case "n", "next":
+			s.stepper.StepOver(s.interpreter.CallDepth())
+			return nil
+		case "finish", "out":
+			s.stepper.StepOut(s.interpreter.CallDepth())
+			return nil
+		case "b", "break":
+			line, err := strconv.Atoi(argument)
+			if err != nil || line < 1 {
+				fmt.Fprintln(s.out, "usage: break LINE")
+				continue
+			}
+			s.SetBreakpoint(line)
+			fmt.Fprintf(s.out, "Breakpoint at %s:%d\n", s.file, line)
+		case "d", "delete":
+			line, err := strconv.Atoi(argument)
+			if err != nil || !s.stepper.ClearBreakpoint(line) {
+				fmt.Fprintln(s.out, "usage: delete LINE, with a breakpoint on LINE")
+				continue
+			}
+		case "breakpoints":
+			s.listBreakpoints()
+		case "p", "print":
+			s.print(argument)
+		case "env":
+			s.printEnvironment()
+		case "bt", "backtrace":
+			s.backtrace()
+		case "f", "frame":
+			frame, err := strconv.Atoi(argument)
+			if err != nil || frame < 0 || frame >= len(s.interpreter.Frames()) {
+				fmt.Fprintln(s.out, "usage: frame N, N from backtrace")
+				continue
+			}
+			s.frame = frame
+			s.backtrace()
+		case "l", "list":
+			s.list(s.interpreter.Frames()[s.frame].Line)
+		case "q", "quit":
+			return ErrQuit
+		case "h", "help":
+			fmt.Fprint(s.out, help)
+		default:
+			fmt.Fprintf(s.out, "unknown command %s, try help\n", command)
+		}
+	}
+}
+
+const help = `commands:
+  c, continue      run until a breakpoint
+  s, step          run to the next statement, stepping into calls
+  n, next          run to the next statement, stepping over calls
+  finish, out      run until the current function returns
+  b, break LINE    stop before the statements on LINE
+  d, delete LINE   remove the breakpoint on LINE
+  breakpoints      list the breakpoints
+  p, print EXPR    evaluate EXPR in the selected frame
+  env              show the variables of the selected frame, innermost scope first
+  bt, backtrace    show the call stack
+  f, frame N       select frame N of the backtrace
+  l, list          show the source around the selected frame
+  q, quit          stop the script
+`
+
+func (s *Session) showLocation(line int) {
+	text := ""
+	if line >= 1 && line <= len(s.lines) {
+		text = strings.TrimSpace(s.lines[line-1])
+	}
+	fmt.Fprintf(s.out, "%s:%d: %s\n", s.file, line, text)
+}
+
+func (s *Session) list(line int) {
+	for n := max(line-3, 1); n <= min(line+3, len(s.lines)); n++ {
+		marker := "  "
+		if n == line {
+			marker = "=>"
+		}
+		if s.stepper.HasBreakpoint(n) {
+			marker = marker[:1] + "*"
+		}
+		fmt.Fprintf(s.out, "%s %4d  %s\n", marker, n, s.lines[n-1])
+	}
+}
+
+func (s *Session) listBreakpoints() {
+	lines := s.stepper.Breakpoints()
+	if len(lines) == 0 {
+		fmt.Fprintln(s.out, "no breakpoints")
+	}
+	for _, line := range lines {
+		fmt.Fprintf(s.out, "%s:%d\n", s.file, line)
+	}
+}
+
+func (s *Session) backtrace() {
+	for n, frame := range s.interpreter.Frames() {
+		marker := " "
+		if n == s.frame {
+			marker = "*"
+		}
+		name := "main"
+		if frame.Function != "" {
+			name = frame.Function + "()"
+		}
+		fmt.Fprintf(s.out, "%s#%d %s at %s:%d\n", marker, n, name, s.file, frame.Line)
+	}
+}
+
+func (s *Session) printEnvironment() {
+	globals := s.interpreter.Globals()
+	depth := 0
+	for environment := s.interpreter.Frames()[s.frame].Environment; environment != nil; environment = environment.Enclosing {
+		values := environment.Values()
+		label := fmt.Sprintf("scope %d", depth)
+		if environment == globals {
+			label = "globals"
+		}
+		depth++
+		if len(values) == 0 {
+			continue
+		}
+		fmt.Fprintf(s.out, "%s:\n", label)
+
+		names := []string{}
+		for name := range values {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			fmt.Fprintf(s.out, "  %s = %s\n", name, Show(values[name]))
+		}
+	}
+}
+
+func (s *Session) print(source string) {
+	if source == "" {
+		fmt.Fprintln(s.out, "usage: print EXPR")
+		return
+	}
+
+	expr, err := ParseExpression(source)
+	if err != nil {
+		fmt.Fprintln(s.out, err)
+		return
+	}
+
+	s.evaluating = true
+	value, err := s.interpreter.Evaluate(expr, s.interpreter.Frames()[s.frame].Environment)
+	s.evaluating = false
+	if err != nil {
+		if runtimeErr, ok := err.(*runtime.RuntimeError); ok {
+			fmt.Fprintln(s.out, runtimeErr.Message)
+			return
+		}
+		fmt.Fprintln(s.out, err)
+		return
+	}
+
+	fmt.Fprintln(s.out, Show(value))
+}
+
+// ParseExpression parses source as a single lox expression.
+func ParseExpression(source string) (parser.Expr, error) {
+	tokens, scannerErrors := scanner.NewScanner([]byte(source+";"), false).Scan()
+	if len(scannerErrors) > 0 {
+		return nil, errors.New(scannerErrors[0].Message)
+	}
+
+	stmts, parserErrors := parser.NewParser(tokens, false).Parse()
+	if len(parserErrors) > 0 {
+		return nil, errors.New(parserErrors[0].Message)
+	}
+
+	if len(stmts) != 1 {
+		return nil, errors.New("expect a single expression")
+	}
+	stmt, ok := stmts[0].(parser.ExpressionStmt)
+	if !ok {
+		return nil, errors.New("expect an expression")
+	}
+
+	return stmt.Expression, nil
+}
+
+// Show formats value for the debugger, strings quoted so they can be told
+// apart from other values.
+func Show(value any) string {
+	if str, ok := value.(string); ok {
+		return strconv.Quote(str)
+	}
+	return interpreter.Stringify(value)
+}
@@ -0,0 +1,153 @@
+package debugger
+
+import (
+	"sort"
+	"sync"
+
+	"github.com/neet-007/glox/pkg/parser"
+)
+
+type mode int
+
+const (
+	continuing mode = iota
+	steppingIn
+	steppingOver
+	steppingOut
+)
+
+// Reason is why a Stepper stopped before a statement.
+type Reason int
+
+const (
+	NotStopped Reason = iota
+	Stepped
+	Breakpoint
+)
+
+// Stepper decides which statements a debugger stops on, from the
+// breakpoints and the last step command. It is safe to change from one
+// goroutine while the script runs on another.
+type Stepper struct {
+	mu          sync.Mutex
+	breakpoints map[int]bool
+	mode        mode
+	// stepDepth is the call depth a step over or out started at.
+	stepDepth int
+	// stoppedLine and stoppedDepth are where the script last stopped, so
+	// continuing does not stop again on the rest of the same line.
+	stoppedLine  int
+	stoppedDepth int
+}
+
+// NewStepper makes a Stepper that stops on the first statement.
+func NewStepper() *Stepper {
+	return &Stepper{
+		breakpoints: map[int]bool{},
+		mode:        steppingIn,
+	}
+}
+
+// Stop says whether to stop before stmt, run at call depth depth.
+func (s *Stepper) Stop(stmt parser.Stmt, depth int) Reason {
+	// a block is only a scope, stop on the statements in it instead
+	if _, ok := stmt.(parser.Block); ok {
+		return NotStopped
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	line := parser.StmtToken(stmt).Line
+	if line != s.stoppedLine || depth != s.stoppedDepth {
+		s.stoppedLine = 0
+	}
+
+	reason := NotStopped
+	switch {
+	case s.mode == steppingIn,
+		s.mode == steppingOver && depth <= s.stepDepth,
+		s.mode == steppingOut && depth < s.stepDepth:
+		reason = Stepped
+	case s.breakpoints[line] && line != s.stoppedLine:
+		reason = Breakpoint
+	}
+
+	if reason != NotStopped {
+		s.stoppedLine, s.stoppedDepth = line, depth
+	}
+	return reason
+}
+
+// Continue runs to the next breakpoint.
+func (s *Stepper) Continue() {
+	s.setMode(continuing, 0)
+}
+
+// StepIn stops on the next statement, inside a call if there is one.
+func (s *Stepper) StepIn() {
+	s.setMode(steppingIn, 0)
+}
+
+// StepOver stops on the next statement not in a call made from depth.
+func (s *Stepper) StepOver(depth int) {
+	s.setMode(steppingOver, depth)
+}
+
+// StepOut stops once the call at depth has returned.
+func (s *Stepper) StepOut(depth int) {
+	s.setMode(steppingOut, depth)
+}
+
+func (s *Stepper) setMode(mode mode, depth int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.mode = mode
+	s.stepDepth = depth
+}
+
+func (s *Stepper) SetBreakpoint(line int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.breakpoints[line] = true
+}
+
+// ClearBreakpoint removes the breakpoint on line and reports whether there
+// was one.
+func (s *Stepper) ClearBreakpoint(line int) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	ok := s.breakpoints[line]
+	delete(s.breakpoints, line)
+	return ok
+}
+
+func (s *Stepper) ClearBreakpoints() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.breakpoints = map[int]bool{}
+}
+
+func (s *Stepper) HasBreakpoint(line int) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.breakpoints[line]
+}
+
+// Breakpoints returns the lines with a breakpoint in order.
+func (s *Stepper) Breakpoints() []int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	lines := []int{}
+	for line := range s.breakpoints {
+		lines = append(lines, line)
+	}
+	sort.Ints(lines)
+	return lines
+}
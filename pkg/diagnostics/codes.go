@@ -0,0 +1,117 @@
+package diagnostics
+
+// Code identifies a kind of error independently of its message, so tools
+// can match on it. Codes are stable, new ones are only ever appended.
+//
+//	E01xx scanner
+//	E02xx parser
+//	E03xx resolver
+//	E04xx runtime
+//	E05xx bytecode compiler
+//	W03xx resolver warnings
+//	W05xx lint rules
+type Code string
+
+const (
+	UnexpectedCharacter Code = "E0101"
+	UnterminatedString  Code = "E0102"
+	InvalidNumber       Code = "E0103"
+)
+
+const (
+	ExpectClassName           Code = "E0201"
+	ExpectSuperclassName      Code = "E0202"
+	ExpectClassBodyStart      Code = "E0203"
+	ExpectClassBodyEnd        Code = "E0204"
+	ExpectFunctionName        Code = "E0205"
+	ExpectParametersStart     Code = "E0206"
+	ExpectParameterName       Code = "E0207"
+	TooManyParameters         Code = "E0208"
+	ExpectParametersEnd       Code = "E0209"
+	ExpectFunctionBodyStart   Code = "E0210"
+	ExpectForStart            Code = "E0211"
+	ExpectForConditionEnd     Code = "E0212"
+	ExpectForClausesEnd       Code = "E0213"
+	ExpectVariableName        Code = "E0214"
+	ExpectVarSemicolon        Code = "E0215"
+	ExpectPrintSemicolon      Code = "E0216"
+	ExpectReturnSemicolon     Code = "E0217"
+	ExpectWhileStart          Code = "E0218"
+	ExpectWhileEnd            Code = "E0219"
+	ExpectIfStart             Code = "E0220"
+	ExpectIfEnd               Code = "E0221"
+	ExpectBlockEnd            Code = "E0222"
+	ExpectExpressionSemicolon Code = "E0223"
+	InvalidAssignmentTarget   Code = "E0224"
+	ExpectPropertyName        Code = "E0225"
+	ExpectIndexEnd            Code = "E0226"
+	TooManyArguments          Code = "E0227"
+	ExpectArgumentsEnd        Code = "E0228"
+	ExpectSuperDot            Code = "E0229"
+	ExpectSuperMethod         Code = "E0230"
+	ExpectListEnd             Code = "E0231"
+	ExpectGroupingEnd         Code = "E0232"
+	ExpectExpression          Code = "E0233"
+)
+
+const (
+	AlreadyDeclared        Code = "E0301"
+	SuperOutsideClass      Code = "E0302"
+	SuperWithoutSuperclass Code = "E0303"
+	ThisOutsideClass       Code = "E0304"
+	ReadInOwnInitializer   Code = "E0305"
+	InheritFromSelf        Code = "E0306"
+	ReturnFromTopLevel     Code = "E0307"
+	ReturnValueFromInit    Code = "E0308"
+)
+
+const (
+	UnusedVariable     Code = "W0301"
+	UnusedParameter    Code = "W0302"
+	UnreachableCode    Code = "W0303"
+	ShadowedVariable   Code = "W0304"
+	InconsistentReturn Code = "W0305"
+)
+
+const (
+	ClassInitArguments    Code = "W0501"
+	CallNonCallable       Code = "W0502"
+	SelfComparison        Code = "W0503"
+	AssignmentInCondition Code = "W0504"
+	EmptyBlock            Code = "W0505"
+)
+
+const (
+	UndefinedVariable     Code = "E0401"
+	UndefinedProperty     Code = "E0402"
+	SuperclassNotClass    Code = "E0403"
+	GetOnNonInstance      Code = "E0404"
+	SetOnNonInstance      Code = "E0405"
+	NotCallable           Code = "E0406"
+	ArityMismatch         Code = "E0407"
+	OperandsNotNumbers    Code = "E0408"
+	OperandNotNumber      Code = "E0409"
+	OperandsNotAddable    Code = "E0410"
+	UnknownBinaryOperator Code = "E0411"
+	UnknownUnaryOperator  Code = "E0412"
+	IndexOnNonList        Code = "E0413"
+	AssignIndexOnNonList  Code = "E0414"
+	IndexNotInteger       Code = "E0415"
+	IndexOutOfBounds      Code = "E0416"
+	LenNotIterable        Code = "E0417"
+	SuperclassNotFound    Code = "E0418"
+	SuperInstanceNotFound Code = "E0419"
+	SuperMethodNotFound   Code = "E0420"
+	ExecutionCancelled    Code = "E0421"
+	LimitExceeded         Code = "E0422"
+	StackOverflow         Code = "E0423"
+	AssertionFailed       Code = "E0424"
+	NativeFailed          Code = "E0425"
+)
+
+const (
+	TooManyLocals    Code = "E0501"
+	TooManyUpvalues  Code = "E0502"
+	TooManyConstants Code = "E0503"
+	JumpTooLarge     Code = "E0504"
+)
@@ -0,0 +1,131 @@
+package diagnostics
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+type Phase string
+
+const (
+	PhaseScanner  Phase = "scanner"
+	PhaseParser   Phase = "parser"
+	PhaseResolver Phase = "resolver"
+	PhaseCompiler Phase = "compiler"
+	PhaseRuntime  Phase = "runtime"
+	PhaseLint     Phase = "lint"
+)
+
+type Severity int
+
+const (
+	Error Severity = iota
+	Warning
+	Note
+)
+
+func (s Severity) String() string {
+	switch s {
+	case Error:
+		return "error"
+	case Warning:
+		return "warning"
+	case Note:
+		return "note"
+	default:
+		return "UNKNOWN_SEVERITY"
+	}
+}
+
+// Span is the piece of source a diagnostic points at. Text is the source
+// text of the span, empty when it points at the end of the input.
+type Span struct {
+	File   string
+	Line   int
+	Column int
+	Offset int
+	Text   string
+}
+
+// Diagnostic is an error or warning reported by any phase, from scanning
+// to running a script.
+type Diagnostic struct {
+	Code     Code
+	Phase    Phase
+	Severity Severity
+	Span     Span
+	Message  string
+	Notes    []string
+	Hints    []string
+}
+
+func New(phase Phase, code Code, span Span, message string) *Diagnostic {
+	return &Diagnostic{
+		Code:     code,
+		Phase:    phase,
+		Severity: Error,
+		Span:     span,
+		Message:  message,
+	}
+}
+
+func NewWarning(phase Phase, code Code, span Span, message string) *Diagnostic {
+	diagnostic := New(phase, code, span, message)
+	diagnostic.Severity = Warning
+	return diagnostic
+}
+
+func (d *Diagnostic) WithNote(note string) *Diagnostic {
+	d.Notes = append(d.Notes, note)
+	return d
+}
+
+func (d *Diagnostic) WithHint(hint string) *Diagnostic {
+	d.Hints = append(d.Hints, hint)
+	return d
+}
+
+func (d *Diagnostic) Error() string {
+	location := fmt.Sprintf("%d:%d", d.Span.Line, d.Span.Column)
+	if d.Span.File != "" {
+		location = d.Span.File + ":" + location
+	}
+
+	return fmt.Sprintf("%s: %s[%s]: %s", location, d.Severity, d.Code, d.Message)
+}
+
+func (d *Diagnostic) MarshalJSON() ([]byte, error) {
+	return json.Marshal(struct {
+		Code     Code     `json:"code"`
+		Severity string   `json:"severity"`
+		Phase    Phase    `json:"phase"`
+		File     string   `json:"file"`
+		Line     int      `json:"line"`
+		Column   int      `json:"column"`
+		Message  string   `json:"message"`
+		Notes    []string `json:"notes,omitempty"`
+		Hints    []string `json:"hints,omitempty"`
+	}{
+		Code:     d.Code,
+		Severity: d.Severity.String(),
+		Phase:    d.Phase,
+		File:     d.Span.File,
+		Line:     d.Span.Line,
+		Column:   d.Span.Column,
+		Message:  d.Message,
+		Notes:    d.Notes,
+		Hints:    d.Hints,
+	})
+}
+
+// HasErrors reports whether any of diagnostics is an error rather than a
+// warning or note.
+func HasErrors(diagnostics []*Diagnostic) bool {
+	for _, diagnostic := range diagnostics {
+		if diagnostic.Severity == Error {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,101 @@
+package diagnostics
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Plain renders d on one line in the classic lox format
+//
+//	[line 2] Error at '*': Expect operands to be numbers
+//
+// scanner errors have no token to point at and leave out the "at" part.
+func Plain(d *Diagnostic) string {
+	where := " at '" + d.Span.Text + "'"
+	if d.Span.Text == "" {
+		where = " at end"
+	}
+	if d.Phase == PhaseScanner {
+		where = ""
+	}
+
+	label := "Error"
+	if d.Severity != Error {
+		label = strings.ToUpper(d.Severity.String()[:1]) + d.Severity.String()[1:]
+	}
+
+	var builder strings.Builder
+	fmt.Fprintf(&builder, "[line %d] %s%s: %s\n", d.Span.Line, label, where, d.Message)
+	for _, note := range d.Notes {
+		fmt.Fprintf(&builder, "    %s\n", note)
+	}
+
+	return builder.String()
+}
+
+// Pretty renders d with the source line it points at and the span
+// underlined, in the style of
+//
+//	error[E0408]: Expect operands to be numbers
+//	 --> area.lox:2:12
+//	  |
+//	2 |   return w * "x";
+//	  |            ^
+func Pretty(d *Diagnostic, source []byte) string {
+	if d.Span.Line == 0 {
+		return Plain(d)
+	}
+
+	var builder strings.Builder
+
+	file := d.Span.File
+	if file == "" {
+		file = "<stdin>"
+	}
+	gutter := strings.Repeat(" ", len(fmt.Sprint(d.Span.Line)))
+
+	fmt.Fprintf(&builder, "%s[%s]: %s\n", d.Severity, d.Code, d.Message)
+	fmt.Fprintf(&builder, "%s--> %s:%d:%d\n", gutter, file, d.Span.Line, d.Span.Column)
+
+	if source != nil && d.Span.Offset <= len(source) {
+		lineStart := d.Span.Offset
+		for lineStart > 0 && source[lineStart-1] != '\n' {
+			lineStart--
+		}
+		lineEnd := d.Span.Offset
+		for lineEnd < len(source) && source[lineEnd] != '\n' {
+			lineEnd++
+		}
+		line := strings.TrimRight(string(source[lineStart:lineEnd]), "\r")
+
+		width := len(d.Span.Text)
+		if d.Span.Offset+width > lineEnd {
+			width = lineEnd - d.Span.Offset
+		}
+		if width < 1 {
+			width = 1
+		}
+
+		var padding strings.Builder
+		for _, c := range []byte(line[:min(d.Span.Offset-lineStart, len(line))]) {
+			if c == '\t' {
+				padding.WriteByte('\t')
+			} else {
+				padding.WriteByte(' ')
+			}
+		}
+
+		fmt.Fprintf(&builder, "%s |\n", gutter)
+		fmt.Fprintf(&builder, "%d | %s\n", d.Span.Line, line)
+		fmt.Fprintf(&builder, "%s | %s%s\n", gutter, padding.String(), strings.Repeat("^", width))
+	}
+
+	for _, note := range d.Notes {
+		fmt.Fprintf(&builder, "%s = note: %s\n", gutter, note)
+	}
+	for _, hint := range d.Hints {
+		fmt.Fprintf(&builder, "%s = help: %s\n", gutter, hint)
+	}
+
+	return builder.String()
+}
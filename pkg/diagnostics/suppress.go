@@ -0,0 +1,100 @@
+package diagnostics
+
+import "strings"
+
+const ignoreDirective = "glox:ignore"
+
+var ruleNames = map[Code]string{
+	UnusedVariable:        "unused-variable",
+	UnusedParameter:       "unused-parameter",
+	UnreachableCode:       "unreachable-code",
+	ShadowedVariable:      "shadowing",
+	InconsistentReturn:    "inconsistent-return",
+	ClassInitArguments:    "class-init-args",
+	CallNonCallable:       "non-callable-call",
+	SelfComparison:        "self-compare",
+	AssignmentInCondition: "assign-in-condition",
+	EmptyBlock:            "empty-block",
+}
+
+// RuleName is the name a warning is turned off by, the code itself for
+// warnings without one.
+func RuleName(code Code) string {
+	if name, ok := ruleNames[code]; ok {
+		return name
+	}
+	return string(code)
+}
+
+// Suppressions records the lines warnings are turned off on with a comment
+//
+//	var unused = 1; // glox:ignore unused-variable
+//
+// at the end of the line, or alone on the line before it. Without rule
+// names every warning on the line is dropped.
+type Suppressions struct {
+	lines map[int][]string
+}
+
+func NewSuppressions() *Suppressions {
+	return &Suppressions{
+		lines: map[int][]string{},
+	}
+}
+
+// AddComment records text, a whole "//" comment, if it is a directive.
+func (s *Suppressions) AddComment(line int, text string, ownLine bool) {
+	text = strings.TrimSpace(strings.TrimPrefix(text, "//"))
+	if !strings.HasPrefix(text, ignoreDirective) {
+		return
+	}
+
+	if ownLine {
+		line++
+	}
+
+	rules := strings.FieldsFunc(strings.TrimPrefix(text, ignoreDirective), func(r rune) bool {
+		return r == ',' || r == ' ' || r == '\t'
+	})
+
+	if existing, ok := s.lines[line]; ok && (len(existing) == 0 || len(rules) == 0) {
+		s.lines[line] = []string{}
+		return
+	}
+	s.lines[line] = append(s.lines[line], rules...)
+}
+
+func (s *Suppressions) Suppressed(d *Diagnostic) bool {
+	if d.Severity == Error {
+		return false
+	}
+
+	rules, ok := s.lines[d.Span.Line]
+	if !ok {
+		return false
+	}
+	if len(rules) == 0 {
+		return true
+	}
+
+	for _, rule := range rules {
+		if rule == RuleName(d.Code) || rule == string(d.Code) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Filter drops the suppressed warnings from diagnostics, errors are always
+// kept.
+func (s *Suppressions) Filter(diagnostics []*Diagnostic) []*Diagnostic {
+	kept := []*Diagnostic{}
+	for _, diagnostic := range diagnostics {
+		if !s.Suppressed(diagnostic) {
+			kept = append(kept, diagnostic)
+		}
+	}
+
+	return kept
+}
@@ -0,0 +1,259 @@
+package format
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+
+	"github.com/neet-007/glox/pkg/diagnostics"
+	"github.com/neet-007/glox/pkg/parser"
+	"github.com/neet-007/glox/pkg/scanner"
+)
+
+const indentation = "    "
+
+// Source formats a script. It is parsed first and the scanner and parser
+// errors are returned instead of formatting code that does not parse. The
+// error is for a bug in the formatter, output that would not scan to the
+// same tokens as the script, and no source is returned with it.
+//
+// Formatting works on the tokens rather than the AST so comments and blank
+// lines, kept as trivia on the tokens, come out where they went in. Every
+// statement goes on its own line, blocks are indented by four spaces and
+// runs of blank lines become one.
+func Source(file string, source []byte) ([]byte, []*diagnostics.Diagnostic, error) {
+	tokens, errors := scanner.NewFileScanner(file, source, false).Scan()
+	if len(errors) > 0 {
+		return nil, errors, nil
+	}
+
+	_, errors = parser.NewParser(tokens, false).Parse()
+	if len(errors) > 0 {
+		return nil, errors, nil
+	}
+
+	formatted := newPrinter().print(tokens)
+
+	formattedTokens, _ := scanner.NewFileScanner(file, formatted, false).Scan()
+	if !sameTokens(tokens, formattedTokens) {
+		return nil, nil, fmt.Errorf("format: formatting %s changed its tokens", file)
+	}
+
+	return formatted, nil, nil
+}
+
+type printer struct {
+	output bytes.Buffer
+	indent int
+	// parens counts the open parentheses, a ';' inside them is part of a
+	// for loop header and does not end the line.
+	parens int
+	// continued is set once a statement has been broken over lines by a
+	// comment, the rest of it is indented one more level.
+	continued      bool
+	atLineStart    bool
+	pendingNewline bool
+	previous       scanner.Token
+	previousUnary  bool
+}
+
+func newPrinter() *printer {
+	return &printer{
+		atLineStart: true,
+	}
+}
+
+func (p *printer) print(tokens []scanner.Token) []byte {
+	for i, token := range tokens {
+		if token.TokenType == scanner.RIGHT_BRACE {
+			p.indent--
+		}
+		p.trivia(token)
+
+		if token.TokenType == scanner.EOF {
+			break
+		}
+
+		if p.pendingNewline {
+			p.newline()
+		}
+		if p.atLineStart {
+			p.writeIndent()
+		} else if p.spaceBefore(token) {
+			p.output.WriteByte(' ')
+		}
+
+		p.output.WriteString(token.Lexeme)
+		p.atLineStart = false
+
+		unary := p.isUnary(token)
+		p.after(token, tokens[i+1])
+		p.previous = token
+		p.previousUnary = unary
+	}
+
+	if !p.atLineStart {
+		p.newline()
+	}
+
+	return p.output.Bytes()
+}
+
+// trivia writes the comments before token and keeps a blank line before
+// it, unless that would start or end a block with one.
+func (p *printer) trivia(token scanner.Token) {
+	if token.Trivia == nil {
+		return
+	}
+
+	for _, comment := range token.Trivia.Comments {
+		if !comment.OwnLine && !p.atLineStart {
+			p.output.WriteString(" " + comment.Text)
+			p.breakLine()
+			continue
+		}
+
+		if !p.atLineStart {
+			p.breakLine()
+		}
+		if comment.BlankLineBefore && p.blankLineAllowed() {
+			p.output.WriteByte('\n')
+		}
+		p.writeIndent()
+		p.output.WriteString(comment.Text)
+		p.newline()
+	}
+
+	if !token.Trivia.BlankLineBefore || !p.blankLineAllowed() {
+		return
+	}
+	switch token.TokenType {
+	case scanner.EOF, scanner.RIGHT_BRACE:
+		return
+	}
+	if p.pendingNewline {
+		p.newline()
+	}
+	if p.atLineStart {
+		p.output.WriteByte('\n')
+	}
+}
+
+func (p *printer) blankLineAllowed() bool {
+	return p.output.Len() > 0 && p.previous.TokenType != scanner.LEFT_BRACE
+}
+
+// breakLine ends the current line, in the middle of a statement unless a
+// newline was already due.
+func (p *printer) breakLine() {
+	if !p.pendingNewline {
+		p.continued = true
+	}
+	p.newline()
+}
+
+func (p *printer) newline() {
+	p.output.WriteByte('\n')
+	p.atLineStart = true
+	p.pendingNewline = false
+}
+
+func (p *printer) writeIndent() {
+	level := p.indent
+	if p.continued {
+		level++
+	}
+	p.output.WriteString(strings.Repeat(indentation, max(level, 0)))
+	p.atLineStart = false
+}
+
+// after updates the state once token is written, next is the token that
+// follows it.
+func (p *printer) after(token scanner.Token, next scanner.Token) {
+	switch token.TokenType {
+	case scanner.LEFT_PAREN:
+		p.parens++
+	case scanner.RIGHT_PAREN:
+		p.parens--
+	case scanner.LEFT_BRACE:
+		p.indent++
+		// an empty block stays "{}"
+		if next.TokenType != scanner.RIGHT_BRACE || next.Trivia != nil && len(next.Trivia.Comments) > 0 {
+			p.endStatement()
+		}
+	case scanner.RIGHT_BRACE:
+		switch next.TokenType {
+		case scanner.ELSE, scanner.SEMICOLON, scanner.RIGHT_PAREN, scanner.COMMA:
+		default:
+			p.endStatement()
+		}
+	case scanner.SEMICOLON:
+		if p.parens == 0 {
+			p.endStatement()
+		}
+	}
+}
+
+func (p *printer) endStatement() {
+	p.pendingNewline = true
+	p.continued = false
+}
+
+func (p *printer) spaceBefore(token scanner.Token) bool {
+	switch p.previous.TokenType {
+	case scanner.LEFT_PAREN, scanner.LEFT_BRACKET, scanner.DOT:
+		return false
+	case scanner.LEFT_BRACE:
+		return token.TokenType != scanner.RIGHT_BRACE
+	}
+	if p.previousUnary {
+		return false
+	}
+
+	switch token.TokenType {
+	case scanner.RIGHT_PAREN, scanner.RIGHT_BRACKET, scanner.COMMA, scanner.DOT, scanner.SEMICOLON:
+		return false
+	case scanner.LEFT_PAREN:
+		return !p.endsOperand()
+	case scanner.LEFT_BRACKET:
+		return !p.endsOperand()
+	}
+
+	return true
+}
+
+// endsOperand reports whether the previous token can end an operand, so a
+// following '(' or '[' is a call or an index and a '-' is binary.
+func (p *printer) endsOperand() bool {
+	switch p.previous.TokenType {
+	case scanner.IDENTIFIER, scanner.NUMBER, scanner.STRING, scanner.TRUE, scanner.FALSE, scanner.NIL,
+		scanner.THIS, scanner.RIGHT_PAREN, scanner.RIGHT_BRACKET:
+		return true
+	}
+
+	return false
+}
+
+func (p *printer) isUnary(token scanner.Token) bool {
+	switch token.TokenType {
+	case scanner.BANG:
+		return true
+	case scanner.MINUS:
+		return !p.endsOperand()
+	}
+
+	return false
+}
+
+func sameTokens(a []scanner.Token, b []scanner.Token) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i].TokenType != b[i].TokenType || a[i].Lexeme != b[i].Lexeme {
+			return false
+		}
+	}
+
+	return true
+}
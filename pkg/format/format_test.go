@@ -0,0 +1,91 @@
+package format
+
+import "testing"
+
+func TestSource(t *testing.T) {
+	tests := []struct {
+		name   string
+		source string
+		want   string
+	}{
+		{
+			name:   "statements on their own lines",
+			source: "var a=1;print a;",
+			want:   "var a = 1;\nprint a;\n",
+		},
+		{
+			name:   "block indentation",
+			source: "fun f(a,b){if(a){print a;}else{return b;}}",
+			want: "fun f(a, b) {\n" +
+				"    if (a) {\n" +
+				"        print a;\n" +
+				"    } else {\n" +
+				"        return b;\n" +
+				"    }\n" +
+				"}\n",
+		},
+		{
+			name:   "class methods",
+			source: "class A<B{init(x){this.x=x;}}",
+			want: "class A < B {\n" +
+				"    init(x) {\n" +
+				"        this.x = x;\n" +
+				"    }\n" +
+				"}\n",
+		},
+		{
+			name:   "for header",
+			source: "for(var i=0;i<3;i=i+1)print -i;",
+			want:   "for (var i = 0; i < 3; i = i + 1) print -i;\n",
+		},
+		{
+			name:   "own line comment",
+			source: "{\n// says hi\nprint \"hi\";\n}",
+			want:   "{\n    // says hi\n    print \"hi\";\n}\n",
+		},
+		{
+			name:   "trailing comment",
+			source: "var a = 1; // one\nvar b = 2;",
+			want:   "var a = 1; // one\nvar b = 2;\n",
+		},
+		{
+			name:   "blank lines collapse",
+			source: "print 1;\n\n\n\nprint 2;",
+			want:   "print 1;\n\nprint 2;\n",
+		},
+		{
+			name:   "lists",
+			source: "var l=[1,2,[3]];print l[0];",
+			want:   "var l = [1, 2, [3]];\nprint l[0];\n",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			formatted, errors, err := Source("test.lox", []byte(test.source))
+			if len(errors) > 0 || err != nil {
+				t.Fatalf("errors: %v %v", errors, err)
+			}
+			if string(formatted) != test.want {
+				t.Errorf("got\n%s\nwant\n%s", formatted, test.want)
+			}
+
+			again, _, err := Source("test.lox", formatted)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(again) != string(formatted) {
+				t.Errorf("formatting is not idempotent, second pass gave\n%s", again)
+			}
+		})
+	}
+}
+
+func TestSourceErrors(t *testing.T) {
+	for _, source := range []string{"print 1", "var = 2;", "\"open"} {
+		formatted, errors, err := Source("test.lox", []byte(source))
+		if len(errors) == 0 || formatted != nil || err != nil {
+			t.Errorf("%q: got %q, %v, %v, want scan or parse errors only", source, formatted, errors, err)
+		}
+	}
+}
@@ -0,0 +1,83 @@
+// Package framing reads and writes messages framed by a Content-Length
+// header, the way the language server and debug adapter protocols send
+// them over stdio:
+//
+//	Content-Length: 17\r\n
+//	\r\n
+//	{"jsonrpc":"2.0"}
+package framing
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/textproto"
+	"strconv"
+	"strings"
+)
+
+// MaxContentLength bounds the body a message may announce, so a bad header
+// can't make a server allocate without limit.
+const MaxContentLength = 64 << 20
+
+// Reader reads one message body at a time.
+type Reader struct {
+	reader *textproto.Reader
+}
+
+func NewReader(reader io.Reader) *Reader {
+	return &Reader{
+		reader: textproto.NewReader(bufio.NewReader(reader)),
+	}
+}
+
+// ReadMessage returns the body of the next message, io.EOF when the
+// stream ends between messages.
+func (r *Reader) ReadMessage() ([]byte, error) {
+	header, err := r.reader.ReadMIMEHeader()
+	if err != nil {
+		return nil, err
+	}
+
+	length, err := strconv.Atoi(strings.TrimSpace(header.Get("Content-Length")))
+	if err != nil {
+		return nil, fmt.Errorf("framing: bad Content-Length header: %w", err)
+	}
+	if length < 0 || length > MaxContentLength {
+		return nil, fmt.Errorf("framing: Content-Length %d out of range", length)
+	}
+
+	body := make([]byte, length)
+	if _, err := io.ReadFull(r.reader.R, body); err != nil {
+		return nil, err
+	}
+
+	return body, nil
+}
+
+// Writer writes values as JSON message bodies. It does not lock, callers
+// writing from several goroutines must.
+type Writer struct {
+	writer io.Writer
+}
+
+func NewWriter(writer io.Writer) *Writer {
+	return &Writer{
+		writer: writer,
+	}
+}
+
+// WriteMessage writes value encoded as JSON with its header.
+func (w *Writer) WriteMessage(value any) error {
+	body, err := json.Marshal(value)
+	if err != nil {
+		return err
+	}
+
+	if _, err := fmt.Fprintf(w.writer, "Content-Length: %d\r\n\r\n", len(body)); err != nil {
+		return err
+	}
+	_, err = w.writer.Write(body)
+	return err
+}
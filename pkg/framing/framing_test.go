@@ -0,0 +1,79 @@
+package framing
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+)
+
+func TestRoundTrip(t *testing.T) {
+	var stream bytes.Buffer
+	writer := NewWriter(&stream)
+	messages := []any{map[string]int{"seq": 1}, "é\r\n", []int{}}
+	for _, message := range messages {
+		if err := writer.WriteMessage(message); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if !strings.HasPrefix(stream.String(), "Content-Length: 9\r\n\r\n{\"seq\":1}") {
+		t.Errorf("wrote %q", stream.String())
+	}
+
+	reader := NewReader(&stream)
+	for _, want := range []string{`{"seq":1}`, `"é\r\n"`, `[]`} {
+		body, err := reader.ReadMessage()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(body) != want {
+			t.Errorf("read %q, want %q", body, want)
+		}
+	}
+	if _, err := reader.ReadMessage(); !errors.Is(err, io.EOF) {
+		t.Errorf("got %v after the last message, want io.EOF", err)
+	}
+}
+
+func TestReadHeaders(t *testing.T) {
+	tests := []struct {
+		name   string
+		stream string
+		body   string
+	}{
+		{"other headers", "Content-Type: application/vscode-jsonrpc; charset=utf-8\r\nContent-Length: 2\r\n\r\n{}", "{}"},
+		{"spaces", "Content-Length:   2  \r\n\r\n{}", "{}"},
+		{"empty body", "Content-Length: 0\r\n\r\n", ""},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			body, err := NewReader(strings.NewReader(test.stream)).ReadMessage()
+			if err != nil || string(body) != test.body {
+				t.Errorf("got %q, %v, want %q", body, err, test.body)
+			}
+		})
+	}
+}
+
+func TestReadRejectsContentLength(t *testing.T) {
+	for _, header := range []string{"-1", "9999999999", "many", ""} {
+		reader := NewReader(strings.NewReader("Content-Length: " + header + "\r\n\r\n{}"))
+		if _, err := reader.ReadMessage(); err == nil {
+			t.Errorf("Content-Length %q was accepted", header)
+		}
+	}
+
+	reader := NewReader(strings.NewReader("Content-Type: text/plain\r\n\r\n{}"))
+	if _, err := reader.ReadMessage(); err == nil {
+		t.Error("a message without Content-Length was accepted")
+	}
+}
+
+func TestReadShortBody(t *testing.T) {
+	reader := NewReader(strings.NewReader("Content-Length: 10\r\n\r\n{}"))
+	if _, err := reader.ReadMessage(); !errors.Is(err, io.ErrUnexpectedEOF) {
+		t.Errorf("got %v, want io.ErrUnexpectedEOF", err)
+	}
+}
@@ -0,0 +1,67 @@
+package interpreter
+
+import (
+	"github.com/neet-007/glox/pkg/runtime"
+	"github.com/neet-007/glox/pkg/scanner"
+)
+
+// frame is a call in progress. environment is the one of the caller at
+// callSite, kept so a debugger can look into frames other than the top.
+type frame struct {
+	name        string
+	callSite    scanner.Token
+	environment *runtime.Environment
+}
+
+func (i *Interpreter) enterCall(name string, callSite scanner.Token) *runtime.RuntimeError {
+	if err := i.checkCallDepth(callSite); err != nil {
+		return err
+	}
+
+	i.frames = append(i.frames, frame{
+		name:        name,
+		callSite:    callSite,
+		environment: i.environment,
+	})
+	return nil
+}
+
+func (i *Interpreter) exitCall() {
+	i.frames = i.frames[:len(i.frames)-1]
+}
+
+// stackTrace walks the call stack from the innermost call out, token being
+// where execution stopped in the innermost one.
+func (i *Interpreter) stackTrace(token scanner.Token) []runtime.StackFrame {
+	trace := make([]runtime.StackFrame, 0, len(i.frames)+1)
+	line := token.Line
+
+	for j := len(i.frames) - 1; j >= 0; j-- {
+		trace = append(trace, runtime.StackFrame{Function: i.frames[j].name, Line: line})
+		line = i.frames[j].callSite.Line
+	}
+
+	// a call made from Go has no lox caller
+	if line == 0 && len(trace) > 0 {
+		return trace
+	}
+	return append(trace, runtime.StackFrame{Line: line})
+}
+
+func callableName(callable Callable) string {
+	switch callable := callable.(type) {
+	case LoxFunction:
+		return callable.Declaration.Name.Lexeme
+	case Class:
+		return callable.Name
+	case clockNativeFunction:
+		return "clock"
+	case lenNativeFunction:
+		return "len"
+	case interface{ Name() string }:
+		// natives defined outside the package name themselves
+		return callable.Name()
+	default:
+		return callable.String()
+	}
+}
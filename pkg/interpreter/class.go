@@ -1,5 +1,7 @@
 package interpreter
 
+import "github.com/neet-007/glox/pkg/scanner"
+
 type Class struct {
 	methods    map[string]LoxFunction
 	Name       string
@@ -15,11 +17,23 @@ func NewLoxClass(name string, methods map[string]LoxFunction, class *Class) Clas
 }
 
 func (c Class) Call(interpreter *Interpreter, arguments []any) (any, error) {
+	return c.instantiate(interpreter, arguments, interpreter.position)
+}
+
+// instantiate makes an instance of c for the call at callSite.
+func (c Class) instantiate(interpreter *Interpreter, arguments []any, callSite scanner.Token) (any, error) {
+	if err := interpreter.allocate(callSite); err != nil {
+		return nil, err
+	}
+
 	instance := NewInstance(c)
 
 	initilzier, ok := c.FindMethod("init")
 	if ok {
-		initilzier.Bind(instance).Call(interpreter, arguments)
+		_, err := initilzier.Bind(instance).Call(interpreter, arguments)
+		if err != nil {
+			return nil, err
+		}
 	}
 
 	return instance, nil
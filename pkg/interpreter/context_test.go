@@ -0,0 +1,95 @@
+package interpreter_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/neet-007/glox/pkg/diagnostics"
+	"github.com/neet-007/glox/pkg/runtime"
+)
+
+func TestInterpretContext(t *testing.T) {
+	tests := []struct {
+		name   string
+		source string
+		// line is where the script is stopped.
+		line int
+		ctx  func() (context.Context, context.CancelFunc)
+		err  error
+	}{
+		{
+			name:   "cancelled loop",
+			source: "var i = 0;\nwhile (true) {\n  i = i + 1;\n}",
+			line:   2,
+			ctx: func() (context.Context, context.CancelFunc) {
+				ctx, cancel := context.WithCancel(context.Background())
+				time.AfterFunc(20*time.Millisecond, cancel)
+				return ctx, cancel
+			},
+			err: context.Canceled,
+		},
+		{
+			name:   "empty loop timing out",
+			source: "print 1;\nwhile (true) {}",
+			line:   2,
+			ctx: func() (context.Context, context.CancelFunc) {
+				return context.WithTimeout(context.Background(), 20*time.Millisecond)
+			},
+			err: context.DeadlineExceeded,
+		},
+		{
+			name:   "call after cancel",
+			source: "fun f() {\n  return 1;\n}\nf();",
+			line:   4,
+			ctx: func() (context.Context, context.CancelFunc) {
+				ctx, cancel := context.WithCancel(context.Background())
+				cancel()
+				return ctx, cancel
+			},
+			err: context.Canceled,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			interpreter_, stmts := compile(t, test.source, nil)
+			ctx, cancel := test.ctx()
+			defer cancel()
+
+			done := make(chan *runtime.RuntimeError, 1)
+			go func() {
+				done <- interpreter_.InterpretContext(ctx, stmts)
+			}()
+
+			var err *runtime.RuntimeError
+			select {
+			case err = <-done:
+			case <-time.After(5 * time.Second):
+				t.Fatal("the script was not stopped")
+			}
+
+			if err == nil || !errors.Is(err, test.err) {
+				t.Fatalf("got %v, want %v", err, test.err)
+			}
+			if err.Code != diagnostics.ExecutionCancelled || err.Token.Line != test.line {
+				t.Errorf("got %s on line %d, want %s on line %d", err.Code, err.Token.Line, diagnostics.ExecutionCancelled, test.line)
+			}
+		})
+	}
+}
+
+// TestInterpretContextReuse makes sure a cancelled run leaves the
+// interpreter able to run again.
+func TestInterpretContextReuse(t *testing.T) {
+	interpreter_, stmts := compile(t, "fun f(n) {\n  if (n > 0) return f(n - 1);\n  return n;\n}\nf(3);", nil)
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if err := interpreter_.InterpretContext(ctx, stmts); err == nil || err.Code != diagnostics.ExecutionCancelled {
+		t.Fatalf("got %v, want the run cancelled", err)
+	}
+	if err := interpreter_.InterpretContext(context.Background(), stmts); err != nil {
+		t.Errorf("got %v running again, want none", err)
+	}
+}
@@ -0,0 +1,177 @@
+package interpreter
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+
+	"github.com/neet-007/glox/pkg/diagnostics"
+	"github.com/neet-007/glox/pkg/parser"
+	"github.com/neet-007/glox/pkg/runtime"
+)
+
+// Hook is told about every statement before it runs, for debuggers and
+// the like. An error from it stops the script as if it was cancelled.
+type Hook interface {
+	Statement(stmt parser.Stmt) error
+}
+
+// Frame is a call on the lox call stack. Function is empty for the
+// top level of the script.
+type Frame struct {
+	Function    string
+	Line        int
+	Column      int
+	Environment *runtime.Environment
+}
+
+// Member is a field of an instance or an item of a list.
+type Member struct {
+	Name  string
+	Value any
+}
+
+// Frames returns the call stack from the innermost call out, as seen from
+// the statement being run.
+func (i *Interpreter) Frames() []Frame {
+	frames := make([]Frame, 0, len(i.frames)+1)
+	position := i.position
+	environment := i.environment
+
+	for j := len(i.frames) - 1; j >= 0; j-- {
+		frames = append(frames, Frame{
+			Function:    i.frames[j].name,
+			Line:        position.Line,
+			Column:      position.Column,
+			Environment: environment,
+		})
+		position = i.frames[j].callSite
+		environment = i.frames[j].environment
+	}
+
+	return append(frames, Frame{
+		Line:        position.Line,
+		Column:      position.Column,
+		Environment: environment,
+	})
+}
+
+// CallDepth is the number of calls in progress.
+func (i *Interpreter) CallDepth() int {
+	return len(i.frames)
+}
+
+func (i *Interpreter) Globals() *runtime.Environment {
+	return i.globals
+}
+
+// Evaluate evaluates expr in environment. expr does not need to have been
+// resolved: names the resolver has not seen are looked up by walking out
+// from environment.
+func (i *Interpreter) Evaluate(expr parser.Expr, environment *runtime.Environment) (any, error) {
+	prevEnvironment, prevDynamic, prevPosition := i.environment, i.dynamic, i.position
+	i.environment, i.dynamic = environment, true
+	defer func() {
+		i.environment, i.dynamic, i.position = prevEnvironment, prevDynamic, prevPosition
+	}()
+
+	return i.evaluate(expr)
+}
+
+// Call calls callee, a lox function, class or native, with arguments from
+// Go. Between runs of the script there is no lox caller, so the error trace
+// ends at the callee.
+func (i *Interpreter) Call(callee any, arguments []any) (any, *runtime.RuntimeError) {
+	callable, ok := callee.(Callable)
+	if !ok {
+		return nil, runtime.NewRuntimeError(i.position, diagnostics.NotCallable, "not callable")
+	}
+
+	value, err := i.call(callable, arguments, i.position)
+	if err != nil {
+		return nil, i.runtimeError(err)
+	}
+
+	return value, nil
+}
+
+// Truthy reports whether lox treats value as true, everything but nil and
+// false is.
+func Truthy(value any) bool {
+	if value == nil {
+		return false
+	}
+	if boolVal, ok := value.(bool); ok {
+		return boolVal
+	}
+
+	return true
+}
+
+// Equal reports whether two lox values are equal. Lists are equal when
+// their items are, instances only to themselves.
+func Equal(a any, b any) bool {
+	switch a := a.(type) {
+	case List:
+		b, ok := b.(List)
+		if !ok || len(a.items) != len(b.items) {
+			return false
+		}
+		for n := range a.items {
+			if !Equal(a.items[n], b.items[n]) {
+				return false
+			}
+		}
+		return true
+	case Instance:
+		b, ok := b.(Instance)
+		return ok && reflect.ValueOf(a.fields).UnsafePointer() == reflect.ValueOf(b.fields).UnsafePointer()
+	case LoxFunction:
+		b, ok := b.(LoxFunction)
+		return ok && a.closure == b.closure && a.Declaration.Name == b.Declaration.Name
+	case Class:
+		b, ok := b.(Class)
+		return ok && a.Name == b.Name && reflect.ValueOf(a.methods).UnsafePointer() == reflect.ValueOf(b.methods).UnsafePointer()
+	}
+
+	switch b.(type) {
+	case List, Instance, LoxFunction, Class:
+		return false
+	}
+	return a == b
+}
+
+// Members returns the fields of an instance sorted by name or the items
+// of a list, nil for other values.
+func Members(value any) []Member {
+	switch value := value.(type) {
+	case Instance:
+		names := []string{}
+		for name := range value.fields {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+
+		members := []Member{}
+		for _, name := range names {
+			members = append(members, Member{Name: name, Value: value.fields[name]})
+		}
+		return members
+	case List:
+		members := []Member{}
+		for n, item := range value.items {
+			members = append(members, Member{Name: fmt.Sprintf("[%d]", n), Value: item})
+		}
+		return members
+	}
+
+	return nil
+}
+
+// Stringify formats value the way print shows it.
+func Stringify(value any) string {
+	if value == nil {
+		return "nil"
+	}
+	return fmt.Sprintf("%v", value)
+}
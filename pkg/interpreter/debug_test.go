@@ -0,0 +1,94 @@
+package interpreter_test
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/neet-007/glox/pkg/diagnostics"
+	"github.com/neet-007/glox/pkg/interpreter"
+)
+
+var errNative = errors.New("native failed")
+
+// failNative is a native from outside the package returning a plain error.
+type failNative struct{}
+
+func (failNative) Arity() int {
+	return 0
+}
+
+func (failNative) Call(*interpreter.Interpreter, []any) (any, error) {
+	return nil, errNative
+}
+
+func (failNative) String() string {
+	return "<fn fail>"
+}
+
+func TestCallWrapsNativeErrors(t *testing.T) {
+	interpreter_ := interpreter.NewInterpreter()
+
+	_, err := interpreter_.Call(failNative{}, nil)
+	if err == nil {
+		t.Fatal("got no error")
+	}
+	if err.Code != diagnostics.NativeFailed || !errors.Is(err, errNative) {
+		t.Errorf("got %v (%s), want %s wrapping the native's error", err, err.Code, diagnostics.NativeFailed)
+	}
+}
+
+func TestCallLimit(t *testing.T) {
+	var interpreter_ *interpreter.Interpreter
+	if err := run(t, "fun f() { while (true) {} }", func(i *interpreter.Interpreter) { interpreter_ = i }); err != nil {
+		t.Fatal(err)
+	}
+	var f any
+	if err := interpreter_.Global("f", &f); err != nil {
+		t.Fatal(err)
+	}
+
+	interpreter_.Limits.MaxSteps = 100
+	if _, err := interpreter_.Call(f, nil); err == nil || err.Code != diagnostics.LimitExceeded {
+		t.Errorf("got %v, want a limit error", err)
+	}
+}
+
+func TestInterpretWrapsNativeErrors(t *testing.T) {
+	err := run(t, "print 1;\nfail();\n", func(i *interpreter.Interpreter) {
+		i.Define("fail", failNative{})
+	})
+	if err == nil || err.Code != diagnostics.NativeFailed || err.Token.Line != 2 {
+		t.Errorf("got %v, want %s at line 2", err, diagnostics.NativeFailed)
+	}
+}
+
+func TestEquality(t *testing.T) {
+	source := `
+class A {}
+var a = A();
+var l = [1, [2, "x"]];
+print l == l;
+print l == [1, [2, "x"]];
+print l != [1, [2, "y"]];
+print [a] == [a];
+print a == a;
+print a == A();
+print A == A;
+print [] == nil;
+print nil == nil;
+print 1 == "1";
+`
+	var out strings.Builder
+	err := run(t, source, func(i *interpreter.Interpreter) {
+		i.Stdout = &out
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := "true\ntrue\ntrue\ntrue\ntrue\nfalse\ntrue\nfalse\ntrue\nfalse\n"
+	if out.String() != want {
+		t.Errorf("got\n%s\nwant\n%s", out.String(), want)
+	}
+}
@@ -5,6 +5,7 @@ import (
 
 	"github.com/neet-007/glox/pkg/parser"
 	"github.com/neet-007/glox/pkg/runtime"
+	"github.com/neet-007/glox/pkg/trace"
 )
 
 type LoxFunction struct {
@@ -22,70 +23,27 @@ func NewLoxFunction(stmt parser.Function, closure *runtime.Environment, isIntitl
 }
 
 func (l LoxFunction) Call(interpreter *Interpreter, arguemnts []any) (any, error) {
-	if interpreter.Debug {
-		fmt.Printf("function call\n")
-	}
 	enviroemnt := runtime.NewEnvironment(l.closure)
 
 	for i := range l.Declaration.Parameters {
 		enviroemnt.Define(l.Declaration.Parameters[i].Lexeme, arguemnts[i])
+		interpreter.traceEvent(trace.Define, l.Declaration.Parameters[i], l.Declaration.Parameters[i].Lexeme, arguemnts[i])
 	}
 
 	err := interpreter.executeBlock(l.Declaration.Body, enviroemnt)
 	if err != nil {
-		if interpreter.Debug {
-			fmt.Printf("function call err %v %T\n", err, err)
-		}
 		if returnVal, ok := err.(*runtime.Return); ok {
-			if interpreter.Debug {
-				fmt.Printf("function call err is for return\n")
-			}
 			if l.isInitilizer {
-				if interpreter.Debug {
-					fmt.Printf("function call err is for return for initilizer\n")
-				}
-				val, err := l.closure.GetAt(0, "this")
-				if err != nil {
-					if interpreter.Debug {
-						fmt.Printf("function call err is for return for initilizer get this err %v %T\n", err, err)
-					}
-					return nil, err
-				}
-				if interpreter.Debug {
-					fmt.Printf("function call err is for return for initilizer finshed value %v\n", val)
-				}
-				return val, nil
-			}
-			if interpreter.Debug {
-				fmt.Printf("function call err is for return finished value\n", returnVal.Value)
+				return l.closure.GetAt(0, 0), nil
 			}
 			return returnVal.Value, nil
 		}
 
-		if interpreter.Debug {
-			fmt.Printf("function call err is for return err %v %T\n", err, err)
-		}
 		return nil, err
 	}
 
 	if l.isInitilizer {
-		if interpreter.Debug {
-			fmt.Printf("function call not return stamtent for initilizer\n")
-		}
-		val, err := l.closure.GetAt(0, "this")
-		if err != nil {
-			if interpreter.Debug {
-				fmt.Printf("function call not return stamtent for initilizer err %v %T\n", err, err)
-			}
-			return nil, err
-		}
-		if interpreter.Debug {
-			fmt.Printf("function call not return stamtent for initilizer finished value\n", val)
-		}
-		return val, nil
-	}
-	if interpreter.Debug {
-		fmt.Printf("function call not return stamtent finished\n")
+		return l.closure.GetAt(0, 0), nil
 	}
 	return nil, nil
 }
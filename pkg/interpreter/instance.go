@@ -1,6 +1,7 @@
 package interpreter
 
 import (
+	"github.com/neet-007/glox/pkg/diagnostics"
 	"github.com/neet-007/glox/pkg/runtime"
 	"github.com/neet-007/glox/pkg/scanner"
 )
@@ -27,7 +28,7 @@ func (i Instance) Get(name scanner.Token) (any, error) {
 		return method.Bind(i), nil
 	}
 
-	return nil, runtime.NewRuntimeError(name, "Undefined property '"+name.Lexeme)
+	return nil, runtime.NewRuntimeError(name, diagnostics.UndefinedProperty, "Undefined property '"+name.Lexeme)
 }
 
 func (i Instance) Set(name scanner.Token, value any) (any, error) {
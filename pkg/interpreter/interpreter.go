@@ -1,19 +1,48 @@
 package interpreter
 
 import (
+	"context"
 	"fmt"
+	"io"
+	"os"
 	"time"
 
+	"github.com/neet-007/glox/pkg/diagnostics"
 	"github.com/neet-007/glox/pkg/parser"
 	"github.com/neet-007/glox/pkg/runtime"
 	"github.com/neet-007/glox/pkg/scanner"
+	"github.com/neet-007/glox/pkg/trace"
 )
 
 type Interpreter struct {
 	globals     *runtime.Environment
 	environment *runtime.Environment
-	locals      map[parser.Expr]int
-	Debug       bool
+	locals      map[parser.ID]local
+	ctx         context.Context
+	steps       int
+	allocations int
+	frames      []frame
+	// position is the statement being run, dynamic is set while a
+	// debugger evaluates an expression the resolver has not seen.
+	position scanner.Token
+	dynamic  bool
+	Limits   Limits
+	// MaxStackDepth is the call depth that raises "Stack overflow.", zero
+	// turns the check off.
+	MaxStackDepth int
+	// Hook, when set, is called before every statement runs.
+	Hook Hook
+	// Stdout is where print writes, os.Stdout by default.
+	Stdout io.Writer
+	// Tracer, when set, is told about statements, calls, variables and
+	// errors as the script runs.
+	Tracer trace.Tracer
+	// TailCalls makes "return f(...)" call f in place of the function
+	// returning, so tail recursion runs in constant space. It is on by
+	// default and not done while a Tracer or Hook is set, as their view
+	// of the call stack would lose the frames replaced; running out of
+	// stack then notes that tail calls were off.
+	TailCalls bool
 }
 
 type clockNativeFunction struct{}
@@ -38,12 +67,12 @@ func (l lenNativeFunction) Arity() int {
 
 func (l lenNativeFunction) Call(interpreter *Interpreter, arguemnts []any) (any, error) {
 	if len(arguemnts) != 1 {
-		return nil, runtime.NewRuntimeError(scanner.Token{TokenType: scanner.Error}, "len must be passed 1 argument that is iterable")
+		return nil, runtime.NewRuntimeError(scanner.Token{TokenType: scanner.Error}, diagnostics.LenNotIterable, "len must be passed 1 argument that is iterable")
 	}
 
 	list, ok := arguemnts[0].(List)
 	if !ok {
-		return nil, runtime.NewRuntimeError(scanner.Token{TokenType: scanner.Error}, "len must be passed 1 argument that is iterable")
+		return nil, runtime.NewRuntimeError(scanner.Token{TokenType: scanner.Error}, diagnostics.LenNotIterable, "len must be passed 1 argument that is iterable")
 	}
 
 	return float64(len(list.items)), nil
@@ -53,8 +82,8 @@ func (l lenNativeFunction) String() string {
 	return "<fn native>"
 }
 
-func NewInterpreter(debug bool) *Interpreter {
-	globals := runtime.NewEnvironment(nil)
+func NewInterpreter() *Interpreter {
+	globals := runtime.NewGlobals()
 	clock := clockNativeFunction{}
 	len_ := lenNativeFunction{}
 	var clockCallabe Callable = clock
@@ -63,34 +92,107 @@ func NewInterpreter(debug bool) *Interpreter {
 	globals.Define("clock", clockCallabe)
 	globals.Define("len", lenCallable)
 	return &Interpreter{
-		globals:     globals,
-		environment: globals,
-		locals:      map[parser.Expr]int{},
-		Debug:       debug,
+		globals:       globals,
+		environment:   globals,
+		locals:        map[parser.ID]local{},
+		ctx:           context.Background(),
+		MaxStackDepth: DefaultMaxStackDepth,
+		Stdout:        os.Stdout,
+		TailCalls:     true,
 	}
 }
 
 func (i *Interpreter) Interpret(stmts []parser.Stmt) *runtime.RuntimeError {
+	return i.InterpretContext(context.Background(), stmts)
+}
+
+// InterpretContext runs stmts until they finish or ctx is done. A cancelled
+// run returns a RuntimeError that wraps ctx.Err() at the loop or call where
+// execution stopped.
+func (i *Interpreter) InterpretContext(ctx context.Context, stmts []parser.Stmt) *runtime.RuntimeError {
+	prevCtx := i.ctx
+	i.ctx = ctx
+	i.frames = i.frames[:0]
+	i.steps = 0
+	i.allocations = 0
+	defer func() {
+		i.ctx = prevCtx
+		i.position = scanner.Token{}
+	}()
+
 	for _, stmt := range stmts {
 		err := i.execute(stmt)
 
 		if err != nil {
-			if runtimeErr, ok := err.(*runtime.RuntimeError); ok {
-				return runtimeErr
+			runtimeErr := i.runtimeError(err)
+			if runtimeErr.Trace == nil {
+				runtimeErr.Trace = i.stackTrace(runtimeErr.Token)
 			}
-
-			panic(fmt.Sprintf("Excpect runtime error got %v", err))
+			if i.Tracer != nil {
+				i.Tracer.Trace(trace.Event{
+					Kind:    trace.Error,
+					File:    runtimeErr.Token.File,
+					Line:    runtimeErr.Token.Line,
+					Column:  runtimeErr.Token.Column,
+					Message: runtimeErr.Message,
+				})
+			}
+			return runtimeErr
 		}
 	}
 
 	return nil
 }
 
-func (i *Interpreter) ResolveExpr(expr parser.Expr, depth int) {
-	i.locals[expr] = depth
+// runtimeError returns err as a *runtime.RuntimeError. Anything else, such
+// as an error from a native defined outside the package, is wrapped at the
+// statement being run.
+func (i *Interpreter) runtimeError(err error) *runtime.RuntimeError {
+	if runtimeErr, ok := err.(*runtime.RuntimeError); ok {
+		return runtimeErr
+	}
+	return runtime.WrapRuntimeError(i.position, diagnostics.NativeFailed, err.Error(), err)
+}
+
+// local is where a resolved variable lives, slot in the environment depth
+// scopes out.
+type local struct {
+	depth int
+	slot  int
+}
+
+// ResolveExpr records that the variable expr refers to is in slot of the
+// environment depth scopes out.
+func (i *Interpreter) ResolveExpr(expr parser.Expr, depth int, slot int) {
+	i.locals[expr.ID()] = local{depth: depth, slot: slot}
+}
+
+// Local is where expr was resolved to, false for globals.
+func (i *Interpreter) Local(expr parser.Expr) (depth int, slot int, ok bool) {
+	local, ok := i.locals[expr.ID()]
+	return local.depth, local.slot, ok
+}
+
+func (i *Interpreter) checkContext(token scanner.Token) *runtime.RuntimeError {
+	if err := i.ctx.Err(); err != nil {
+		return runtime.WrapRuntimeError(token, diagnostics.ExecutionCancelled, "Execution cancelled: "+err.Error(), err)
+	}
+
+	return nil
 }
 
 func (i *Interpreter) execute(stmt parser.Stmt) error {
+	i.position = parser.StmtToken(stmt)
+	if tErr := i.step(i.position); tErr != nil {
+		return tErr
+	}
+	if i.Hook != nil {
+		if err := i.Hook.Statement(stmt); err != nil {
+			return runtime.WrapRuntimeError(i.position, diagnostics.ExecutionCancelled, "Execution cancelled: "+err.Error(), err)
+		}
+	}
+	i.traceEvent(trace.Statement, i.position, parser.StmtName(stmt), nil)
+
 	_, err := stmt.Accept(i)
 	return err
 }
@@ -100,39 +202,28 @@ func (i *Interpreter) evaluate(expr parser.Expr) (any, error) {
 }
 
 func (i *Interpreter) VisitClassStmt(stmt parser.Class) (any, error) {
-	if i.Debug {
-		fmt.Printf("interpreter visit class name:%s\n", stmt.Name.Lexeme)
-	}
 	var superClass Class
 	var zeroVariabe parser.Variable
 	if stmt.SuperClass != zeroVariabe {
-		if i.Debug {
-			fmt.Printf("interpreter visit class name:%s has superclass\n", stmt.Name.Lexeme)
-		}
 		superClassVal, err := i.evaluate(stmt.SuperClass)
 		if err != nil {
-			if i.Debug {
-				fmt.Printf("interpreter visit class name:%s superclass err\n", stmt.Name.Lexeme)
-			}
 			return nil, err
 		}
 
 		superClassClass, ok := superClassVal.(Class)
 		if !ok {
-			if i.Debug {
-				fmt.Printf("interpreter visit class name:%s superclass not class\n", stmt.Name.Lexeme)
-			}
-			return nil, runtime.NewRuntimeError(stmt.Name, "Superclass must be a class")
+			return nil, runtime.NewRuntimeError(stmt.Name, diagnostics.SuperclassNotClass, "Superclass must be a class")
 		}
 
 		superClass = superClassClass
 	}
+	if tErr := i.allocate(stmt.Name); tErr != nil {
+		return nil, tErr
+	}
+
 	i.environment.Define(stmt.Name.Lexeme, nil)
 
 	if stmt.SuperClass != zeroVariabe {
-		if i.Debug {
-			fmt.Printf("interpreter visit class name:%s make superclass env and define super\n", stmt.Name.Lexeme)
-		}
 		i.environment = runtime.NewEnvironment(i.environment)
 		i.environment.Define("super", superClass)
 	}
@@ -151,113 +242,84 @@ func (i *Interpreter) VisitClassStmt(stmt parser.Class) (any, error) {
 	}
 
 	i.environment.Assign(stmt.Name, class)
+	i.traceEvent(trace.Define, stmt.Name, stmt.Name.Lexeme, class)
 
-	if i.Debug {
-		fmt.Printf("interpreter visit class name:%s finished\n", stmt.Name.Lexeme)
-	}
 	return nil, nil
 }
 
 func (i *Interpreter) VisitReturnStmt(stmt parser.Return) (any, error) {
-	if i.Debug {
-		fmt.Printf("interpreter visit return \n")
+	if call, ok := stmt.Value.(parser.Call); ok && i.TailCalls && !i.tailCallsSuspended() {
+		callable, arguments, err := i.evaluateCall(call)
+		if err != nil {
+			return nil, err
+		}
+		return nil, runtime.NewTailCall(callable, arguments, call.Paren)
 	}
+
 	var val any = nil
 	var err error
 	if stmt.Value != nil {
-		if i.Debug {
-			fmt.Printf("interpreter visit return has value\n")
-		}
 		val, err = i.evaluate(stmt.Value)
 		if err != nil {
-			if i.Debug {
-				fmt.Printf("interpreter visit return value error %v %T\n", err, err)
-			}
 			return nil, err
 		}
 	}
 
-	if i.Debug {
-		fmt.Printf("interpreter visit return finish value:%v\n", val)
-	}
 	return nil, runtime.NewReturn(val)
 }
 
 func (i *Interpreter) VisitThisExpr(expr parser.This) (any, error) {
-	return i.lookUpVariable(expr.Keyword, expr)
+	return i.lookUpVariable(expr.Keyword, expr.ID())
 }
 
 func (i *Interpreter) VisitSetExpr(expr parser.Set) (any, error) {
-	if i.Debug {
-		fmt.Printf("interpreter visit set name:%v\n", expr.Name.Lexeme)
-	}
 	object, err := i.evaluate(expr.Object)
 	if err != nil {
-		if i.Debug {
-			fmt.Printf("interpreter visit set name:%v object err %v %T\n", expr.Name.Lexeme, err, err)
-		}
 		return nil, err
 	}
 
 	objectInstance, ok := object.(Instance)
 	if !ok {
-		if i.Debug {
-			fmt.Printf("interpreter visit set name:%v not instance\n", expr.Name.Lexeme)
-		}
-		return nil, runtime.NewRuntimeError(expr.Name, "Only instances have properties")
+		return nil, runtime.NewRuntimeError(expr.Name, diagnostics.SetOnNonInstance, "Only instances have properties")
 	}
 
 	value, err := i.evaluate(expr.Value)
 	if err != nil {
-		if i.Debug {
-			fmt.Printf("interpreter visit set name:%v value err %v %T\n", expr.Name.Lexeme, err, err)
-		}
 		return nil, err
 	}
 
 	objectInstance.Set(expr.Name, value)
 
-	if i.Debug {
-		fmt.Printf("interpreter visit set name:%v finished\n", expr.Name.Lexeme)
-	}
 	return value, nil
 }
 
 func (i *Interpreter) VisitGetExpr(expr parser.Get) (any, error) {
-	if i.Debug {
-		fmt.Printf("interpreter visit get name:%v\n", expr.Name.Lexeme)
-	}
 	object, err := i.evaluate(expr.Object)
 	if err != nil {
-		if i.Debug {
-			fmt.Printf("interpreter visit get name:%v object err %v %T\n", expr.Name.Lexeme, err, err)
-		}
 		return nil, err
 	}
 
 	if objectInstance, ok := object.(Instance); ok {
-		if i.Debug {
-			fmt.Printf("interpreter visit get name:%v finished\n", expr.Name.Lexeme)
-		}
 		return objectInstance.Get(expr.Name)
 	}
 
-	if i.Debug {
-		fmt.Printf("interpreter visit get name:%v not instance\n", expr.Name.Lexeme)
-	}
-	return nil, runtime.NewRuntimeError(expr.Name, "Only instances have properties")
+	return nil, runtime.NewRuntimeError(expr.Name, diagnostics.GetOnNonInstance, "Only instances have properties")
 }
 
 func (i *Interpreter) VisitCallExpr(expr parser.Call) (any, error) {
-	if i.Debug {
-		fmt.Printf("interpreter visit call\n")
+	callable, arguments, err := i.evaluateCall(expr)
+	if err != nil {
+		return nil, err
 	}
+
+	return i.call(callable, arguments, expr.Paren)
+}
+
+// evaluateCall evaluates what expr calls and its arguments.
+func (i *Interpreter) evaluateCall(expr parser.Call) (Callable, []any, error) {
 	callee, err := i.evaluate(expr.Callee)
 	if err != nil {
-		if i.Debug {
-			fmt.Printf("interpreter visit call calle error %v %T\n", err, err)
-		}
-		return nil, err
+		return nil, nil, err
 	}
 
 	arguments := []any{}
@@ -265,47 +327,94 @@ func (i *Interpreter) VisitCallExpr(expr parser.Call) (any, error) {
 	for _, arg := range expr.Arguments {
 		argVal, err := i.evaluate(arg)
 		if err != nil {
-			if i.Debug {
-				fmt.Printf("interpreter visit call arg vall error %v %T\n", err, err)
-			}
-			return nil, err
+			return nil, nil, err
 		}
 
 		arguments = append(arguments, argVal)
 	}
 
+	if tErr := i.checkContext(expr.Paren); tErr != nil {
+		return nil, nil, tErr
+	}
+
 	callable, ok := callee.(Callable)
 	if !ok {
-		if i.Debug {
-			fmt.Printf("interpreter visit call not callalbe\n")
-		}
-		return nil, runtime.NewRuntimeError(expr.Paren, "not callable")
+		return nil, nil, runtime.NewRuntimeError(expr.Paren, diagnostics.NotCallable, "not callable")
 	}
 
+	return callable, arguments, nil
+}
+
+// call calls callable from callSite, keeping the call stack.
+func (i *Interpreter) call(callable Callable, arguments []any, callSite scanner.Token) (any, error) {
 	if len(arguments) != callable.Arity() {
-		if i.Debug {
-			fmt.Printf("interpreter visit call err args %d vs arity %d\n", len(arguments), callable.Arity())
+		return nil, runtime.NewRuntimeError(callSite, diagnostics.ArityMismatch, fmt.Sprintf("expect %d parameters got %d arguments", callable.Arity(), len(arguments)))
+	}
+
+	name := callableName(callable)
+	if tErr := i.enterCall(name, callSite); tErr != nil {
+		return nil, tErr
+	}
+	i.traceCall(trace.Call, callSite, name, nil)
+	callVal, tErr := i.invoke(callable, arguments, callSite)
+
+	// a call in tail position takes over the frame of the function ending
+	// in it, as if made from the same call site.
+	site := callSite
+	for {
+		tailCall, ok := tErr.(*runtime.TailCall)
+		if !ok {
+			break
+		}
+
+		callable, arguments, site = tailCall.Callee.(Callable), tailCall.Arguments, tailCall.CallSite
+		if len(arguments) != callable.Arity() {
+			tErr = runtime.NewRuntimeError(site, diagnostics.ArityMismatch, fmt.Sprintf("expect %d parameters got %d arguments", callable.Arity(), len(arguments)))
+			break
 		}
-		return nil, runtime.NewRuntimeError(expr.Paren, fmt.Sprintf("expect %d parameters got %d arguments", callable.Arity(), len(arguments)))
+		i.frames[len(i.frames)-1].name = callableName(callable)
+		callVal, tErr = i.invoke(callable, arguments, site)
 	}
 
-	callVal, tErr := callable.Call(i, arguments)
 	if tErr != nil {
-		if i.Debug {
-			fmt.Printf("interpreter visit call call value err value %v error %v %v\n", callVal, tErr, tErr)
+		if runtimeErr, ok := tErr.(*runtime.RuntimeError); ok {
+			// natives have no token of their own to put on their errors
+			if runtimeErr.Token.Line == 0 {
+				runtimeErr.Token = site
+			}
+			if runtimeErr.Trace == nil {
+				runtimeErr.Trace = i.stackTrace(runtimeErr.Token)
+			}
 		}
+	}
+	if tErr == nil {
+		i.traceCall(trace.Return, callSite, name, callVal)
+	}
+	i.exitCall()
+	if tErr != nil {
 		return nil, tErr
 	}
 
-	if i.Debug {
-		fmt.Printf("interpreter visit call finished value %v\n", callVal)
-	}
 	return callVal, nil
 }
 
+// invoke runs callable, classes being given the call site for the errors
+// making an instance can raise.
+func (i *Interpreter) invoke(callable Callable, arguments []any, callSite scanner.Token) (any, error) {
+	if class, ok := callable.(Class); ok {
+		return class.instantiate(i, arguments, callSite)
+	}
+	return callable.Call(i, arguments)
+}
+
 func (i *Interpreter) VisitFunctionStmt(stmt parser.Function) (any, error) {
+	if tErr := i.allocate(stmt.Name); tErr != nil {
+		return nil, tErr
+	}
+
 	function := NewLoxFunction(stmt, i.environment, false)
 	i.environment.Define(stmt.Name.Lexeme, function)
+	i.traceEvent(trace.Define, stmt.Name, stmt.Name.Lexeme, function)
 
 	return nil, nil
 }
@@ -321,6 +430,7 @@ func (i *Interpreter) VisitVarDeclaration(stmt parser.VarDeclaration) (any, erro
 	}
 
 	i.environment.Define(stmt.Name.Lexeme, initizlier)
+	i.traceEvent(trace.Define, stmt.Name, stmt.Name.Lexeme, initizlier)
 	return nil, nil
 }
 
@@ -331,9 +441,14 @@ func (i *Interpreter) VisitWhileStmt(stmt parser.WhileStmt) (any, error) {
 	}
 
 	conditionTruthy := i.isTruthy(condition)
+	i.traceBranch(stmt.Keyword, conditionTruthy)
 
 	for conditionTruthy {
-		_, err = stmt.Body.Accept(i)
+		if tErr := i.checkContext(stmt.Keyword); tErr != nil {
+			return nil, tErr
+		}
+
+		err = i.execute(stmt.Body)
 		if err != nil {
 			return nil, err
 		}
@@ -343,6 +458,7 @@ func (i *Interpreter) VisitWhileStmt(stmt parser.WhileStmt) (any, error) {
 			return nil, err
 		}
 		conditionTruthy = i.isTruthy(condition)
+		i.traceBranch(stmt.Keyword, conditionTruthy)
 	}
 	return nil, nil
 }
@@ -354,14 +470,15 @@ func (i *Interpreter) VisitIfStmt(stmt parser.IfStmt) (any, error) {
 		return nil, err
 	}
 	conditionTruthy := i.isTruthy(condition)
+	i.traceBranch(stmt.Keyword, conditionTruthy)
 
 	if conditionTruthy {
-		_, err = stmt.ThenBranch.Accept(i)
+		err = i.execute(stmt.ThenBranch)
 		if err != nil {
 			return nil, err
 		}
 	} else if stmt.ElseBranch != nil {
-		_, err = stmt.ElseBranch.Accept(i)
+		err = i.execute(stmt.ElseBranch)
 		if err != nil {
 			return nil, err
 		}
@@ -389,43 +506,33 @@ func (i *Interpreter) VisitPrintStmt(stmt parser.PrintStmt) (any, error) {
 		return nil, err
 	}
 
-	if val == nil {
-		fmt.Println("nil")
-	} else {
-		fmt.Printf("%v\n", val)
-	}
+	fmt.Fprintln(i.Stdout, Stringify(val))
 	return nil, nil
 }
 
 func (i *Interpreter) VisitSuperExpr(expr parser.Super) (any, error) {
-	dist, ok := i.locals[expr]
+	super, ok := i.locals[expr.ID()]
 	if !ok {
-		return nil, runtime.NewRuntimeError(expr.Keyword, "superclass not found")
-	}
-
-	class, err := i.environment.GetAt(dist, "super")
-	if err != nil {
-		return nil, err
+		return nil, runtime.NewRuntimeError(expr.Keyword, diagnostics.SuperclassNotFound, "superclass not found")
 	}
 
+	class := i.environment.GetAt(super.depth, super.slot)
 	classClass, ok := class.(Class)
 	if !ok {
-		return nil, runtime.NewRuntimeError(expr.Keyword, "superclass not found")
-	}
-
-	instance, err := i.environment.GetAt(dist-1, "this")
-	if err != nil {
-		return nil, err
+		return nil, runtime.NewRuntimeError(expr.Keyword, diagnostics.SuperclassNotFound, "superclass not found")
 	}
 
+	// "this" is the only variable of the scope just inside the one with
+	// "super".
+	instance := i.environment.GetAt(super.depth-1, 0)
 	instanceInstance, ok := instance.(Instance)
 	if !ok {
-		return nil, runtime.NewRuntimeError(expr.Keyword, "instance not found")
+		return nil, runtime.NewRuntimeError(expr.Keyword, diagnostics.SuperInstanceNotFound, "instance not found")
 	}
 
 	method, ok := classClass.FindMethod(expr.Method.Lexeme)
 	if !ok {
-		return nil, runtime.NewRuntimeError(expr.Method, "method not found")
+		return nil, runtime.NewRuntimeError(expr.Method, diagnostics.SuperMethodNotFound, "method not found")
 	}
 
 	return method.Bind(instanceInstance), nil
@@ -439,20 +546,26 @@ func (i *Interpreter) VisitAssignExpr(expr parser.Assign) (any, error) {
 		return nil, err
 	}
 
-	if dist, ok := i.locals[expr]; ok {
-		i.environment.AssignAt(dist, expr.Lexem, val)
+	if local, ok := i.locals[expr.ID()]; ok {
+		i.environment.AssignAt(local.depth, local.slot, val)
+	} else if i.dynamic {
+		tErr := i.environment.Assign(expr.Lexem, val)
+		if tErr != nil {
+			return nil, tErr
+		}
 	} else {
 		tErr := i.globals.Assign(expr.Lexem, val)
 		if tErr != nil {
 			return nil, tErr
 		}
 	}
+	i.traceEvent(trace.Assign, expr.Lexem, expr.Lexem.Lexeme, val)
 
 	return val, nil
 }
 
 func (i *Interpreter) VisitVariableExpr(expr parser.Variable) (any, error) {
-	return i.lookUpVariable(expr.Name, expr)
+	return i.lookUpVariable(expr.Name, expr.ID())
 }
 
 func (i *Interpreter) VisitBinaryExpr(expr parser.Binary) (any, error) {
@@ -505,11 +618,17 @@ func (i *Interpreter) VisitBinaryExpr(expr parser.Binary) (any, error) {
 
 			if strLeft, ok := leftVal.(string); ok {
 				if strRight, ok := rightVal.(string); ok {
+					if tErr := i.checkStringLength(expr.Operator, len(strLeft)+len(strRight)); tErr != nil {
+						return nil, tErr
+					}
+					if tErr := i.allocate(expr.Operator); tErr != nil {
+						return nil, tErr
+					}
 					return strLeft + strRight, nil
 				}
 			}
 
-			return nil, runtime.NewRuntimeError(expr.Operator, "Expect binary operands to be strings")
+			return nil, runtime.NewRuntimeError(expr.Operator, diagnostics.OperandsNotAddable, "Expect binary operands to be strings")
 		}
 	case scanner.GREATER:
 		{
@@ -549,29 +668,15 @@ func (i *Interpreter) VisitBinaryExpr(expr parser.Binary) (any, error) {
 		}
 	case scanner.EQUAL_EQUAL:
 		{
-			if leftVal == nil && rightVal == nil {
-				return true, nil
-			}
-			if leftVal == nil {
-				return false, nil
-			}
-
-			return leftVal == rightVal, nil
+			return Equal(leftVal, rightVal), nil
 		}
 	case scanner.BANG_EQUAL:
 		{
-			if leftVal == nil && rightVal == nil {
-				return false, nil
-			}
-			if leftVal == nil {
-				return true, nil
-			}
-
-			return leftVal != rightVal, nil
+			return !Equal(leftVal, rightVal), nil
 		}
 	default:
 		{
-			return nil, runtime.NewRuntimeError(expr.Operator, "Excpect binray operator to be -, +, *, /")
+			return nil, runtime.NewRuntimeError(expr.Operator, diagnostics.UnknownBinaryOperator, "Excpect binray operator to be -, +, *, /")
 		}
 	}
 }
@@ -584,13 +689,16 @@ func (i *Interpreter) VisitLogicalExpr(expr parser.Logical) (any, error) {
 
 	if expr.Operator.TokenType == scanner.OR {
 		if i.isTruthy(leftVal) {
+			i.traceBranch(expr.Operator, false)
 			return leftVal, nil
 		}
 	} else {
 		if !i.isTruthy(leftVal) {
+			i.traceBranch(expr.Operator, false)
 			return leftVal, nil
 		}
 	}
+	i.traceBranch(expr.Operator, true)
 
 	rightVal, err := i.evaluate(expr.Right)
 	if err != nil {
@@ -621,10 +729,13 @@ func (i *Interpreter) VisitListSet(expr parser.ListSet) (any, error) {
 
 	listList, ok := list.(List)
 	if !ok {
-		return nil, runtime.NewRuntimeError(expr.Token, "only lists support index")
+		return nil, runtime.NewRuntimeError(expr.Token, diagnostics.AssignIndexOnNonList, "only lists support index")
 	}
 
 	index, err := i.valueToInt(expr.Token, indexVal)
+	if err != nil {
+		return nil, err
+	}
 	tErr := listList.Set(index, value)
 	if tErr != nil {
 		return nil, tErr
@@ -645,10 +756,13 @@ func (i *Interpreter) VisitListGet(expr parser.ListGet) (any, error) {
 
 	listList, ok := list.(List)
 	if !ok {
-		return nil, runtime.NewRuntimeError(expr.Token, "only lists support index")
+		return nil, runtime.NewRuntimeError(expr.Token, diagnostics.IndexOnNonList, "only lists support index")
 	}
 
 	index, err := i.valueToInt(expr.Token, indexVal)
+	if err != nil {
+		return nil, err
+	}
 	val, tErr := listList.Get(index)
 	if tErr != nil {
 		return nil, tErr
@@ -657,6 +771,13 @@ func (i *Interpreter) VisitListGet(expr parser.ListGet) (any, error) {
 }
 
 func (i *Interpreter) VisitListExpr(expr parser.ListExpr) (any, error) {
+	if tErr := i.checkListLength(expr.LeftBracket, len(expr.Literals)); tErr != nil {
+		return nil, tErr
+	}
+	if tErr := i.allocate(expr.LeftBracket); tErr != nil {
+		return nil, tErr
+	}
+
 	items := make([]any, len(expr.Literals))
 
 	for j, literal := range expr.Literals {
@@ -679,7 +800,7 @@ func (i *Interpreter) executeBlock(stmts []parser.Stmt, enviroment *runtime.Envi
 	prev := i.environment
 	i.environment = enviroment
 	for _, stmt_ := range stmts {
-		_, err := stmt_.Accept(i)
+		err := i.execute(stmt_)
 		if err != nil {
 			i.environment = prev
 			return err
@@ -714,43 +835,26 @@ func (i *Interpreter) VisitUnaryExpr(expr parser.Unary) (any, error) {
 		}
 	default:
 		{
-			return nil, runtime.NewRuntimeError(expr.Operator, "Expect unary operator to be -, !")
+			return nil, runtime.NewRuntimeError(expr.Operator, diagnostics.UnknownUnaryOperator, "Expect unary operator to be -, !")
 		}
 	}
 }
 
-func (i *Interpreter) lookUpVariable(name scanner.Token, expr parser.Expr) (any, error) {
-	if i.Debug {
-		fmt.Printf("lookup variable name:%s\n", name.Lexeme)
-	}
-	if dist, ok := i.locals[expr]; ok {
-		if i.Debug {
-			fmt.Printf("lookup variable name:%s found dist:%d\n", name.Lexeme, dist)
-		}
-		val, err := i.environment.GetAt(dist, name.Lexeme)
+func (i *Interpreter) lookUpVariable(name scanner.Token, id parser.ID) (any, error) {
+	if local, ok := i.locals[id]; ok {
+		return i.environment.GetAt(local.depth, local.slot), nil
+	} else if i.dynamic {
+		val, err := i.environment.Get(name)
 		if err != nil {
-			if i.Debug {
-				fmt.Printf("lookup variable name:%s found dist:%d get at error %v %T\n", name.Lexeme, dist, err, err)
-			}
 			return nil, err
 		}
-
 		return val, nil
 	} else {
-		if i.Debug {
-			fmt.Printf("lookup variable name:%s look global\n", name.Lexeme)
-		}
 		val, err := i.globals.Get(name)
 		if err != nil {
-			if i.Debug {
-				fmt.Printf("lookup variable name:%s global get error %v %T\n", name.Lexeme, dist, err, err)
-			}
 			return nil, err
 		}
 
-		if i.Debug {
-			fmt.Printf("lookup variable name:%s finshed val %v\n", name.Lexeme, val)
-		}
 		return val, nil
 	}
 }
@@ -758,23 +862,16 @@ func (i *Interpreter) lookUpVariable(name scanner.Token, expr parser.Expr) (any,
 func (i *Interpreter) valueToInt(token scanner.Token, v any) (int, error) {
 	f, ok := v.(float64)
 	if !ok {
-		return 0, runtime.NewRuntimeError(token, "value is not an integer")
+		return 0, runtime.NewRuntimeError(token, diagnostics.IndexNotInteger, "value is not an integer")
 	}
 	if f != float64(int(f)) {
-		return 0, runtime.NewRuntimeError(token, "value is not an integer")
+		return 0, runtime.NewRuntimeError(token, diagnostics.IndexNotInteger, "value is not an integer")
 	}
 	return int(f), nil
 }
 
 func (i *Interpreter) isTruthy(value any) bool {
-	if value == nil {
-		return false
-	}
-	if boolVal, ok := value.(bool); ok {
-		return boolVal
-	}
-
-	return true
+	return Truthy(value)
 }
 
 func (i *Interpreter) checkNumberOperand(operator scanner.Token, operand any) (float64, *runtime.RuntimeError) {
@@ -782,7 +879,7 @@ func (i *Interpreter) checkNumberOperand(operator scanner.Token, operand any) (f
 		return val, nil
 	}
 
-	return 0, runtime.NewRuntimeError(operator, "Expect operands to be numbers")
+	return 0, runtime.NewRuntimeError(operator, diagnostics.OperandNotNumber, "Expect operands to be numbers")
 }
 
 func (i *Interpreter) checkNumberOperands(operator scanner.Token, operandLeft any, operandRight any) (float64, float64, *runtime.RuntimeError) {
@@ -792,5 +889,5 @@ func (i *Interpreter) checkNumberOperands(operator scanner.Token, operandLeft an
 		}
 	}
 
-	return 0, 0, runtime.NewRuntimeError(operator, "Expect operands to be numbers")
+	return 0, 0, runtime.NewRuntimeError(operator, diagnostics.OperandsNotNumbers, "Expect operands to be numbers")
 }
@@ -0,0 +1,98 @@
+package interpreter
+
+import (
+	"fmt"
+
+	"github.com/neet-007/glox/pkg/diagnostics"
+	"github.com/neet-007/glox/pkg/runtime"
+	"github.com/neet-007/glox/pkg/scanner"
+)
+
+// DefaultMaxStackDepth is how deep lox calls may nest before the interpreter
+// reports a stack overflow, well before the go runtime runs out of stack.
+const DefaultMaxStackDepth = 10000
+
+// Limits bounds the work a script may do so untrusted code can be run
+// safely. A zero field means no limit.
+type Limits struct {
+	MaxSteps        int
+	MaxCallDepth    int
+	MaxListLength   int
+	MaxStringLength int
+	MaxAllocations  int
+}
+
+type LimitError struct {
+	Limit string
+	Max   int
+}
+
+func (l *LimitError) Error() string {
+	return fmt.Sprintf("Limit exceeded: %s (%d)", l.Limit, l.Max)
+}
+
+func (i *Interpreter) limitError(token scanner.Token, limit string, max int) *runtime.RuntimeError {
+	err := &LimitError{
+		Limit: limit,
+		Max:   max,
+	}
+	return runtime.WrapRuntimeError(token, diagnostics.LimitExceeded, err.Error(), err)
+}
+
+func (i *Interpreter) step(token scanner.Token) *runtime.RuntimeError {
+	i.steps++
+	if i.Limits.MaxSteps > 0 && i.steps > i.Limits.MaxSteps {
+		return i.limitError(token, "max steps", i.Limits.MaxSteps)
+	}
+
+	return nil
+}
+
+func (i *Interpreter) allocate(token scanner.Token) *runtime.RuntimeError {
+	i.allocations++
+	if i.Limits.MaxAllocations > 0 && i.allocations > i.Limits.MaxAllocations {
+		return i.limitError(token, "max allocations", i.Limits.MaxAllocations)
+	}
+
+	return nil
+}
+
+func (i *Interpreter) checkListLength(token scanner.Token, length int) *runtime.RuntimeError {
+	if i.Limits.MaxListLength > 0 && length > i.Limits.MaxListLength {
+		return i.limitError(token, "max list length", i.Limits.MaxListLength)
+	}
+
+	return nil
+}
+
+func (i *Interpreter) checkStringLength(token scanner.Token, length int) *runtime.RuntimeError {
+	if i.Limits.MaxStringLength > 0 && length > i.Limits.MaxStringLength {
+		return i.limitError(token, "max string length", i.Limits.MaxStringLength)
+	}
+
+	return nil
+}
+
+func (i *Interpreter) checkCallDepth(token scanner.Token) *runtime.RuntimeError {
+	var err *runtime.RuntimeError
+	if i.MaxStackDepth > 0 && len(i.frames) >= i.MaxStackDepth {
+		err = runtime.NewRuntimeError(token, diagnostics.StackOverflow, "Stack overflow.")
+	} else if i.Limits.MaxCallDepth > 0 && len(i.frames) >= i.Limits.MaxCallDepth {
+		err = i.limitError(token, "max call depth", i.Limits.MaxCallDepth)
+	}
+
+	// a script that only runs deep because of its tail calls fails once it
+	// is traced, profiled, covered or debugged, which would be a surprise
+	if err != nil && i.tailCallsSuspended() {
+		err.Notes = append(err.Notes, tailCallsSuspendedNote)
+	}
+	return err
+}
+
+const tailCallsSuspendedNote = "calls in tail position keep their frames while the script is traced, profiled, covered or debugged"
+
+// tailCallsSuspended reports whether TailCalls is set but has no effect, a
+// Tracer or Hook needing every call on the stack.
+func (i *Interpreter) tailCallsSuspended() bool {
+	return i.TailCalls && (i.Tracer != nil || i.Hook != nil)
+}
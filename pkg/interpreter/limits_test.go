@@ -0,0 +1,163 @@
+package interpreter_test
+
+import (
+	"errors"
+	"io"
+	"testing"
+
+	"github.com/neet-007/glox/pkg/diagnostics"
+	"github.com/neet-007/glox/pkg/interpreter"
+	"github.com/neet-007/glox/pkg/parser"
+	"github.com/neet-007/glox/pkg/resolver"
+	"github.com/neet-007/glox/pkg/runtime"
+	"github.com/neet-007/glox/pkg/scanner"
+)
+
+// run runs source on a new interpreter that setup has been given first.
+func run(t *testing.T, source string, setup func(*interpreter.Interpreter)) *runtime.RuntimeError {
+	t.Helper()
+	interpreter_, stmts := compile(t, source, setup)
+	return interpreter_.Interpret(stmts)
+}
+
+// compile parses and resolves source for a new interpreter that setup has
+// been given first.
+func compile(t *testing.T, source string, setup func(*interpreter.Interpreter)) (*interpreter.Interpreter, []parser.Stmt) {
+	t.Helper()
+	tokens, errs := scanner.NewFileScanner("test.lox", []byte(source), false).Scan()
+	if len(errs) > 0 {
+		t.Fatalf("scan: %v", errs[0])
+	}
+	stmts, errs := parser.NewParser(tokens, false).Parse()
+	if len(errs) > 0 {
+		t.Fatalf("parse: %v", errs[0])
+	}
+
+	interpreter_ := interpreter.NewInterpreter()
+	interpreter_.Stdout = io.Discard
+	if setup != nil {
+		setup(interpreter_)
+	}
+	resolver_ := resolver.NewResolver(interpreter_)
+	resolver_.Tracer = interpreter_.Tracer
+	for _, err := range resolver_.Resolve(stmts) {
+		if err.Severity == diagnostics.Error {
+			t.Fatalf("resolve: %v", err)
+		}
+	}
+	return interpreter_, stmts
+}
+
+func TestLimits(t *testing.T) {
+	tests := []struct {
+		name   string
+		limits interpreter.Limits
+		source string
+		limit  string
+		// line is where the limit is hit, 0 for any line of the script.
+		line int
+	}{
+		{
+			name:   "steps",
+			limits: interpreter.Limits{MaxSteps: 10},
+			source: "var i = 0;\nwhile (true) {\n  i = i + 1;\n}\n",
+			limit:  "max steps",
+		},
+		{
+			name:   "allocations",
+			limits: interpreter.Limits{MaxAllocations: 2},
+			source: "class A {}\nvar a = A();\nvar b = A();\n",
+			limit:  "max allocations",
+			line:   3,
+		},
+		{
+			name:   "list length",
+			limits: interpreter.Limits{MaxListLength: 2},
+			source: "var a = [1, 2];\nvar b = [1, 2, 3];\n",
+			limit:  "max list length",
+			line:   2,
+		},
+		{
+			name:   "string length",
+			limits: interpreter.Limits{MaxStringLength: 4},
+			source: "var a = \"ab\" + \"cd\";\nvar b = a + \"e\";\n",
+			limit:  "max string length",
+			line:   2,
+		},
+		{
+			name:   "call depth",
+			limits: interpreter.Limits{MaxCallDepth: 5},
+			source: "fun f(n) {\n  if (n == 0) return 0;\n  return 1 + f(n - 1);\n}\nf(3);\nf(10);\n",
+			limit:  "max call depth",
+			line:   3,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			err := run(t, test.source, func(i *interpreter.Interpreter) {
+				i.Limits = test.limits
+			})
+			if err == nil {
+				t.Fatal("ran to the end, want a limit error")
+			}
+			var limitErr *interpreter.LimitError
+			if !errors.As(err, &limitErr) {
+				t.Fatalf("got %v, want a *LimitError", err)
+			}
+			if limitErr.Limit != test.limit {
+				t.Errorf("got limit %q, want %q", limitErr.Limit, test.limit)
+			}
+			if err.Token.Line == 0 || test.line != 0 && err.Token.Line != test.line {
+				t.Errorf("got line %d, want %d", err.Token.Line, test.line)
+			}
+		})
+	}
+}
+
+func TestLimitsUnderMax(t *testing.T) {
+	limits := interpreter.Limits{
+		MaxSteps:        100,
+		MaxAllocations:  10,
+		MaxListLength:   3,
+		MaxStringLength: 10,
+		MaxCallDepth:    10,
+	}
+	source := `
+class A {}
+var a = A();
+var list = [1, 2, 3];
+var s = "hello" + "world";
+fun f(n) { if (n == 0) return 0; return 1 + f(n - 1); }
+f(5);
+`
+	if err := run(t, source, func(i *interpreter.Interpreter) { i.Limits = limits }); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestInstanceAllocationPointsAtCall(t *testing.T) {
+	source := "class A {}\nfun make() {\n  return A();\n}\nmake();\nmake();\n"
+	err := run(t, source, func(i *interpreter.Interpreter) {
+		i.Limits.MaxAllocations = 3
+	})
+	if err == nil {
+		t.Fatal("ran to the end, want a limit error")
+	}
+	if err.Token.Line != 3 || err.Token.Lexeme != ")" {
+		t.Errorf("got %q at line %d, want the call's paren at line 3", err.Token.Lexeme, err.Token.Line)
+	}
+}
+
+func TestDefineChecksListLength(t *testing.T) {
+	interpreter_ := interpreter.NewInterpreter()
+	interpreter_.Limits.MaxListLength = 2
+
+	if err := interpreter_.Define("short", []int{1, 2}); err != nil {
+		t.Errorf("short list: %v", err)
+	}
+	var marshalErr *interpreter.MarshalError
+	if err := interpreter_.Define("long", map[string][]int{"items": {1, 2, 3}}); !errors.As(err, &marshalErr) {
+		t.Errorf("got %v, want a *MarshalError", err)
+	}
+}
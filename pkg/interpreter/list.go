@@ -3,6 +3,7 @@ package interpreter
 import (
 	"fmt"
 
+	"github.com/neet-007/glox/pkg/diagnostics"
 	"github.com/neet-007/glox/pkg/parser"
 	"github.com/neet-007/glox/pkg/runtime"
 )
@@ -20,16 +21,16 @@ func NewList(list parser.ListExpr, items []any) List {
 }
 
 func (l List) Get(i int) (any, *runtime.RuntimeError) {
-	if i >= len(l.items) {
-		return nil, runtime.NewRuntimeError(l.list.LeftBracket, fmt.Sprintf("index out of bound index %d length %d", i, len(l.items)))
+	if i < 0 || i >= len(l.items) {
+		return nil, runtime.NewRuntimeError(l.list.LeftBracket, diagnostics.IndexOutOfBounds, fmt.Sprintf("index out of bound index %d length %d", i, len(l.items)))
 	}
 
 	return l.items[i], nil
 }
 
 func (l List) Set(i int, value any) *runtime.RuntimeError {
-	if i >= len(l.items) {
-		return runtime.NewRuntimeError(l.list.LeftBracket, fmt.Sprintf("index out of bound index %d length %d", i, len(l.items)))
+	if i < 0 || i >= len(l.items) {
+		return runtime.NewRuntimeError(l.list.LeftBracket, diagnostics.IndexOutOfBounds, fmt.Sprintf("index out of bound index %d length %d", i, len(l.items)))
 	}
 
 	l.items[i] = value
@@ -37,7 +38,9 @@ func (l List) Set(i int, value any) *runtime.RuntimeError {
 	return nil
 }
 
-func (l List) Append(value any) {
+// Append adds value to the end of l, for lists built in Go before they are
+// handed to a script.
+func (l *List) Append(value any) {
 	l.items = append(l.items, value)
 }
 
@@ -0,0 +1,535 @@
+package interpreter
+
+import (
+	"fmt"
+	"math"
+	"reflect"
+	"strings"
+
+	"github.com/neet-007/glox/pkg/parser"
+	"github.com/neet-007/glox/pkg/scanner"
+)
+
+/*
+ NOTE:
+	struct fields are named by the `lox` tag when present, otherwise by the
+	go field name. a tag of "-" skips the field and ",omitempty" drops zero
+	values when converting to lox. go maps become instances of the "map"
+	class with one field per key.
+*/
+
+const mapClassName = "map"
+
+type MarshalError struct {
+	Path    string
+	Message string
+}
+
+func newMarshalError(path string, message string) *MarshalError {
+	return &MarshalError{
+		Path:    path,
+		Message: message,
+	}
+}
+
+func (m *MarshalError) Error() string {
+	path := strings.TrimPrefix(m.Path, ".")
+	if path == "" {
+		path = "(root)"
+	}
+	return fmt.Sprintf("lox: %s at %s", m.Message, path)
+}
+
+// ToLox converts a go value into the value the interpreter uses for it.
+func ToLox(v any) (any, error) {
+	return toLox("", reflect.ValueOf(v), map[visit]bool{})
+}
+
+// FromLox stores a lox value into the go value pointed to by out.
+func FromLox(value any, out any) error {
+	rv := reflect.ValueOf(out)
+	if rv.Kind() != reflect.Pointer || rv.IsNil() {
+		return newMarshalError("", fmt.Sprintf("expect a non-nil pointer got %T", out))
+	}
+
+	return fromLox("", value, rv.Elem(), map[visit]bool{})
+}
+
+// Define converts v with ToLox and binds it to a global variable. A list in
+// v longer than Limits.MaxListLength gives a *MarshalError.
+func (i *Interpreter) Define(name string, v any) error {
+	value, err := ToLox(v)
+	if err != nil {
+		return err
+	}
+	if err := i.checkListLengths(name, value, map[visit]bool{}); err != nil {
+		return err
+	}
+
+	i.globals.Define(name, value)
+	return nil
+}
+
+// Global reads a global variable into the go value pointed to by out.
+func (i *Interpreter) Global(name string, out any) error {
+	value, err := i.globals.Get(scanner.Token{TokenType: scanner.IDENTIFIER, Lexeme: name})
+	if err != nil {
+		return err
+	}
+
+	return FromLox(value, out)
+}
+
+// checkListLengths holds the lists in value, as made by ToLox, to
+// Limits.MaxListLength.
+func (i *Interpreter) checkListLengths(path string, value any, seen map[visit]bool) error {
+	switch value := value.(type) {
+	case List:
+		key, ok := enter(reflect.ValueOf(value.items), seen)
+		if !ok {
+			return nil
+		}
+		defer delete(seen, key)
+		if i.Limits.MaxListLength > 0 && len(value.items) > i.Limits.MaxListLength {
+			return newMarshalError(path, fmt.Sprintf("list of length %d is over the max list length (%d)", len(value.items), i.Limits.MaxListLength))
+		}
+		for j, item := range value.items {
+			if err := i.checkListLengths(fmt.Sprintf("%s[%d]", path, j), item, seen); err != nil {
+				return err
+			}
+		}
+	case Instance:
+		key, ok := enter(reflect.ValueOf(value.fields), seen)
+		if !ok {
+			return nil
+		}
+		defer delete(seen, key)
+		for key, item := range value.fields {
+			if err := i.checkListLengths(path+"."+key, item, seen); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+type structField struct {
+	name      string
+	index     []int
+	omitEmpty bool
+}
+
+func structFields(t reflect.Type) []structField {
+	fields := []structField{}
+
+	for j := range t.NumField() {
+		field := t.Field(j)
+		tag := field.Tag.Get("lox")
+		if tag == "-" {
+			continue
+		}
+
+		name, options, _ := strings.Cut(tag, ",")
+		if field.Anonymous && name == "" {
+			fieldType := field.Type
+			if fieldType.Kind() == reflect.Pointer {
+				fieldType = fieldType.Elem()
+			}
+			if fieldType.Kind() == reflect.Struct {
+				for _, embedded := range structFields(fieldType) {
+					embedded.index = append([]int{j}, embedded.index...)
+					fields = append(fields, embedded)
+				}
+				continue
+			}
+		}
+		if !field.IsExported() {
+			continue
+		}
+
+		if name == "" {
+			name = field.Name
+		}
+
+		fields = append(fields, structField{
+			name:      name,
+			index:     []int{j},
+			omitEmpty: options == "omitempty",
+		})
+	}
+
+	return fields
+}
+
+// fieldByIndex follows index through embedded structs, false when it runs
+// into a nil pointer it does not allocate or, as the pointer is to an
+// unexported struct, cannot.
+func fieldByIndex(v reflect.Value, index []int, allocate bool) (reflect.Value, bool) {
+	for j, x := range index {
+		if j > 0 && v.Kind() == reflect.Pointer {
+			if v.IsNil() {
+				if !allocate || !v.CanSet() {
+					return reflect.Value{}, false
+				}
+				v.Set(reflect.New(v.Type().Elem()))
+			}
+			v = v.Elem()
+		}
+		v = v.Field(x)
+	}
+
+	return v, true
+}
+
+// visit is a pointer, map or slice being converted, kept to catch values
+// that contain themselves. lists and instances are kept by their items and
+// fields.
+type visit struct {
+	pointer uintptr
+	t       reflect.Type
+}
+
+// enter marks v as being converted, false when it already is and converting
+// it again would never end.
+func enter(v reflect.Value, seen map[visit]bool) (visit, bool) {
+	key := visit{pointer: v.Pointer(), t: v.Type()}
+	if seen[key] {
+		return key, false
+	}
+	seen[key] = true
+	return key, true
+}
+
+func cycleError(path string, value any) error {
+	return newMarshalError(path, fmt.Sprintf("cycle through %s", loxTypeName(value)))
+}
+
+func toLox(path string, v reflect.Value, seen map[visit]bool) (any, error) {
+	if !v.IsValid() {
+		return nil, nil
+	}
+
+	if v.CanInterface() {
+		switch value := v.Interface().(type) {
+		case Instance, List, Class, Callable:
+			return value, nil
+		}
+	}
+
+	switch v.Kind() {
+	case reflect.Bool:
+		return v.Bool(), nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return float64(v.Int()), nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return float64(v.Uint()), nil
+	case reflect.Float32, reflect.Float64:
+		return v.Float(), nil
+	case reflect.String:
+		return v.String(), nil
+	case reflect.Pointer, reflect.Interface:
+		if v.IsNil() {
+			return nil, nil
+		}
+		if v.Kind() == reflect.Pointer {
+			key, ok := enter(v, seen)
+			if !ok {
+				return nil, newMarshalError(path, fmt.Sprintf("cycle through %s", v.Type()))
+			}
+			defer delete(seen, key)
+		}
+		return toLox(path, v.Elem(), seen)
+	case reflect.Slice, reflect.Array:
+		if v.Kind() == reflect.Slice {
+			if v.IsNil() {
+				return nil, nil
+			}
+			key, ok := enter(v, seen)
+			if !ok {
+				return nil, newMarshalError(path, fmt.Sprintf("cycle through %s", v.Type()))
+			}
+			defer delete(seen, key)
+		}
+		items := make([]any, v.Len())
+		for j := range v.Len() {
+			item, err := toLox(fmt.Sprintf("%s[%d]", path, j), v.Index(j), seen)
+			if err != nil {
+				return nil, err
+			}
+			items[j] = item
+		}
+		return NewList(parser.ListExpr{}, items), nil
+	case reflect.Map:
+		if v.IsNil() {
+			return nil, nil
+		}
+		if v.Type().Key().Kind() != reflect.String {
+			return nil, newMarshalError(path, fmt.Sprintf("unsupported map key type %s", v.Type().Key()))
+		}
+		key, ok := enter(v, seen)
+		if !ok {
+			return nil, newMarshalError(path, fmt.Sprintf("cycle through %s", v.Type()))
+		}
+		defer delete(seen, key)
+		instance := NewInstance(NewLoxClass(mapClassName, map[string]LoxFunction{}, nil))
+		iter := v.MapRange()
+		for iter.Next() {
+			key := iter.Key().String()
+			item, err := toLox(path+"."+key, iter.Value(), seen)
+			if err != nil {
+				return nil, err
+			}
+			instance.fields[key] = item
+		}
+		return instance, nil
+	case reflect.Struct:
+		instance := NewInstance(NewLoxClass(v.Type().Name(), map[string]LoxFunction{}, nil))
+		for _, field := range structFields(v.Type()) {
+			fieldValue, ok := fieldByIndex(v, field.index, false)
+			if !ok {
+				continue
+			}
+			if field.omitEmpty && fieldValue.IsZero() {
+				continue
+			}
+			item, err := toLox(path+"."+field.name, fieldValue, seen)
+			if err != nil {
+				return nil, err
+			}
+			instance.fields[field.name] = item
+		}
+		return instance, nil
+	default:
+		return nil, newMarshalError(path, fmt.Sprintf("unsupported go type %s", v.Type()))
+	}
+}
+
+func fromLox(path string, value any, v reflect.Value, seen map[visit]bool) error {
+	if v.Kind() == reflect.Pointer {
+		if value == nil {
+			v.SetZero()
+			return nil
+		}
+		if v.IsNil() {
+			v.Set(reflect.New(v.Type().Elem()))
+		}
+		return fromLox(path, value, v.Elem(), seen)
+	}
+
+	if v.Kind() == reflect.Interface && v.NumMethod() == 0 {
+		plain, err := plainValue(path, value, seen)
+		if err != nil {
+			return err
+		}
+		if plain == nil {
+			v.SetZero()
+			return nil
+		}
+		v.Set(reflect.ValueOf(plain))
+		return nil
+	}
+
+	typeErr := func() error {
+		return newMarshalError(path, fmt.Sprintf("cannot convert %s into go value of type %s", loxTypeName(value), v.Type()))
+	}
+
+	switch v.Kind() {
+	case reflect.Bool:
+		b, ok := value.(bool)
+		if !ok {
+			return typeErr()
+		}
+		v.SetBool(b)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		f, ok := value.(float64)
+		if !ok {
+			return typeErr()
+		}
+		// f is checked against the kind's range before it is converted, as
+		// converting a float64 that does not fit is implementation defined.
+		limit := math.Ldexp(1, v.Type().Bits()-1)
+		if f != math.Trunc(f) || f < -limit || f >= limit {
+			return newMarshalError(path, fmt.Sprintf("number %v does not fit in go value of type %s", f, v.Type()))
+		}
+		v.SetInt(int64(f))
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		f, ok := value.(float64)
+		if !ok {
+			return typeErr()
+		}
+		if f != math.Trunc(f) || f < 0 || f >= math.Ldexp(1, v.Type().Bits()) {
+			return newMarshalError(path, fmt.Sprintf("number %v does not fit in go value of type %s", f, v.Type()))
+		}
+		v.SetUint(uint64(f))
+	case reflect.Float32, reflect.Float64:
+		f, ok := value.(float64)
+		if !ok {
+			return typeErr()
+		}
+		v.SetFloat(f)
+	case reflect.String:
+		s, ok := value.(string)
+		if !ok {
+			return typeErr()
+		}
+		v.SetString(s)
+	case reflect.Slice:
+		if value == nil {
+			v.SetZero()
+			return nil
+		}
+		list, ok := value.(List)
+		if !ok {
+			return typeErr()
+		}
+		key, ok := enter(reflect.ValueOf(list.items), seen)
+		if !ok {
+			return cycleError(path, list)
+		}
+		defer delete(seen, key)
+		slice := reflect.MakeSlice(v.Type(), len(list.items), len(list.items))
+		for j, item := range list.items {
+			if err := fromLox(fmt.Sprintf("%s[%d]", path, j), item, slice.Index(j), seen); err != nil {
+				return err
+			}
+		}
+		v.Set(slice)
+	case reflect.Array:
+		list, ok := value.(List)
+		if !ok {
+			return typeErr()
+		}
+		key, ok := enter(reflect.ValueOf(list.items), seen)
+		if !ok {
+			return cycleError(path, list)
+		}
+		defer delete(seen, key)
+		if len(list.items) > v.Len() {
+			return newMarshalError(path, fmt.Sprintf("list of length %d does not fit in go value of type %s", len(list.items), v.Type()))
+		}
+		for j, item := range list.items {
+			if err := fromLox(fmt.Sprintf("%s[%d]", path, j), item, v.Index(j), seen); err != nil {
+				return err
+			}
+		}
+		for j := len(list.items); j < v.Len(); j++ {
+			v.Index(j).SetZero()
+		}
+	case reflect.Map:
+		if value == nil {
+			v.SetZero()
+			return nil
+		}
+		instance, ok := value.(Instance)
+		if !ok {
+			return typeErr()
+		}
+		if v.Type().Key().Kind() != reflect.String {
+			return newMarshalError(path, fmt.Sprintf("unsupported map key type %s", v.Type().Key()))
+		}
+		key, ok := enter(reflect.ValueOf(instance.fields), seen)
+		if !ok {
+			return cycleError(path, instance)
+		}
+		defer delete(seen, key)
+		if v.IsNil() {
+			v.Set(reflect.MakeMapWithSize(v.Type(), len(instance.fields)))
+		}
+		for key, item := range instance.fields {
+			elem := reflect.New(v.Type().Elem()).Elem()
+			if err := fromLox(path+"."+key, item, elem, seen); err != nil {
+				return err
+			}
+			v.SetMapIndex(reflect.ValueOf(key).Convert(v.Type().Key()), elem)
+		}
+	case reflect.Struct:
+		instance, ok := value.(Instance)
+		if !ok {
+			return typeErr()
+		}
+		key, ok := enter(reflect.ValueOf(instance.fields), seen)
+		if !ok {
+			return cycleError(path, instance)
+		}
+		defer delete(seen, key)
+		for _, field := range structFields(v.Type()) {
+			item, ok := instance.fields[field.name]
+			if !ok {
+				continue
+			}
+			fieldValue, ok := fieldByIndex(v, field.index, true)
+			if !ok {
+				return newMarshalError(path+"."+field.name, fmt.Sprintf("cannot allocate the unexported embedded struct of go value of type %s", v.Type()))
+			}
+			if err := fromLox(path+"."+field.name, item, fieldValue, seen); err != nil {
+				return err
+			}
+		}
+	default:
+		return newMarshalError(path, fmt.Sprintf("unsupported go type %s", v.Type()))
+	}
+
+	return nil
+}
+
+func plainValue(path string, value any, seen map[visit]bool) (any, error) {
+	switch value := value.(type) {
+	case nil, bool, float64, string:
+		return value, nil
+	case List:
+		key, ok := enter(reflect.ValueOf(value.items), seen)
+		if !ok {
+			return nil, cycleError(path, value)
+		}
+		defer delete(seen, key)
+		items := make([]any, len(value.items))
+		for j, item := range value.items {
+			plain, err := plainValue(fmt.Sprintf("%s[%d]", path, j), item, seen)
+			if err != nil {
+				return nil, err
+			}
+			items[j] = plain
+		}
+		return items, nil
+	case Instance:
+		key, ok := enter(reflect.ValueOf(value.fields), seen)
+		if !ok {
+			return nil, cycleError(path, value)
+		}
+		defer delete(seen, key)
+		fields := make(map[string]any, len(value.fields))
+		for key, item := range value.fields {
+			plain, err := plainValue(path+"."+key, item, seen)
+			if err != nil {
+				return nil, err
+			}
+			fields[key] = plain
+		}
+		return fields, nil
+	default:
+		return value, nil
+	}
+}
+
+func loxTypeName(value any) string {
+	switch value := value.(type) {
+	case nil:
+		return "nil"
+	case bool:
+		return "boolean"
+	case float64:
+		return "number"
+	case string:
+		return "string"
+	case List:
+		return "list"
+	case Instance:
+		return value.class.Name + " instance"
+	case Class:
+		return "class " + value.Name
+	case Callable:
+		return "function"
+	default:
+		return fmt.Sprintf("%T", value)
+	}
+}
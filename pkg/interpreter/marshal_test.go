@@ -0,0 +1,265 @@
+package interpreter
+
+import (
+	"errors"
+	"math"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/neet-007/glox/pkg/parser"
+)
+
+type point struct {
+	X    int     `lox:"x"`
+	Y    float64 `lox:"y"`
+	Name string  `lox:",omitempty"`
+	Skip bool    `lox:"-"`
+}
+
+type shape struct {
+	point
+	Points []point
+	Tags   map[string]string
+	Next   *shape
+}
+
+func TestRoundTrip(t *testing.T) {
+	tests := []struct {
+		name string
+		in   any
+	}{
+		{"bool", true},
+		{"int", -42},
+		{"uint8", uint8(255)},
+		{"float", 1.5},
+		{"string", "lox"},
+		{"slice", []string{"a", "b"}},
+		{"array", [3]int{1, 2, 3}},
+		{"map", map[string]float64{"a": 1, "b": 2}},
+		{"struct", point{X: 1, Y: 2.5, Name: "p"}},
+		{"nested", shape{
+			point:  point{X: 1},
+			Points: []point{{X: 2}, {Y: 3}},
+			Tags:   map[string]string{"k": "v"},
+			Next:   &shape{point: point{X: 4}},
+		}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			value, err := ToLox(test.in)
+			if err != nil {
+				t.Fatalf("ToLox: %v", err)
+			}
+			out := reflect.New(reflect.TypeOf(test.in))
+			if err := FromLox(value, out.Interface()); err != nil {
+				t.Fatalf("FromLox: %v", err)
+			}
+			if got := out.Elem().Interface(); !reflect.DeepEqual(got, test.in) {
+				t.Errorf("got %#v, want %#v", got, test.in)
+			}
+		})
+	}
+}
+
+func TestFromLoxArrayZeroesTrailingElements(t *testing.T) {
+	out := [3]int{7, 8, 9}
+	if err := FromLox(list(1.0), &out); err != nil {
+		t.Fatal(err)
+	}
+	if want := [3]int{1, 0, 0}; out != want {
+		t.Errorf("got %v, want %v", out, want)
+	}
+}
+
+func TestFromLoxErrors(t *testing.T) {
+	tests := []struct {
+		name  string
+		value any
+		out   any
+		path  string
+	}{
+		{"not a pointer", 1.0, 0, "(root)"},
+		{"wrong type", "a", new(int), "(root)"},
+		{"fraction", 1.5, new(int), "(root)"},
+		{"int8 overflow", 128.0, new(int8), "(root)"},
+		{"int8 underflow", -129.0, new(int8), "(root)"},
+		{"int64 overflow", 1e19, new(int64), "(root)"},
+		{"int64 max", math.Ldexp(1, 63), new(int64), "(root)"},
+		{"uint negative", -1.0, new(uint), "(root)"},
+		{"uint64 overflow", 2e19, new(uint64), "(root)"},
+		{"array too short", list(1.0, 2.0), new([1]int), "(root)"},
+		{"list element", list(1.0, "b"), new([]int), "[1]"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			err := FromLox(test.value, test.out)
+			var marshalErr *MarshalError
+			if !errors.As(err, &marshalErr) {
+				t.Fatalf("got %v, want a *MarshalError", err)
+			}
+			if path := marshalErr.Error(); !strings.HasSuffix(path, test.path) {
+				t.Errorf("error %q does not point at %s", path, test.path)
+			}
+		})
+	}
+}
+
+func TestFromLoxIntLimits(t *testing.T) {
+	var small int8
+	if err := FromLox(-128.0, &small); err != nil || small != math.MinInt8 {
+		t.Errorf("got %v, %v, want %d", small, err, math.MinInt8)
+	}
+	var large int64
+	if err := FromLox(-math.Ldexp(1, 63), &large); err != nil || large != math.MinInt64 {
+		t.Errorf("got %v, %v, want %d", large, err, int64(math.MinInt64))
+	}
+}
+
+func TestToLoxErrors(t *testing.T) {
+	type node struct {
+		Next *node
+	}
+	loop := &node{}
+	loop.Next = loop
+
+	cyclicMap := map[string]any{}
+	cyclicMap["self"] = cyclicMap
+
+	cyclicSlice := []any{nil}
+	cyclicSlice[0] = cyclicSlice
+
+	shared := &point{X: 1}
+
+	tests := []struct {
+		name string
+		in   any
+		ok   bool
+	}{
+		{"channel", make(chan int), false},
+		{"int keys", map[int]string{1: "a"}, false},
+		{"pointer cycle", loop, false},
+		{"map cycle", cyclicMap, false},
+		{"slice cycle", cyclicSlice, false},
+		{"shared pointer", []*point{shared, shared}, true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			_, err := ToLox(test.in)
+			if test.ok {
+				if err != nil {
+					t.Errorf("unexpected error: %v", err)
+				}
+				return
+			}
+			var marshalErr *MarshalError
+			if !errors.As(err, &marshalErr) {
+				t.Errorf("got %v, want a *MarshalError", err)
+			}
+		})
+	}
+}
+
+func list(items ...any) List {
+	return NewList(parser.ListExpr{}, items)
+}
+
+func TestFromLoxCycles(t *testing.T) {
+	// var a = A(); a.self = a;
+	instance := NewInstance(NewLoxClass("A", map[string]LoxFunction{}, nil))
+	instance.fields["self"] = instance
+
+	// var l = [1, nil]; l[1] = l;
+	cyclic := list(1.0, nil)
+	cyclic.items[1] = cyclic
+
+	shared := list(1.0)
+
+	type node struct {
+		Self *node `lox:"self"`
+	}
+
+	tests := []struct {
+		name  string
+		value any
+		out   any
+		path  string
+	}{
+		{"instance into any", instance, new(any), "self"},
+		{"instance into map", instance, new(map[string]any), "self"},
+		{"instance into struct", instance, new(node), "self"},
+		{"list into any", cyclic, new(any), "[1]"},
+		{"list into slice", cyclic, new([]any), "[1]"},
+		{"list into array", cyclic, new([2]any), "[1]"},
+		{"nested", list(list(instance)), new(any), "[0][0].self"},
+		{"shared list", list(shared, shared), new(any), ""},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			err := FromLox(test.value, test.out)
+			if test.path == "" {
+				if err != nil {
+					t.Errorf("unexpected error: %v", err)
+				}
+				return
+			}
+			var marshalErr *MarshalError
+			if !errors.As(err, &marshalErr) {
+				t.Fatalf("got %v, want a *MarshalError", err)
+			}
+			if marshalErr.Path != test.path && marshalErr.Path != "."+test.path {
+				t.Errorf("got the cycle at %q, want %s", marshalErr.Path, test.path)
+			}
+		})
+	}
+}
+
+func TestDefineCycles(t *testing.T) {
+	instance := NewInstance(NewLoxClass("A", map[string]LoxFunction{}, nil))
+	instance.fields["self"] = instance
+
+	interpreter := NewInterpreter()
+	if err := interpreter.Define("a", instance); err != nil {
+		t.Fatal(err)
+	}
+	var out any
+	var marshalErr *MarshalError
+	if err := interpreter.Global("a", &out); !errors.As(err, &marshalErr) {
+		t.Errorf("got %v, want a *MarshalError", err)
+	}
+}
+
+type hidden struct {
+	X int `lox:"x"`
+}
+
+type exposed struct {
+	*hidden
+	Y int `lox:"y"`
+}
+
+func TestFromLoxUnexportedEmbeddedPointer(t *testing.T) {
+	instance := NewInstance(NewLoxClass("exposed", map[string]LoxFunction{}, nil))
+	instance.fields["x"] = 1.0
+	instance.fields["y"] = 2.0
+
+	var out exposed
+	err := FromLox(instance, &out)
+	var marshalErr *MarshalError
+	if !errors.As(err, &marshalErr) || marshalErr.Path != ".x" {
+		t.Fatalf("got %v, want a *MarshalError at x", err)
+	}
+
+	// with the struct there, its fields can be set
+	out = exposed{hidden: &hidden{}}
+	if err := FromLox(instance, &out); err != nil {
+		t.Fatal(err)
+	}
+	if out.X != 1 || out.Y != 2 {
+		t.Errorf("got %+v, want x = 1 and y = 2", out)
+	}
+}
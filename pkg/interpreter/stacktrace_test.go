@@ -0,0 +1,130 @@
+package interpreter_test
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/neet-007/glox/pkg/interpreter"
+)
+
+func TestStackTrace(t *testing.T) {
+	tests := []struct {
+		name      string
+		source    string
+		tailCalls bool
+		want      []string
+	}{
+		{
+			name: "methods",
+			source: `class A {
+  init(n) {
+    this.n = n;
+  }
+  get() {
+    return check(this.n);
+  }
+}
+fun check(n) {
+  if (n > 1) {
+    return n - nil;
+  }
+  return n;
+}
+fun make(n) {
+  var a = A(n);
+  return a.get();
+}
+make(1);
+make(2);
+`,
+			want: []string{"at check() line 11", "at get() line 6", "at make() line 17", "at main line 20"},
+		},
+		{
+			name: "tail calls",
+			source: `fun check(n) {
+  return n - nil;
+}
+fun get(n) {
+  return check(n);
+}
+fun make(n) {
+  var a = get(n);
+  return a;
+}
+make(1);
+`,
+			tailCalls: true,
+			want:      []string{"at check() line 2", "at make() line 8", "at main line 11"},
+		},
+		{
+			name: "initializer",
+			source: `class A {
+  init(n) {
+    this.n = n * "x";
+  }
+}
+fun make(n) {
+  var a = A(n);
+  return a;
+}
+make(1);
+`,
+			want: []string{"at A() line 3", "at make() line 7", "at main line 10"},
+		},
+		{
+			name:   "top level",
+			source: "print 1;\nprint -nil;\n",
+			want:   []string{"at main line 2"},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			err := run(t, test.source, func(i *interpreter.Interpreter) {
+				i.TailCalls = test.tailCalls
+			})
+			if err == nil {
+				t.Fatal("got no error")
+			}
+			got := []string{}
+			for _, frame := range err.Trace {
+				got = append(got, frame.String())
+			}
+			if !reflect.DeepEqual(got, test.want) {
+				t.Errorf("got trace\n%s\nwant\n%s", strings.Join(got, "\n"), strings.Join(test.want, "\n"))
+			}
+		})
+	}
+}
+
+// TestStackTraceTrimmed checks a deep recursion reports its innermost and
+// outermost ten frames, saying how many were left out.
+func TestStackTraceTrimmed(t *testing.T) {
+	err := run(t, `fun down(n) {
+  if (n == 0) return nil();
+  down(n - 1);
+}
+down(25);
+`, nil)
+	if err == nil {
+		t.Fatal("got no error")
+	}
+	if len(err.Trace) != 27 {
+		t.Fatalf("got %d frames, want 27", len(err.Trace))
+	}
+
+	want := []string{"at down() line 2"}
+	for range 9 {
+		want = append(want, "at down() line 3")
+	}
+	want = append(want, fmt.Sprintf("... %d more frames", 27-20))
+	for range 9 {
+		want = append(want, "at down() line 3")
+	}
+	want = append(want, "at main line 5")
+	if got := err.Diagnostic().Notes; !reflect.DeepEqual(got, want) {
+		t.Errorf("got notes\n%s\nwant\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+	}
+}
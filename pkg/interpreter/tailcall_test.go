@@ -0,0 +1,119 @@
+package interpreter_test
+
+import (
+	"fmt"
+	goruntime "runtime"
+	"strings"
+	"testing"
+
+	"github.com/neet-007/glox/pkg/diagnostics"
+	"github.com/neet-007/glox/pkg/interpreter"
+	"github.com/neet-007/glox/pkg/parser"
+	"github.com/neet-007/glox/pkg/trace"
+)
+
+// countdown recurses n times in tail position before it prints.
+const countdown = `
+fun countdown(n) {
+  if (n == 0) {
+    var depth = goDepth();
+    print depth;
+    return depth;
+  }
+  return countdown(n - 1);
+}
+countdown(%s);
+`
+
+// goDepth returns how many Go frames deep it was called.
+type goDepth struct{}
+
+func (goDepth) Arity() int {
+	return 0
+}
+
+func (goDepth) Call(*interpreter.Interpreter, []any) (any, error) {
+	pcs := make([]uintptr, 1<<16)
+	return float64(goruntime.Callers(0, pcs)), nil
+}
+
+func (goDepth) String() string {
+	return "<fn goDepth>"
+}
+
+func TestTailCalls(t *testing.T) {
+	depths := map[string]string{}
+	for _, n := range []string{"1", "100000"} {
+		var out strings.Builder
+		err := run(t, fmt.Sprintf(countdown, n), func(i *interpreter.Interpreter) {
+			i.Stdout = &out
+			i.Define("goDepth", goDepth{})
+		})
+		if err != nil {
+			t.Fatalf("counting down from %s: %v", n, err)
+		}
+		depths[n] = out.String()
+	}
+
+	// the Go stack is as deep after 100000 calls as after one
+	if depths["1"] != depths["100000"] {
+		t.Errorf("called %s Go frames deep after one call and %s after 100000", strings.TrimSpace(depths["1"]), strings.TrimSpace(depths["100000"]))
+	}
+}
+
+// nopTracer and nopHook see everything and do nothing.
+type nopTracer struct{}
+
+func (nopTracer) Trace(trace.Event) {}
+
+type nopHook struct{}
+
+func (nopHook) Statement(parser.Stmt) error {
+	return nil
+}
+
+// TestNoTailCalls makes sure every call keeps its frame when tail calls are
+// turned off, by -no-tco or by a Tracer or debugger watching the calls, and
+// that the stack overflow says so when the script asked for tail calls.
+func TestNoTailCalls(t *testing.T) {
+	tests := map[string]struct {
+		setup func(*interpreter.Interpreter)
+		told  bool
+	}{
+		"no-tco":        {func(i *interpreter.Interpreter) { i.TailCalls = false }, false},
+		"tracer":        {func(i *interpreter.Interpreter) { i.Tracer = nopTracer{} }, true},
+		"hook":          {func(i *interpreter.Interpreter) { i.Hook = nopHook{} }, true},
+		"no-tco tracer": {func(i *interpreter.Interpreter) { i.TailCalls = false; i.Tracer = nopTracer{} }, false},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			err := run(t, fmt.Sprintf(countdown, "100"), func(i *interpreter.Interpreter) {
+				i.Define("goDepth", goDepth{})
+				i.MaxStackDepth = 50
+				test.setup(i)
+			})
+			if err == nil || err.Code != diagnostics.StackOverflow || err.Message != "Stack overflow." {
+				t.Fatalf("got %v, want a stack overflow", err)
+			}
+			if len(err.Trace) < 50 {
+				t.Errorf("got a trace of %d frames, want every call", len(err.Trace))
+			}
+
+			notes := err.Diagnostic().Notes
+			told := len(notes) > 0 && strings.Contains(notes[len(notes)-1], "tail position")
+			if told != test.told {
+				t.Errorf("told that tail calls were off: %v, want %v (notes %q)", told, test.told, notes)
+			}
+		})
+	}
+
+	// the same script runs with tail calls
+	err := run(t, fmt.Sprintf(countdown, "100"), func(i *interpreter.Interpreter) {
+		i.Define("goDepth", goDepth{})
+		i.MaxStackDepth = 50
+	})
+	if err != nil {
+		t.Errorf("got %v with tail calls, want none", err)
+	}
+}
@@ -0,0 +1,55 @@
+package interpreter
+
+import (
+	"github.com/neet-007/glox/pkg/scanner"
+	"github.com/neet-007/glox/pkg/trace"
+)
+
+// traceEvent tells the Tracer, if there is one, about an event at token.
+func (i *Interpreter) traceEvent(kind trace.Kind, token scanner.Token, name string, value any) {
+	if i.Tracer == nil {
+		return
+	}
+
+	i.Tracer.Trace(trace.Event{
+		Kind:   kind,
+		File:   token.File,
+		Line:   token.Line,
+		Column: token.Column,
+		Depth:  len(i.frames),
+		Name:   name,
+		Value:  value,
+	})
+}
+
+// traceCall traces a call or its return at the depth of the caller, so the
+// call lines up with the statement that made it.
+func (i *Interpreter) traceCall(kind trace.Kind, token scanner.Token, name string, value any) {
+	if i.Tracer == nil {
+		return
+	}
+
+	i.Tracer.Trace(trace.Event{
+		Kind:   kind,
+		File:   token.File,
+		Line:   token.Line,
+		Column: token.Column,
+		Depth:  len(i.frames) - 1,
+		Name:   name,
+		Value:  value,
+	})
+}
+
+// traceBranch traces the branch taken at keyword, the first one when taken
+// is set.
+func (i *Interpreter) traceBranch(keyword scanner.Token, taken bool) {
+	if i.Tracer == nil {
+		return
+	}
+
+	branch := 1
+	if taken {
+		branch = 0
+	}
+	i.traceEvent(trace.Branch, keyword, keyword.Lexeme, branch)
+}
@@ -0,0 +1,93 @@
+package interpreter_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/neet-007/glox/pkg/interpreter"
+	"github.com/neet-007/glox/pkg/trace"
+)
+
+// TestTraceOrder checks the events of small scripts arrive in the order
+// they happen, the resolver's before any of the run's.
+func TestTraceOrder(t *testing.T) {
+	tests := []struct {
+		name   string
+		source string
+		kinds  []trace.Kind
+		want   string
+	}{
+		{
+			name: "call",
+			source: `fun add(a, b) {
+  var s = a + b;
+  return s;
+}
+var x = add(1, 2);
+if (x > 2) print x; else print 0;
+`,
+			want: `test.lox:2:11 resolve a depth 0
+test.lox:2:15 resolve b depth 0
+test.lox:3:10 resolve s depth 0
+test.lox:5:9 resolve add global
+test.lox:6:5 resolve x global
+test.lox:6:18 resolve x global
+test.lox:1:5 statement fun
+test.lox:1:5 define add = <fn add>
+test.lox:5:5 statement var
+test.lox:5:17 call add
+  test.lox:1:9 define a = 1
+  test.lox:1:12 define b = 2
+  test.lox:2:7 statement var
+  test.lox:2:7 define s = 3
+  test.lox:3:3 statement return
+test.lox:5:17 return add -> 3
+test.lox:5:5 define x = 3
+test.lox:6:1 statement if
+test.lox:6:1 branch if 0
+test.lox:6:12 statement print
+`,
+		},
+		{
+			name: "error",
+			source: `fun f(n) {
+  return n * "x";
+}
+f(2);
+`,
+			kinds: []trace.Kind{trace.Call, trace.Return, trace.Error},
+			want: `test.lox:4:4 call f
+test.lox:2:12 error: Expect operands to be numbers
+`,
+		},
+		{
+			name: "loop",
+			source: `var i = 0;
+while (i < 2) i = i + 1;
+`,
+			kinds: []trace.Kind{trace.Branch, trace.Assign},
+			want: `test.lox:2:1 branch while 0
+test.lox:2:15 assign i = 1
+test.lox:2:1 branch while 0
+test.lox:2:15 assign i = 2
+test.lox:2:1 branch while 1
+`,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var out strings.Builder
+			tracer := trace.Text(&out)
+			if test.kinds != nil {
+				tracer = trace.Filter(tracer, test.kinds...)
+			}
+			run(t, test.source, func(i *interpreter.Interpreter) {
+				i.Tracer = tracer
+			})
+			if got := out.String(); got != test.want {
+				t.Errorf("got\n%s\nwant\n%s", got, test.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,117 @@
+package lint
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/neet-007/glox/pkg/diagnostics"
+)
+
+// ConfigFile is looked for in the current directory and its parents, the
+// first one found is used.
+const ConfigFile = ".gloxlint.json"
+
+// Config turns rules off or changes how severe they are. It is read from
+// a file like
+//
+//	{
+//		"rules": {
+//			"empty-block": "off",
+//			"self-compare": "error",
+//			"W0301": "warning"
+//		}
+//	}
+//
+// where rules are named by rule name or code. Rules not listed are warnings.
+type Config struct {
+	Rules map[string]string `json:"rules"`
+}
+
+func NewConfig() *Config {
+	return &Config{
+		Rules: map[string]string{},
+	}
+}
+
+func LoadConfig(path string) (*Config, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	config := NewConfig()
+	if err := json.Unmarshal(data, config); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+
+	for rule, level := range config.Rules {
+		if !knownRule(rule) {
+			return nil, fmt.Errorf("%s: unknown rule %s", path, rule)
+		}
+		if level != "off" && level != "warning" && level != "error" {
+			return nil, fmt.Errorf("%s: rule %s has level %s, want off, warning or error", path, rule, level)
+		}
+	}
+
+	return config, nil
+}
+
+// FindConfig returns the path of the nearest ConfigFile at or above dir,
+// or "" when there is none.
+func FindConfig(dir string) string {
+	dir, err := filepath.Abs(dir)
+	if err != nil {
+		return ""
+	}
+
+	for {
+		path := filepath.Join(dir, ConfigFile)
+		if _, err := os.Stat(path); err == nil {
+			return path
+		}
+
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return ""
+		}
+		dir = parent
+	}
+}
+
+// Severity is the severity code is reported with, false when it is off.
+func (c *Config) Severity(code diagnostics.Code) (diagnostics.Severity, bool) {
+	level, ok := c.Rules[diagnostics.RuleName(code)]
+	if !ok {
+		level, ok = c.Rules[string(code)]
+	}
+	if !ok {
+		return diagnostics.Warning, true
+	}
+
+	switch level {
+	case "off":
+		return diagnostics.Warning, false
+	case "error":
+		return diagnostics.Error, true
+	default:
+		return diagnostics.Warning, true
+	}
+}
+
+// IsRule reports whether code is one of Rules rather than a scanner or
+// parser error.
+func IsRule(code diagnostics.Code) bool {
+	return knownRule(string(code))
+}
+
+func knownRule(rule string) bool {
+	for _, code := range Rules {
+		if rule == string(code) || rule == diagnostics.RuleName(code) {
+			return true
+		}
+	}
+
+	return false
+}
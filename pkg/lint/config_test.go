@@ -0,0 +1,121 @@
+package lint
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/neet-007/glox/pkg/diagnostics"
+)
+
+func TestLoadConfig(t *testing.T) {
+	tests := []struct {
+		name string
+		data string
+		err  string
+	}{
+		{"names and codes", `{"rules": {"empty-block": "off", "W0503": "error", "shadowing": "warning"}}`, ""},
+		{"empty", `{}`, ""},
+		{"unknown rule", `{"rules": {"no-such-rule": "off"}}`, "unknown rule no-such-rule"},
+		{"unknown code", `{"rules": {"E0001": "off"}}`, "unknown rule E0001"},
+		{"unknown level", `{"rules": {"empty-block": "loud"}}`, "has level loud"},
+		{"not json", `rules: off`, "invalid character"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), ConfigFile)
+			if err := os.WriteFile(path, []byte(test.data), 0o644); err != nil {
+				t.Fatal(err)
+			}
+
+			_, err := LoadConfig(path)
+			if test.err == "" {
+				if err != nil {
+					t.Errorf("unexpected error: %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), test.err) || !strings.Contains(err.Error(), path) {
+				t.Errorf("got %v, want an error about %q naming the file", err, test.err)
+			}
+		})
+	}
+
+	if _, err := LoadConfig(filepath.Join(t.TempDir(), ConfigFile)); !os.IsNotExist(err) {
+		t.Errorf("got %v loading a missing file, want it not to exist", err)
+	}
+}
+
+func TestSeverity(t *testing.T) {
+	config := NewConfig()
+	config.Rules["empty-block"] = "off"
+	config.Rules[string(diagnostics.SelfComparison)] = "error"
+	config.Rules["shadowing"] = "warning"
+	// the name wins over the code
+	config.Rules["unused-variable"] = "error"
+	config.Rules[string(diagnostics.UnusedVariable)] = "off"
+
+	tests := []struct {
+		code     diagnostics.Code
+		severity diagnostics.Severity
+		on       bool
+	}{
+		{diagnostics.EmptyBlock, diagnostics.Warning, false},
+		{diagnostics.SelfComparison, diagnostics.Error, true},
+		{diagnostics.ShadowedVariable, diagnostics.Warning, true},
+		{diagnostics.UnusedVariable, diagnostics.Error, true},
+		{diagnostics.CallNonCallable, diagnostics.Warning, true},
+	}
+
+	for _, test := range tests {
+		severity, on := config.Severity(test.code)
+		if severity != test.severity || on != test.on {
+			t.Errorf("%s: got %v, %v, want %v, %v", test.code, severity, on, test.severity, test.on)
+		}
+	}
+}
+
+func TestFindConfig(t *testing.T) {
+	root := t.TempDir()
+	nested := filepath.Join(root, "a", "b")
+	if err := os.MkdirAll(nested, 0o755); err != nil {
+		t.Fatal(err)
+	}
+
+	// a config above the temporary directory is not this test's to see
+	if got := FindConfig(nested); strings.HasPrefix(got, root) {
+		t.Fatalf("got %s before any config was written", got)
+	}
+
+	path := filepath.Join(root, ConfigFile)
+	if err := os.WriteFile(path, []byte(`{}`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if got := FindConfig(nested); got != path {
+		t.Errorf("got %q, want %q", got, path)
+	}
+
+	closer := filepath.Join(root, "a", ConfigFile)
+	if err := os.WriteFile(closer, []byte(`{}`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if got := FindConfig(nested); got != closer {
+		t.Errorf("got %q, want the nearer %q", got, closer)
+	}
+}
+
+func TestRulesAreKnown(t *testing.T) {
+	for _, code := range Rules {
+		if !IsRule(code) {
+			t.Errorf("%s is not a rule", code)
+		}
+		if name := diagnostics.RuleName(code); name == string(code) {
+			t.Errorf("%s has no rule name", code)
+		}
+	}
+	if IsRule(diagnostics.Code("E0001")) {
+		t.Error("E0001 is a rule")
+	}
+}
@@ -0,0 +1,110 @@
+package lint
+
+import (
+	"os"
+	"sort"
+
+	"github.com/neet-007/glox/pkg/diagnostics"
+	"github.com/neet-007/glox/pkg/interpreter"
+	"github.com/neet-007/glox/pkg/parser"
+	"github.com/neet-007/glox/pkg/resolver"
+	"github.com/neet-007/glox/pkg/scanner"
+	"github.com/neet-007/glox/pkg/utils"
+)
+
+// Rules are the codes of every check the linter knows, the resolver
+// warnings followed by the rules of this package.
+var Rules = []diagnostics.Code{
+	diagnostics.UnusedVariable,
+	diagnostics.UnusedParameter,
+	diagnostics.UnreachableCode,
+	diagnostics.ShadowedVariable,
+	diagnostics.InconsistentReturn,
+	diagnostics.ClassInitArguments,
+	diagnostics.CallNonCallable,
+	diagnostics.SelfComparison,
+	diagnostics.AssignmentInCondition,
+	diagnostics.EmptyBlock,
+}
+
+type Linter struct {
+	config *Config
+}
+
+func NewLinter(config *Config) *Linter {
+	if config == nil {
+		config = NewConfig()
+	}
+
+	return &Linter{
+		config: config,
+	}
+}
+
+// LintPaths lints every path, directories are walked for ".lox" files.
+// The diagnostics are sorted by file and position.
+func (l *Linter) LintPaths(paths []string) ([]*diagnostics.Diagnostic, error) {
+	files, err := utils.LoxFiles(paths)
+	if err != nil {
+		return nil, err
+	}
+
+	reported := []*diagnostics.Diagnostic{}
+	for _, file := range files {
+		source, err := os.ReadFile(file)
+		if err != nil {
+			return nil, err
+		}
+
+		reported = append(reported, l.LintSource(file, source)...)
+	}
+
+	sort.SliceStable(reported, func(i, j int) bool {
+		if reported[i].Span.File != reported[j].Span.File {
+			return reported[i].Span.File < reported[j].Span.File
+		}
+		return reported[i].Span.Offset < reported[j].Span.Offset
+	})
+
+	return reported, nil
+}
+
+// LintSource lints one script. Scanner and parser errors are returned as
+// they are and stop the script from being checked any further.
+func (l *Linter) LintSource(file string, source []byte) []*diagnostics.Diagnostic {
+	scanner_ := scanner.NewFileScanner(file, source, false)
+	tokens, scannerErrors := scanner_.Scan()
+
+	parser_ := parser.NewParser(tokens, false)
+	statements, parserErrors := parser_.Parse()
+
+	if len(scannerErrors) > 0 || len(parserErrors) > 0 {
+		return append(scannerErrors, parserErrors...)
+	}
+
+	resolver_ := resolver.NewResolver(interpreter.NewInterpreter())
+	reported := resolver_.Resolve(statements)
+	reported = append(reported, newChecker(statements).check(statements)...)
+
+	suppressions := diagnostics.NewSuppressions()
+	for _, comment := range scanner_.Comments() {
+		suppressions.AddComment(comment.Line, comment.Text, comment.OwnLine)
+	}
+
+	kept := []*diagnostics.Diagnostic{}
+	for _, diagnostic := range suppressions.Filter(reported) {
+		if diagnostic.Severity == diagnostics.Error {
+			kept = append(kept, diagnostic)
+			continue
+		}
+
+		severity, ok := l.config.Severity(diagnostic.Code)
+		if !ok {
+			continue
+		}
+		diagnostic.Severity = severity
+		kept = append(kept, diagnostic)
+	}
+
+	return kept
+}
@@ -0,0 +1,115 @@
+package lint
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+
+	"github.com/neet-007/glox/pkg/diagnostics"
+)
+
+// lint lints source with config and returns what it reports as
+// "code line severity".
+func lint(t *testing.T, config *Config, source string) []string {
+	t.Helper()
+	found := []string{}
+	for _, diagnostic := range NewLinter(config).LintSource("test.lox", []byte(source)) {
+		found = append(found, fmt.Sprintf("%s %d %s", diagnostic.Code, diagnostic.Span.Line, diagnostic.Severity))
+	}
+	return found
+}
+
+func warning(code diagnostics.Code, line int) string {
+	return fmt.Sprintf("%s %d %s", code, line, diagnostics.Warning)
+}
+
+func TestRules(t *testing.T) {
+	tests := []struct {
+		name   string
+		source string
+		want   []string
+	}{
+		{"class init arguments", "class A {\n  init(a) { print a; }\n}\nA(1, 2);", []string{warning(diagnostics.ClassInitArguments, 4)}},
+		{"class init matching", "class A {\n  init(a) { print a; }\n}\nA(1);", nil},
+		{"class without init", "class A {}\nA(1);", []string{warning(diagnostics.ClassInitArguments, 2)}},
+		{"inherited init", "class A {\n  init(a) { print a; }\n}\nclass B < A {}\nB();", []string{warning(diagnostics.ClassInitArguments, 5)}},
+		{"call number", "1();", []string{warning(diagnostics.CallNonCallable, 1)}},
+		{"call string", "(\"f\")();", []string{warning(diagnostics.CallNonCallable, 1)}},
+		{"call list", "[1]();", []string{warning(diagnostics.CallNonCallable, 1)}},
+		{"call function", "fun f() { print 1; }\nf();", nil},
+		{"self comparison", "var a = 1;\nprint a == a;", []string{warning(diagnostics.SelfComparison, 2)}},
+		{"self comparison grouped", "var a = 1;\nprint (a) < a;", []string{warning(diagnostics.SelfComparison, 2)}},
+		{"self comparison property", "class A {\n  f() { return this.x != this.x; }\n}", []string{warning(diagnostics.SelfComparison, 2)}},
+		{"different sides", "var a = 1;\nvar b = 2;\nprint a == b;", nil},
+		{"arithmetic on itself", "var a = 1;\nprint a + a;", nil},
+		{"assignment in if", "var a = 1;\nif (a = 2) print a;", []string{warning(diagnostics.AssignmentInCondition, 2)}},
+		{"assignment in while", "var a = 1;\nwhile ((a = false)) print a;", []string{warning(diagnostics.AssignmentInCondition, 2)}},
+		{"set in condition", "class A {}\nvar a = A();\nif (a.x = 1) print a;", []string{warning(diagnostics.AssignmentInCondition, 3)}},
+		{"comparison in condition", "var a = 1;\nif (a == 2) print a;", nil},
+		{"empty block", "{\n}", []string{warning(diagnostics.EmptyBlock, 1)}},
+		{"empty while body", "while (false) {}", []string{warning(diagnostics.EmptyBlock, 1)}},
+		{"block with statement", "{\n  print 1;\n}", nil},
+		{"resolver warning", "{\n  var a = 1;\n}", []string{warning(diagnostics.UnusedVariable, 2)}},
+		{"ignored", "print 1 == 1; // glox:ignore self-compare", nil},
+		{"ignored other rule", "print 1 == 1; // glox:ignore empty-block", []string{warning(diagnostics.SelfComparison, 1)}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got := lint(t, nil, test.source)
+			if len(got) == 0 && len(test.want) == 0 {
+				return
+			}
+			if !reflect.DeepEqual(got, test.want) {
+				t.Errorf("got %q, want %q", got, test.want)
+			}
+		})
+	}
+}
+
+func TestRulesConfig(t *testing.T) {
+	// a self comparison and an empty block
+	const source = "print 1 == 1;\n{}"
+
+	tests := []struct {
+		name  string
+		rules map[string]string
+		want  []string
+	}{
+		{"default", nil, []string{warning(diagnostics.SelfComparison, 1), warning(diagnostics.EmptyBlock, 2)}},
+		{"off by name", map[string]string{"empty-block": "off"}, []string{warning(diagnostics.SelfComparison, 1)}},
+		{"off by code", map[string]string{string(diagnostics.SelfComparison): "off"}, []string{warning(diagnostics.EmptyBlock, 2)}},
+		{"both off", map[string]string{"empty-block": "off", "self-compare": "off"}, nil},
+		{"error", map[string]string{"self-compare": "error"}, []string{
+			fmt.Sprintf("%s 1 %s", diagnostics.SelfComparison, diagnostics.Error),
+			warning(diagnostics.EmptyBlock, 2),
+		}},
+		{"warning", map[string]string{"W0505": "warning"}, []string{warning(diagnostics.SelfComparison, 1), warning(diagnostics.EmptyBlock, 2)}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			config := NewConfig()
+			for rule, level := range test.rules {
+				config.Rules[rule] = level
+			}
+			got := lint(t, config, source)
+			if len(got) == 0 && len(test.want) == 0 {
+				return
+			}
+			if !reflect.DeepEqual(got, test.want) {
+				t.Errorf("got %q, want %q", got, test.want)
+			}
+		})
+	}
+}
+
+func TestSyntaxErrorsStayErrors(t *testing.T) {
+	config := NewConfig()
+	config.Rules["empty-block"] = "off"
+
+	reported := NewLinter(config).LintSource("test.lox", []byte("var = 1;"))
+	if len(reported) != 1 || reported[0].Severity != diagnostics.Error {
+		t.Errorf("got %v, want one error", reported)
+	}
+}
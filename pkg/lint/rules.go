@@ -0,0 +1,320 @@
+package lint
+
+import (
+	"fmt"
+
+	"github.com/neet-007/glox/pkg/diagnostics"
+	"github.com/neet-007/glox/pkg/parser"
+	"github.com/neet-007/glox/pkg/scanner"
+)
+
+// checker walks a script once and reports what the rules of this package
+// find. Classes are matched by name, so a class shadowed by a local of the
+// same name can be reported wrongly.
+type checker struct {
+	classes  map[string]parser.Class
+	reported []*diagnostics.Diagnostic
+}
+
+func newChecker(stmts []parser.Stmt) *checker {
+	c := &checker{
+		classes:  map[string]parser.Class{},
+		reported: []*diagnostics.Diagnostic{},
+	}
+	c.collectClasses(stmts)
+
+	return c
+}
+
+func (c *checker) check(stmts []parser.Stmt) []*diagnostics.Diagnostic {
+	for _, stmt := range stmts {
+		stmt.Accept(c)
+	}
+
+	return c.reported
+}
+
+func (c *checker) warn(token scanner.Token, code diagnostics.Code, message string) *diagnostics.Diagnostic {
+	warning := diagnostics.NewWarning(diagnostics.PhaseLint, code, token.Span(), message)
+	c.reported = append(c.reported, warning)
+	return warning
+}
+
+func (c *checker) collectClasses(stmts []parser.Stmt) {
+	for _, stmt := range stmts {
+		switch stmt := stmt.(type) {
+		case parser.Class:
+			c.classes[stmt.Name.Lexeme] = stmt
+		case parser.Function:
+			c.collectClasses(stmt.Body)
+		case parser.Block:
+			c.collectClasses(stmt.Statements)
+		}
+	}
+}
+
+// initializer finds the init method class, or one of its superclasses,
+// is constructed with.
+func (c *checker) initializer(class parser.Class) (parser.Function, bool) {
+	seen := map[string]bool{}
+	for !seen[class.Name.Lexeme] {
+		seen[class.Name.Lexeme] = true
+		for _, method := range class.Methods {
+			if method.Name.Lexeme == "init" {
+				return method, true
+			}
+		}
+
+		superclass, ok := c.classes[class.SuperClass.Name.Lexeme]
+		if !ok {
+			break
+		}
+		class = superclass
+	}
+
+	return parser.Function{}, false
+}
+
+func (c *checker) checkClassCall(expr parser.Call) {
+	callee, ok := unwrap(expr.Callee).(parser.Variable)
+	if !ok {
+		return
+	}
+	class, ok := c.classes[callee.Name.Lexeme]
+	if !ok {
+		return
+	}
+
+	init, ok := c.initializer(class)
+	if !ok {
+		if len(expr.Arguments) > 0 {
+			c.warn(callee.Name, diagnostics.ClassInitArguments, fmt.Sprintf("Class '%s' has no init method but is called with %d arguments.", class.Name.Lexeme, len(expr.Arguments)))
+		}
+		return
+	}
+
+	if len(init.Parameters) != len(expr.Arguments) {
+		c.warn(callee.Name, diagnostics.ClassInitArguments, fmt.Sprintf("Class '%s' is called with %d arguments but its init method takes %d.", class.Name.Lexeme, len(expr.Arguments), len(init.Parameters))).
+			WithNote(fmt.Sprintf("init is declared on line %d", init.Name.Line))
+	}
+}
+
+func (c *checker) checkCondition(condition parser.Expr) {
+	switch expr := unwrap(condition).(type) {
+	case parser.Assign:
+		c.warn(expr.Lexem, diagnostics.AssignmentInCondition, "Assignment used as a condition.").
+			WithHint("use '==' to compare")
+	case parser.Set:
+		c.warn(expr.Name, diagnostics.AssignmentInCondition, "Assignment used as a condition.").
+			WithHint("use '==' to compare")
+	}
+}
+
+func (c *checker) VisitClassStmt(stmt parser.Class) (any, error) {
+	for _, method := range stmt.Methods {
+		method.Accept(c)
+	}
+	return nil, nil
+}
+
+func (c *checker) VisitReturnStmt(stmt parser.Return) (any, error) {
+	if stmt.Value != nil {
+		stmt.Value.Accept(c)
+	}
+	return nil, nil
+}
+
+func (c *checker) VisitFunctionStmt(stmt parser.Function) (any, error) {
+	for _, stmt := range stmt.Body {
+		stmt.Accept(c)
+	}
+	return nil, nil
+}
+
+func (c *checker) VisitVarDeclaration(stmt parser.VarDeclaration) (any, error) {
+	if stmt.Initizlier != nil {
+		stmt.Initizlier.Accept(c)
+	}
+	return nil, nil
+}
+
+func (c *checker) VisitWhileStmt(stmt parser.WhileStmt) (any, error) {
+	c.checkCondition(stmt.Condition)
+	stmt.Condition.Accept(c)
+	stmt.Body.Accept(c)
+	return nil, nil
+}
+
+func (c *checker) VisitBlockStmt(stmt parser.Block) (any, error) {
+	if len(stmt.Statements) == 0 {
+		c.warn(stmt.Brace, diagnostics.EmptyBlock, "Empty block.")
+	}
+	for _, stmt := range stmt.Statements {
+		stmt.Accept(c)
+	}
+	return nil, nil
+}
+
+func (c *checker) VisitIfStmt(stmt parser.IfStmt) (any, error) {
+	c.checkCondition(stmt.Condition)
+	stmt.Condition.Accept(c)
+	stmt.ThenBranch.Accept(c)
+	if stmt.ElseBranch != nil {
+		stmt.ElseBranch.Accept(c)
+	}
+	return nil, nil
+}
+
+func (c *checker) VisitExpressionStmt(stmt parser.ExpressionStmt) (any, error) {
+	stmt.Expression.Accept(c)
+	return nil, nil
+}
+
+func (c *checker) VisitPrintStmt(stmt parser.PrintStmt) (any, error) {
+	stmt.Expression.Accept(c)
+	return nil, nil
+}
+
+func (c *checker) VisitListSet(expr parser.ListSet) (any, error) {
+	expr.List.Accept(c)
+	expr.Index.Accept(c)
+	expr.Value.Accept(c)
+	return nil, nil
+}
+
+func (c *checker) VisitListGet(expr parser.ListGet) (any, error) {
+	expr.List.Accept(c)
+	expr.Index.Accept(c)
+	return nil, nil
+}
+
+func (c *checker) VisitListExpr(expr parser.ListExpr) (any, error) {
+	for _, item := range expr.Literals {
+		item.Accept(c)
+	}
+	return nil, nil
+}
+
+func (c *checker) VisitSuperExpr(expr parser.Super) (any, error) {
+	return nil, nil
+}
+
+func (c *checker) VisitThisExpr(expr parser.This) (any, error) {
+	return nil, nil
+}
+
+func (c *checker) VisitSetExpr(expr parser.Set) (any, error) {
+	expr.Object.Accept(c)
+	expr.Value.Accept(c)
+	return nil, nil
+}
+
+func (c *checker) VisitGetExpr(expr parser.Get) (any, error) {
+	expr.Object.Accept(c)
+	return nil, nil
+}
+
+func (c *checker) VisitCallExpr(expr parser.Call) (any, error) {
+	switch callee := unwrap(expr.Callee).(type) {
+	case parser.Literal:
+		c.warn(expr.Paren, diagnostics.CallNonCallable, fmt.Sprintf("Calling %s, which is not a function or class.", describeLiteral(callee.Value)))
+	case parser.ListExpr:
+		c.warn(expr.Paren, diagnostics.CallNonCallable, "Calling a list, which is not a function or class.")
+	default:
+		c.checkClassCall(expr)
+	}
+
+	expr.Callee.Accept(c)
+	for _, arg := range expr.Arguments {
+		arg.Accept(c)
+	}
+	return nil, nil
+}
+
+func (c *checker) VisitVariableExpr(expr parser.Variable) (any, error) {
+	return nil, nil
+}
+
+func (c *checker) VisitAssignExpr(expr parser.Assign) (any, error) {
+	expr.Expr.Accept(c)
+	return nil, nil
+}
+
+func (c *checker) VisitBinaryExpr(expr parser.Binary) (any, error) {
+	switch expr.Operator.TokenType {
+	case scanner.EQUAL_EQUAL, scanner.BANG_EQUAL, scanner.LESS, scanner.LESS_EQUAL, scanner.GREATER, scanner.GREATER_EQUAL:
+		if sameExpr(expr.Left, expr.Right) {
+			c.warn(expr.Operator, diagnostics.SelfComparison, fmt.Sprintf("Both sides of '%s' are the same, the result is always the same.", expr.Operator.Lexeme))
+		}
+	}
+
+	expr.Left.Accept(c)
+	expr.Right.Accept(c)
+	return nil, nil
+}
+
+func (c *checker) VisitGroupingExpr(expr parser.Grouping) (any, error) {
+	expr.Expr.Accept(c)
+	return nil, nil
+}
+
+func (c *checker) VisitLiteralExpr(expr parser.Literal) (any, error) {
+	return nil, nil
+}
+
+func (c *checker) VisitLogicalExpr(expr parser.Logical) (any, error) {
+	expr.Left.Accept(c)
+	expr.Right.Accept(c)
+	return nil, nil
+}
+
+func (c *checker) VisitUnaryExpr(expr parser.Unary) (any, error) {
+	expr.Right.Accept(c)
+	return nil, nil
+}
+
+func unwrap(expr parser.Expr) parser.Expr {
+	for {
+		grouping, ok := expr.(parser.Grouping)
+		if !ok {
+			return expr
+		}
+		expr = grouping.Expr
+	}
+}
+
+// sameExpr reports whether a and b are the same side effect free
+// expression, like the same variable or the same property of this.
+func sameExpr(a parser.Expr, b parser.Expr) bool {
+	switch a := unwrap(a).(type) {
+	case parser.Variable:
+		b, ok := unwrap(b).(parser.Variable)
+		return ok && a.Name.Lexeme == b.Name.Lexeme
+	case parser.This:
+		_, ok := unwrap(b).(parser.This)
+		return ok
+	case parser.Get:
+		b, ok := unwrap(b).(parser.Get)
+		return ok && a.Name.Lexeme == b.Name.Lexeme && sameExpr(a.Object, b.Object)
+	case parser.Literal:
+		b, ok := unwrap(b).(parser.Literal)
+		return ok && a.Value == b.Value
+	default:
+		return false
+	}
+}
+
+func describeLiteral(value any) string {
+	switch value.(type) {
+	case nil:
+		return "nil"
+	case bool:
+		return "a boolean"
+	case float64:
+		return "a number"
+	case string:
+		return "a string"
+	default:
+		return "a literal"
+	}
+}
@@ -0,0 +1,72 @@
+package lox
+
+import (
+	"context"
+	"io"
+	"os"
+	"testing"
+
+	"github.com/neet-007/glox/pkg/diagnostics"
+	"github.com/neet-007/glox/pkg/interpreter"
+	"github.com/neet-007/glox/pkg/optimizer"
+	"github.com/neet-007/glox/pkg/parser"
+	"github.com/neet-007/glox/pkg/resolver"
+	"github.com/neet-007/glox/pkg/scanner"
+)
+
+// prepare readies file to be run once by the tree backend, the one keeping
+// its variables in environments, its output thrown away. Scanning, parsing
+// and resolving are left out of what is timed.
+func prepare(b *testing.B, file string) func() error {
+	b.Helper()
+	source, err := os.ReadFile(file)
+	if err != nil {
+		b.Fatal(err)
+	}
+	tokens, errs := scanner.NewFileScanner(file, source, false).Scan()
+	if len(errs) > 0 {
+		b.Fatal(errs[0])
+	}
+	statements, errs := parser.NewParser(tokens, false).Parse()
+	if len(errs) > 0 {
+		b.Fatal(errs[0])
+	}
+
+	interpreter_ := interpreter.NewInterpreter()
+	interpreter_.Stdout = io.Discard
+	for _, err := range resolver.NewResolver(interpreter_).Resolve(statements) {
+		if err.Severity == diagnostics.Error {
+			b.Fatal(err)
+		}
+	}
+	statements = optimizer.Optimize(statements)
+	resolver.NewResolver(interpreter_).Resolve(statements)
+
+	return func() error {
+		if err := interpreter_.InterpretContext(context.Background(), statements); err != nil {
+			return err
+		}
+		return nil
+	}
+}
+
+// benchmark runs file, a fresh run for each iteration.
+func benchmark(b *testing.B, file string) {
+	b.ReportAllocs()
+	for range b.N {
+		b.StopTimer()
+		run := prepare(b, file)
+		b.StartTimer()
+		if err := run(); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkFib(b *testing.B) {
+	benchmark(b, "../../bench/fib.lox")
+}
+
+func BenchmarkLoop(b *testing.B) {
+	benchmark(b, "../../bench/loop.lox")
+}
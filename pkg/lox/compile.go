@@ -0,0 +1,75 @@
+package lox
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/neet-007/glox/pkg/loxc"
+)
+
+// Compile runs "glox compile [flags] script", writing the resolved script
+// to a .loxc file that glox runs without scanning, parsing or resolving it
+// again, and returns the exit code.
+func Compile(args []string) int {
+	flags := flag.NewFlagSet("compile", flag.ContinueOnError)
+	output := flags.String("o", "", "file to write, the script's name with .loxc in place of .lox by default")
+	diagnostics := flags.String("diagnostics", "plain", "error output format: plain, pretty or json")
+	warnings := flags.Bool("warnings", false, "report resolver warnings such as unused variables and unreachable code")
+	noOpt := flags.Bool("no-opt", false, "compile the script as written, without folding constants or dropping code that can never run")
+
+	// flags may come after the script, as in "glox compile a.lox -o a.loxc".
+	scripts := []string{}
+	for {
+		if err := flags.Parse(args); err != nil {
+			return 64
+		}
+		if flags.NArg() == 0 {
+			break
+		}
+		scripts = append(scripts, flags.Arg(0))
+		args = flags.Args()[1:]
+	}
+	if len(scripts) != 1 {
+		fmt.Fprintln(os.Stderr, "Usage: glox compile [flags] script")
+		return 64
+	}
+	if *diagnostics != "pretty" && *diagnostics != "plain" && *diagnostics != "json" {
+		fmt.Fprintf(os.Stderr, "Unknown diagnostics format %s\n", *diagnostics)
+		return 64
+	}
+
+	script := scripts[0]
+	if *output == "" {
+		*output = strings.TrimSuffix(script, filepath.Ext(script)) + loxc.Extension
+	}
+
+	source, err := os.ReadFile(script)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to open file %s with error: %v\n", script, err)
+		return 66
+	}
+
+	l := NewLox()
+	l.diagnostics = *diagnostics
+	l.warnings = *warnings
+	l.optimize = !*noOpt
+	statements := l.resolve(script, source)
+	if l.hadError {
+		return 65
+	}
+
+	var compiled bytes.Buffer
+	if err := loxc.Write(&compiled, script, statements, l.interpreter.Local); err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to compile %s with error: %v\n", script, err)
+		return 70
+	}
+	if err := os.WriteFile(*output, compiled.Bytes(), 0o644); err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to write %s with error: %v\n", *output, err)
+		return 74
+	}
+	return 0
+}
@@ -0,0 +1,117 @@
+package lox
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/neet-007/glox/pkg/conformance"
+	"github.com/neet-007/glox/pkg/vm"
+)
+
+// Conformance runs "glox conformance [flags] path..." over test files
+// annotated the way the Crafting Interpreters suite is and returns the exit
+// code, 1 when any of them does not conform.
+func Conformance(args []string) int {
+	flags := flag.NewFlagSet("conformance", flag.ContinueOnError)
+	verbose := flags.Bool("v", false, "list every file run, not only the failures")
+	format := flags.String("format", "text", "output format: text or json (an array of results)")
+	timeout := flags.Duration("timeout", 10*time.Second, "stop a test after this long (0 means no limit)")
+	backend := flags.String("backend", "tree", "how tests run: tree (walking the syntax tree) or vm (compiled to bytecode)")
+	if err := flags.Parse(args); err != nil {
+		return 64
+	}
+	if *format != "text" && *format != "json" {
+		fmt.Fprintf(os.Stderr, "Unknown conformance format %s\n", *format)
+		return 64
+	}
+	if *backend != "tree" && *backend != "vm" {
+		fmt.Fprintf(os.Stderr, "Unknown backend %s\n", *backend)
+		return 64
+	}
+	if flags.NArg() == 0 {
+		fmt.Fprintln(os.Stderr, "Usage: glox conformance [flags] path...")
+		return 64
+	}
+
+	runner := &conformance.Runner{Execute: executor(*backend == "vm", *timeout)}
+	results, err := runner.Run(flags.Args())
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to find tests: %v\n", err)
+		return 66
+	}
+
+	passed, failed, skipped := 0, 0, 0
+	for _, result := range results {
+		switch {
+		case result.Skipped:
+			skipped++
+		case result.Passed():
+			passed++
+		default:
+			failed++
+		}
+	}
+
+	switch *format {
+	case "json":
+		encoded, err := json.MarshalIndent(results, "", "  ")
+		if err != nil {
+			panic(err)
+		}
+		fmt.Printf("%s\n", encoded)
+	default:
+		for _, result := range results {
+			switch {
+			case !result.Passed():
+				fmt.Printf("FAIL\t%s\n", result.File)
+				for _, difference := range result.Differences {
+					fmt.Printf("    %s\n", difference)
+				}
+			case *verbose && result.Skipped:
+				fmt.Printf("skip\t%s\n", result.File)
+			case *verbose:
+				fmt.Printf("ok\t%s\n", result.File)
+			}
+		}
+		fmt.Printf("%d passed, %d failed, %d skipped\n", passed, failed, skipped)
+	}
+
+	if failed > 0 {
+		return 1
+	}
+	return 0
+}
+
+// executor runs conformance tests through the same pipeline as "glox
+// script", on the VM when bytecode is set, stopping each after timeout when
+// it is not 0.
+func executor(bytecode bool, timeout time.Duration) conformance.Executor {
+	return func(file string, source []byte) (outcome conformance.Outcome) {
+		var stdout, stderr bytes.Buffer
+		l := NewLox()
+		l.stderr = &stderr
+		l.timeout = timeout
+		l.interpreter.Stdout = &stdout
+		if bytecode {
+			l.vm = vm.New()
+			l.vm.Stdout = &stdout
+		}
+
+		defer func() {
+			if r := recover(); r != nil {
+				fmt.Fprintf(&stderr, "glox panicked: %v\n", r)
+				outcome.ExitCode = 70
+			}
+			outcome.Stdout = stdout.String()
+			outcome.Stderr = stderr.String()
+		}()
+
+		l.run(file, source)
+		outcome.ExitCode = l.exitCode()
+		return outcome
+	}
+}
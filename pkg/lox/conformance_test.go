@@ -0,0 +1,98 @@
+package lox
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/neet-007/glox/pkg/conformance"
+)
+
+func TestConformance(t *testing.T) {
+	for _, backend := range []string{"tree", "vm"} {
+		runner := &conformance.Runner{Execute: executor(backend == "vm", 0)}
+		results, err := runner.Run([]string{"testdata/conformance"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(results) == 0 {
+			t.Fatal("no tests found in testdata/conformance")
+		}
+
+		for _, result := range results {
+			t.Run(backend+"/"+filepath.Base(result.File), func(t *testing.T) {
+				if result.Skipped {
+					t.Skip("not a test")
+				}
+				for _, difference := range result.Differences {
+					t.Error(difference)
+				}
+			})
+		}
+	}
+}
+
+// TestBackendsAgree runs the fixtures and the benchmark scripts on both
+// backends and compares everything they write, stack traces included.
+func TestBackendsAgree(t *testing.T) {
+	fixtures, err := filepath.Glob("testdata/conformance/*.lox")
+	if err != nil {
+		t.Fatal(err)
+	}
+	scripts, err := filepath.Glob("../../bench/*.lox")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tree, vm := executor(false, 0), executor(true, 0)
+	for _, file := range append(fixtures, scripts...) {
+		t.Run(filepath.Base(file), func(t *testing.T) {
+			source, err := os.ReadFile(file)
+			if err != nil {
+				t.Fatal(err)
+			}
+			walked, compiled := tree(file, source), vm(file, source)
+			if walked != compiled {
+				t.Errorf("tree:\n%+v\nvm:\n%+v", walked, compiled)
+			}
+		})
+	}
+}
+
+// TestConformanceFails makes sure a test that does not conform is caught,
+// with the expectations of the fixtures changed.
+func TestConformanceFails(t *testing.T) {
+	tests := []struct {
+		file string
+		old  string
+		new  string
+	}{
+		{"output.lox", "// expect: hello", "// expect: goodbye"},
+		{"output.lox", "// expect: 7", ""},
+		{"compile_error.lox", "// Error at '=': Expect identefier for variable", ""},
+		{"runtime_error.lox", "// expect runtime error: Expect operands to be numbers", "// expect runtime error: Operands must be numbers."},
+		{"runtime_error.lox", "return w * \"x\"; // expect runtime error", "return w * \"x\";\n // expect runtime error"},
+	}
+
+	dir := t.TempDir()
+	execute := executor(false, 0)
+	for _, test := range tests {
+		source, err := os.ReadFile(filepath.Join("testdata/conformance", test.file))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Contains(source, []byte(test.old)) {
+			t.Fatalf("%s does not contain %q", test.file, test.old)
+		}
+		file := filepath.Join(dir, test.file)
+		if err := os.WriteFile(file, bytes.Replace(source, []byte(test.old), []byte(test.new), 1), 0o644); err != nil {
+			t.Fatal(err)
+		}
+
+		result := (&conformance.Runner{Execute: execute}).RunFile(file)
+		if result.Passed() {
+			t.Errorf("%s with %q in place of %q passed", test.file, test.new, test.old)
+		}
+	}
+}
@@ -0,0 +1,45 @@
+package lox
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+
+	"github.com/neet-007/glox/pkg/dap"
+	"github.com/neet-007/glox/pkg/interpreter"
+)
+
+// DebugAdapter runs "glox dap", a debug adapter on stdin and stdout, and
+// returns the exit code.
+func DebugAdapter(args []string) int {
+	flags := flag.NewFlagSet("dap", flag.ContinueOnError)
+	logFile := flags.String("log", "", "append a log of the requests handled to this file")
+	if err := flags.Parse(args); err != nil {
+		return 64
+	}
+
+	var logger *log.Logger
+	if *logFile != "" {
+		file, err := os.OpenFile(*logFile, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o644)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Failed to open log file %s with error: %v\n", *logFile, err)
+			return 66
+		}
+		defer file.Close()
+		logger = log.New(file, "glox dap: ", log.LstdFlags)
+	}
+
+	return dap.NewServer(os.Stdin, os.Stdout, logger, runScript).Run()
+}
+
+// runScript is the dap.Runner, running a script the way "glox script" does.
+func runScript(interpreter_ *interpreter.Interpreter, file string, source []byte, stderr io.Writer) int {
+	l := NewLox()
+	l.interpreter = interpreter_
+	l.stderr = stderr
+
+	l.run(file, source)
+	return l.exitCode()
+}
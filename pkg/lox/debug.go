@@ -0,0 +1,53 @@
+package lox
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/neet-007/glox/pkg/debugger"
+)
+
+// Debugger runs "glox debug [flags] script" under the interactive debugger
+// and returns the exit code.
+func Debugger(args []string) int {
+	flags := flag.NewFlagSet("debug", flag.ContinueOnError)
+	breakpoints := flags.String("break", "", "comma separated lines to stop on")
+	run := flags.Bool("run", false, "run to the first breakpoint instead of stopping on the first statement")
+	if err := flags.Parse(args); err != nil {
+		return 64
+	}
+	if flags.NArg() != 1 {
+		fmt.Fprintln(os.Stderr, "Usage: glox debug [flags] script")
+		return 64
+	}
+
+	file := flags.Arg(0)
+	source, err := os.ReadFile(file)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to open file %s with error: %v\n", file, err)
+		return 66
+	}
+
+	l := NewLox()
+	session := debugger.NewSession(l.interpreter, file, source, os.Stdin, os.Stdout)
+	if *breakpoints != "" {
+		for _, field := range strings.Split(*breakpoints, ",") {
+			line, err := strconv.Atoi(strings.TrimSpace(field))
+			if err != nil || line < 1 {
+				fmt.Fprintf(os.Stderr, "Bad breakpoint line %s\n", field)
+				return 64
+			}
+			session.SetBreakpoint(line)
+		}
+	}
+	if *run {
+		session.Continue()
+	}
+	l.interpreter.Hook = session
+
+	l.run(file, source)
+	return l.exitCode()
+}
@@ -0,0 +1,101 @@
+package lox
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/neet-007/glox/pkg/diagnostics"
+	"github.com/neet-007/glox/pkg/format"
+	"github.com/neet-007/glox/pkg/utils"
+)
+
+// Format runs "glox fmt [flags] [path...]" and returns the exit code. The
+// formatted scripts are printed unless -check or -write is given, with no
+// paths standard input is formatted.
+func Format(args []string) int {
+	flags := flag.NewFlagSet("fmt", flag.ContinueOnError)
+	check := flags.Bool("check", false, "list the files that are not formatted and exit with 1 if there are any")
+	write := flags.Bool("write", false, "write the formatted source back to the files")
+	if err := flags.Parse(args); err != nil {
+		return 64
+	}
+	if *check && *write {
+		fmt.Fprintln(os.Stderr, "-check and -write can't be used together")
+		return 64
+	}
+
+	if flags.NArg() == 0 {
+		if *write {
+			fmt.Fprintln(os.Stderr, "-write needs files to write to")
+			return 64
+		}
+		source, err := io.ReadAll(os.Stdin)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error reading input: %v\n", err)
+			return 66
+		}
+		return formatSource("<stdin>", source, *check, false)
+	}
+
+	files, err := utils.LoxFiles(flags.Args())
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to format: %v\n", err)
+		return 66
+	}
+
+	code := 0
+	for _, file := range files {
+		source, err := os.ReadFile(file)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Failed to open file %s with error: %v\n", file, err)
+			return 66
+		}
+
+		code = max(code, formatSource(file, source, *check, *write))
+	}
+
+	return code
+}
+
+func formatSource(file string, source []byte, check bool, write bool) int {
+	formatted, errors, err := format.Source(file, source)
+	if len(errors) > 0 {
+		for _, err := range errors {
+			fmt.Fprint(os.Stderr, diagnostics.Pretty(err, source))
+		}
+		return 65
+	}
+	if err != nil {
+		// a bug in the formatter, the file is left as it is
+		fmt.Fprintf(os.Stderr, "Failed to format %s: %v\n", file, err)
+		return 70
+	}
+
+	switch {
+	case check:
+		if !bytes.Equal(formatted, source) {
+			fmt.Println(file)
+			return 1
+		}
+	case write:
+		if bytes.Equal(formatted, source) {
+			return 0
+		}
+		info, err := os.Stat(file)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Failed to write %s: %v\n", file, err)
+			return 74
+		}
+		if err := os.WriteFile(file, formatted, info.Mode()); err != nil {
+			fmt.Fprintf(os.Stderr, "Failed to write %s: %v\n", file, err)
+			return 74
+		}
+	default:
+		os.Stdout.Write(formatted)
+	}
+
+	return 0
+}
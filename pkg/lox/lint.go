@@ -0,0 +1,72 @@
+package lox
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/neet-007/glox/pkg/diagnostics"
+	"github.com/neet-007/glox/pkg/lint"
+)
+
+// Lint runs "glox lint [flags] [path...]" and returns the exit code, 1 when
+// anything was reported.
+func Lint(args []string) int {
+	flags := flag.NewFlagSet("lint", flag.ContinueOnError)
+	format := flags.String("format", "text", "output format: text (one line per warning) or json (an array of warnings)")
+	configPath := flags.String("config", "", "rule configuration, "+lint.ConfigFile+" in the current directory or a parent by default")
+	if err := flags.Parse(args); err != nil {
+		return 64
+	}
+	if *format != "text" && *format != "json" {
+		fmt.Fprintf(os.Stderr, "Unknown lint format %s\n", *format)
+		return 64
+	}
+
+	if *configPath == "" {
+		*configPath = lint.FindConfig(".")
+	}
+	config := lint.NewConfig()
+	if *configPath != "" {
+		var err error
+		config, err = lint.LoadConfig(*configPath)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Failed to load lint config: %v\n", err)
+			return 64
+		}
+	}
+
+	paths := flags.Args()
+	if len(paths) == 0 {
+		paths = []string{"."}
+	}
+
+	reported, err := lint.NewLinter(config).LintPaths(paths)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to lint: %v\n", err)
+		return 66
+	}
+
+	switch *format {
+	case "json":
+		encoded, err := json.MarshalIndent(reported, "", "  ")
+		if err != nil {
+			panic(err)
+		}
+		fmt.Printf("%s\n", encoded)
+	default:
+		for _, diagnostic := range reported {
+			if lint.IsRule(diagnostic.Code) {
+				fmt.Printf("%s (%s)\n", diagnostic.Error(), diagnostics.RuleName(diagnostic.Code))
+				continue
+			}
+			fmt.Println(diagnostic.Error())
+		}
+	}
+
+	if len(reported) > 0 {
+		return 1
+	}
+	return 0
+}
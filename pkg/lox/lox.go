@@ -2,15 +2,28 @@ package lox
 
 import (
 	"bufio"
+	"context"
+	"errors"
 	"flag"
 	"fmt"
+	"io"
 	"os"
+	"path/filepath"
+	"time"
 
+	"github.com/neet-007/glox/pkg/coverage"
+	"github.com/neet-007/glox/pkg/debugger"
+	"github.com/neet-007/glox/pkg/diagnostics"
 	"github.com/neet-007/glox/pkg/interpreter"
+	"github.com/neet-007/glox/pkg/loxc"
+	"github.com/neet-007/glox/pkg/optimizer"
 	"github.com/neet-007/glox/pkg/parser"
+	"github.com/neet-007/glox/pkg/profile"
 	"github.com/neet-007/glox/pkg/resolver"
 	"github.com/neet-007/glox/pkg/scanner"
+	"github.com/neet-007/glox/pkg/trace"
 	"github.com/neet-007/glox/pkg/utils"
+	"github.com/neet-007/glox/pkg/vm"
 )
 
 type Lox struct {
@@ -19,27 +32,128 @@ type Lox struct {
 	hadRuntimeError bool
 	debug           bool
 	printAst        bool
+	timeout         time.Duration
+	diagnostics     string
+	warnings        bool
+	file            string
+	source          []byte
+	// optimize runs the optimizer over scripts once they are resolved.
+	optimize bool
+	// stderr is where diagnostics are reported, os.Stderr by default.
+	stderr io.Writer
+	// profiler, when set, is written to profileFile after the script runs.
+	profiler    *profile.Profiler
+	profileFile string
+	// coverage, when coverageFile is set, is made for the script once it
+	// is parsed and written after it runs.
+	coverage     *coverage.Coverage
+	coverageFile string
+	// vm, when set, runs scripts compiled to bytecode in place of the
+	// interpreter, which then only serves the resolver.
+	vm *vm.VM
 }
 
 func NewLox() *Lox {
 	return &Lox{
-		interpreter: interpreter.NewInterpreter(false),
+		interpreter: interpreter.NewInterpreter(),
+		diagnostics: "plain",
+		optimize:    true,
+		stderr:      os.Stderr,
 	}
 }
 
 func (l *Lox) Main() {
-	debug := flag.Bool("debug", false, "turn on debug mode")
-	printAst := flag.Bool("ast", false, "print parser AST")
+	if len(os.Args) > 1 {
+		switch os.Args[1] {
+		case "lint":
+			os.Exit(Lint(os.Args[2:]))
+		case "fmt":
+			os.Exit(Format(os.Args[2:]))
+		case "lsp":
+			os.Exit(LanguageServer(os.Args[2:]))
+		case "debug":
+			os.Exit(Debugger(os.Args[2:]))
+		case "dap":
+			os.Exit(DebugAdapter(os.Args[2:]))
+		case "test":
+			os.Exit(Test(os.Args[2:]))
+		case "conformance":
+			os.Exit(Conformance(os.Args[2:]))
+		case "compile":
+			os.Exit(Compile(os.Args[2:]))
+		}
+	}
+
+	debug := flag.Bool("debug", false, "turn on debug mode, tracing every event as text unless -trace is given")
+	traceFormat := flag.String("trace", "", "trace execution to stderr as text or json (one object per line)")
+	profileFile := flag.String("profile", "", "write a pprof profile of the lox functions called to this file")
+	coverageFile := flag.String("coverage", "", "write the statements and branches run to this file as LCOV and print a summary")
+	traceEvents := flag.String("trace-events", "", "comma separated events to trace, from statement, call, return, define, assign, error, resolve and branch (default all)")
+	printAst := flag.Bool("ast", false, "print parser AST, as optimized unless -no-opt is given")
+	noOpt := flag.Bool("no-opt", false, "run the script as written, without folding constants or dropping code that can never run")
+	noTCO := flag.Bool("no-tco", false, "give calls in tail position a frame of their own, keeping every call in stack traces (always the case with -debug, -trace, -profile, -coverage and the debuggers)")
+	backend := flag.String("backend", "tree", "how scripts run: tree (walking the syntax tree) or vm (compiled to bytecode)")
+	diagnostics := flag.String("diagnostics", "plain", "error output format: plain (one line per error), pretty (source line with a caret) or json (one object per line)")
+	warnings := flag.Bool("warnings", false, "report resolver warnings such as unused variables and unreachable code")
+	timeout := flag.Duration("timeout", 0, "stop the script after this long (0 means no limit)")
+	flag.IntVar(&l.interpreter.Limits.MaxSteps, "max-steps", 0, "maximum statements executed, instructions with -backend=vm (0 means no limit)")
+	flag.IntVar(&l.interpreter.Limits.MaxCallDepth, "max-call-depth", 0, "maximum nested calls (0 means no limit)")
+	flag.IntVar(&l.interpreter.Limits.MaxListLength, "max-list-length", 0, "maximum list length (0 means no limit)")
+	flag.IntVar(&l.interpreter.Limits.MaxStringLength, "max-string-length", 0, "maximum string length in bytes (0 means no limit)")
+	flag.IntVar(&l.interpreter.Limits.MaxAllocations, "max-allocations", 0, "maximum values allocated (0 means no limit)")
+	flag.IntVar(&l.interpreter.MaxStackDepth, "max-stack-depth", interpreter.DefaultMaxStackDepth, "call depth that raises a stack overflow error (0 turns the check off)")
 	flag.Parse()
 
 	l.debug = *debug
+	l.interpreter.TailCalls = !*noTCO
 	l.printAst = *printAst
-	l.interpreter.Debug = *debug
+	l.timeout = *timeout
+	l.diagnostics = *diagnostics
+	l.warnings = *warnings
+	if l.diagnostics != "pretty" && l.diagnostics != "plain" && l.diagnostics != "json" {
+		fmt.Fprintf(os.Stderr, "Unknown diagnostics format %s\n", l.diagnostics)
+		os.Exit(64)
+	}
+	if *debug && *traceFormat == "" {
+		*traceFormat = "text"
+	}
+	tracer, err := newTracer(*traceFormat, *traceEvents, os.Stderr)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(64)
+	}
+	l.interpreter.Tracer = tracer
+	if *profileFile != "" {
+		l.profileFile = *profileFile
+		l.profiler = profile.NewProfiler()
+		l.addTracer(l.profiler)
+	}
+	l.coverageFile = *coverageFile
+
+	switch *backend {
+	case "tree":
+	case "vm":
+		if l.interpreter.Tracer != nil || l.profiler != nil || l.coverageFile != "" {
+			fmt.Fprintln(os.Stderr, "-backend=vm does not support -debug, -trace, -profile or -coverage")
+			os.Exit(64)
+		}
+		l.vm = vm.New()
+		l.vm.Limits = l.interpreter.Limits
+		l.vm.MaxStackDepth = l.interpreter.MaxStackDepth
+		l.vm.TailCalls = l.interpreter.TailCalls
+	default:
+		fmt.Fprintf(os.Stderr, "Unknown backend %s\n", *backend)
+		os.Exit(64)
+	}
+
+	// tracing, coverage and the limits watch the script as it is written,
+	// which the optimizer would change.
+	l.optimize = !*noOpt && l.interpreter.Tracer == nil && l.coverageFile == "" && l.interpreter.Limits == (interpreter.Limits{})
 
 	args := flag.Args()
 
 	if len(args) > 1 {
-		fmt.Fprintln(os.Stderr, "Usage: glox --ast --debug [script]")
+		fmt.Fprintln(os.Stderr, "Usage: glox [flags] [script]\n       glox lint [flags] [path...]\n       glox fmt [flags] [path...]\n       glox lsp [flags]\n       glox debug [flags] script\n       glox dap [flags]\n       glox test [flags] [path...]\n       glox conformance [flags] path...\n       glox compile [flags] script")
 		os.Exit(64)
 	}
 
@@ -51,19 +165,82 @@ func (l *Lox) Main() {
 }
 
 func (l *Lox) runFile(filePath string) {
-	file, err := os.ReadFile(filePath)
+	if filepath.Ext(filePath) == loxc.Extension {
+		l.runCompiled(filePath)
+	} else {
+		file, err := os.ReadFile(filePath)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Failed to open file %s with error: %v\n", filePath, err)
+		}
+
+		l.run(filePath, file)
+	}
+	if l.profiler != nil {
+		if err := l.writeProfile(); err != nil {
+			fmt.Fprintf(os.Stderr, "Failed to write profile %s with error: %v\n", l.profileFile, err)
+			os.Exit(74)
+		}
+	}
+	if l.coverage != nil {
+		if err := l.writeCoverage(); err != nil {
+			fmt.Fprintf(os.Stderr, "Failed to write coverage %s with error: %v\n", l.coverageFile, err)
+			os.Exit(74)
+		}
+	}
+	if code := l.exitCode(); code != 0 {
+		os.Exit(code)
+	}
+}
+
+// addTracer adds tracer to the ones the interpreter tells about events.
+func (l *Lox) addTracer(tracer trace.Tracer) {
+	if l.interpreter.Tracer == nil {
+		l.interpreter.Tracer = tracer
+		return
+	}
+	l.interpreter.Tracer = trace.Multi(l.interpreter.Tracer, tracer)
+}
+
+func (l *Lox) writeCoverage() error {
+	file, err := os.Create(l.coverageFile)
 	if err != nil {
-		fmt.Fprintf(os.Stderr, "Failed to open file %s with error: %w\n", filePath, err)
+		return err
 	}
 
-	l.run(file)
-	if l.hadError {
-		os.Exit(65)
+	if err := l.coverage.WriteLCOV(file); err != nil {
+		file.Close()
+		return err
+	}
+	if err := file.Close(); err != nil {
+		return err
+	}
+
+	return coverage.WriteSummary(os.Stderr, l.coverage.Summary())
+}
+
+func (l *Lox) writeProfile() error {
+	file, err := os.Create(l.profileFile)
+	if err != nil {
+		return err
 	}
 
+	if _, err := l.profiler.WriteTo(file); err != nil {
+		file.Close()
+		return err
+	}
+	return file.Close()
+}
+
+// exitCode is the exit code for the last script run, 65 for a compile
+// error and 70 for a runtime error.
+func (l *Lox) exitCode() int {
+	if l.hadError {
+		return 65
+	}
 	if l.hadRuntimeError {
-		os.Exit(70)
+		return 70
 	}
+	return 0
 }
 
 func (l *Lox) runPromt() {
@@ -76,7 +253,7 @@ func (l *Lox) runPromt() {
 			if err.Error() == "EOF" {
 				break
 			}
-			fmt.Fprintf(os.Stderr, "Error reading input: %w\n", err)
+			fmt.Fprintf(os.Stderr, "Error reading input: %v\n", err)
 			break
 		}
 
@@ -84,61 +261,151 @@ func (l *Lox) runPromt() {
 			line = line[:len(line)-1]
 		}
 
-		l.run(line)
+		l.run("", line)
 		l.hadError = false
 	}
 }
 
-func (l *Lox) run(source []byte) {
-	scanner := scanner.NewScanner(source, l.debug)
+func (l *Lox) run(file string, source []byte) {
+	statements := l.resolve(file, source)
+	if l.hadError {
+		return
+	}
+
+	l.execute(file, statements)
+}
+
+// resolve scans, parses and resolves source, reporting what goes wrong. The
+// statements are only ready to run when that set no error.
+func (l *Lox) resolve(file string, source []byte) []parser.Stmt {
+	l.file = file
+	l.source = source
+
+	scanner := scanner.NewFileScanner(file, source, l.debug)
 	tokens, scannerErrors := scanner.Scan()
 
-	for _, err := range scannerErrors {
-		l.error(err.Token, err.Message)
-	}
+	l.report(scannerErrors...)
 
 	parser_ := parser.NewParser(tokens, l.debug)
 	statements, parserErrors := parser_.Parse()
 
-	for _, err := range parserErrors {
-		l.error(err.Token, err.Message)
+	l.report(parserErrors...)
+
+	if l.hadError {
+		l.printTree(statements)
+		return statements
 	}
 
-	if l.printAst {
-		astPrinter := utils.NewAstPrinter()
-		astPrinter.Print(statements)
+	resolver_ := resolver.NewResolver(l.interpreter)
+	resolver_.Tracer = l.interpreter.Tracer
+
+	compileErros := resolver_.Resolve(statements)
+	l.report(l.filterWarnings(scanner, compileErros)...)
+
+	if l.optimize && !l.hadError {
+		// the optimized statements are new nodes, some in other scopes than
+		// before, while what the resolver reports is for the script as
+		// written.
+		statements = optimizer.Optimize(statements)
+		resolver.NewResolver(l.interpreter).Resolve(statements)
 	}
 
-	if l.hadError {
+	l.printTree(statements)
+	return statements
+}
+
+// printTree prints statements when -ast is given.
+func (l *Lox) printTree(statements []parser.Stmt) {
+	if !l.printAst {
 		return
 	}
 
-	resolver_ := resolver.NewResolver(l.interpreter, l.debug)
+	astPrinter := utils.NewAstPrinter()
+	astPrinter.Print(statements)
+}
 
-	compileErros := resolver_.Resolve(statements)
-	for _, err := range compileErros {
-		l.error(err.Token, err.Message)
+// runCompiled runs a script compiled with "glox compile". Its source is not
+// at hand, so errors are reported without the source line.
+func (l *Lox) runCompiled(filePath string) {
+	file, err := os.Open(filePath)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to open file %s with error: %v\n", filePath, err)
+		os.Exit(66)
+	}
+	program, err := loxc.Read(file)
+	file.Close()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to load %s: %v\n", filePath, err)
+		os.Exit(65)
 	}
 
-	if l.hadError {
+	l.file = program.File
+	l.source = nil
+	program.Resolve(l.interpreter)
+	l.execute(program.File, program.Stmts)
+}
+
+// execute runs resolved statements with the interpreter or, given
+// -backend=vm, the VM.
+func (l *Lox) execute(file string, statements []parser.Stmt) {
+	if l.coverageFile != "" && l.coverage == nil {
+		l.coverage = coverage.New(file, statements)
+		l.addTracer(l.coverage)
+	}
+
+	ctx := context.Background()
+	if l.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, l.timeout)
+		defer cancel()
+	}
+
+	if l.vm != nil {
+		l.runBytecode(ctx, statements)
 		return
 	}
 
-	err := l.interpreter.Interpret(statements)
+	err := l.interpreter.InterpretContext(ctx, statements)
+	if err != nil && errors.Is(err, debugger.ErrQuit) {
+		return
+	}
 	if err != nil {
-		l.error(err.Token, err.Message)
+		l.hadRuntimeError = true
+		l.report(err.Diagnostic())
 	}
 }
 
-func (l *Lox) error(token scanner.Token, message string) {
-	if token.TokenType == scanner.EOF {
-		l.report(token.Line, " at end", message)
-	} else {
-		l.report(token.Line, " at '"+token.Lexeme+"'", message)
+// runBytecode compiles the resolved statements and runs them on the VM.
+func (l *Lox) runBytecode(ctx context.Context, statements []parser.Stmt) {
+	script, compileErrors := vm.Compile(statements)
+	l.report(compileErrors...)
+	if l.hadError {
+		return
+	}
+
+	if err := l.vm.Run(ctx, script); err != nil {
+		l.hadRuntimeError = true
+		l.report(err.Diagnostic())
 	}
 }
 
-func (l *Lox) report(line int, where string, message string) {
-	fmt.Fprintf(os.Stderr, "[line %d] Error %s: %s\n", line, where, message)
-	l.hadError = true
+// filterWarnings drops the warnings unless -warnings is given, and those
+// turned off with a "// glox:ignore" comment.
+func (l *Lox) filterWarnings(scanner_ *scanner.Scanner, reported []*diagnostics.Diagnostic) []*diagnostics.Diagnostic {
+	if !l.warnings {
+		kept := []*diagnostics.Diagnostic{}
+		for _, diagnostic := range reported {
+			if diagnostic.Severity == diagnostics.Error {
+				kept = append(kept, diagnostic)
+			}
+		}
+		return kept
+	}
+
+	suppressions := diagnostics.NewSuppressions()
+	for _, comment := range scanner_.Comments() {
+		suppressions.AddComment(comment.Line, comment.Text, comment.OwnLine)
+	}
+
+	return suppressions.Filter(reported)
 }
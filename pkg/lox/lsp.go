@@ -0,0 +1,33 @@
+package lox
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"os"
+
+	"github.com/neet-007/glox/pkg/lsp"
+)
+
+// LanguageServer runs "glox lsp", a language server on stdin and stdout,
+// and returns the exit code.
+func LanguageServer(args []string) int {
+	flags := flag.NewFlagSet("lsp", flag.ContinueOnError)
+	logFile := flags.String("log", "", "append a log of the messages handled to this file")
+	if err := flags.Parse(args); err != nil {
+		return 64
+	}
+
+	var logger *log.Logger
+	if *logFile != "" {
+		file, err := os.OpenFile(*logFile, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o644)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Failed to open log file %s with error: %v\n", *logFile, err)
+			return 66
+		}
+		defer file.Close()
+		logger = log.New(file, "glox lsp: ", log.LstdFlags)
+	}
+
+	return lsp.NewServer(os.Stdin, os.Stdout, logger).Run()
+}
@@ -0,0 +1,29 @@
+package lox
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/neet-007/glox/pkg/diagnostics"
+)
+
+func (l *Lox) report(reported ...*diagnostics.Diagnostic) {
+	for _, diagnostic := range reported {
+		if diagnostic.Severity == diagnostics.Error && diagnostic.Phase != diagnostics.PhaseRuntime {
+			l.hadError = true
+		}
+
+		switch l.diagnostics {
+		case "json":
+			encoded, err := json.Marshal(diagnostic)
+			if err != nil {
+				panic(err)
+			}
+			fmt.Fprintf(l.stderr, "%s\n", encoded)
+		case "pretty":
+			fmt.Fprint(l.stderr, diagnostics.Pretty(diagnostic, l.source))
+		default:
+			fmt.Fprint(l.stderr, diagnostics.Plain(diagnostic))
+		}
+	}
+}
@@ -0,0 +1,62 @@
+package lox
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"regexp"
+
+	"github.com/neet-007/glox/pkg/testrunner"
+)
+
+// Test runs "glox test [flags] [path...]" and returns the exit code, 1 when
+// any test failed.
+func Test(args []string) int {
+	flags := flag.NewFlagSet("test", flag.ContinueOnError)
+	verbose := flags.Bool("v", false, "list every test run, not only the failures")
+	run := flags.String("run", "", "only run the tests whose names match this regular expression")
+	format := flags.String("format", "text", "output format: text, json or junit (JUnit XML)")
+	if err := flags.Parse(args); err != nil {
+		return 64
+	}
+	if *format != "text" && *format != "json" && *format != "junit" {
+		fmt.Fprintf(os.Stderr, "Unknown test format %s\n", *format)
+		return 64
+	}
+
+	runner := &testrunner.Runner{}
+	if *run != "" {
+		match, err := regexp.Compile(*run)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Invalid -run pattern: %v\n", err)
+			return 64
+		}
+		runner.Match = match
+	}
+
+	files, err := testrunner.Discover(flags.Args())
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to find tests: %v\n", err)
+		return 66
+	}
+
+	report := runner.Run(files)
+
+	switch *format {
+	case "json":
+		err = testrunner.WriteJSON(os.Stdout, report)
+	case "junit":
+		err = testrunner.WriteJUnit(os.Stdout, report)
+	default:
+		err = testrunner.WriteText(os.Stdout, report, *verbose)
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to write the results: %v\n", err)
+		return 74
+	}
+
+	if report.Failed() {
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,62 @@
+package lox
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestTestExitCode(t *testing.T) {
+	dir := t.TempDir()
+	files := map[string]string{
+		"pass_test.lox":   "fun testPass() { assertEqual(2, 1 + 1); }\n",
+		"fail_test.lox":   "fun testFail() { assertEqual(3, 1 + 1); }\n",
+		"broken_test.lox": "fun testBroken() { var = 1; }\n",
+	}
+	for name, source := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(source), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := []struct {
+		name   string
+		args   []string
+		code   int
+		output string
+	}{
+		{"pass", []string{filepath.Join(dir, "pass_test.lox")}, 0, ""},
+		{"fail", []string{filepath.Join(dir, "pass_test.lox"), filepath.Join(dir, "fail_test.lox")}, 1, "expected 3, got 2"},
+		{"compile error", []string{filepath.Join(dir, "broken_test.lox")}, 1, "broken_test.lox"},
+		{"run only passing", []string{"-run", "Pass", filepath.Join(dir, "pass_test.lox"), filepath.Join(dir, "fail_test.lox")}, 0, ""},
+		{"bad format", []string{"-format", "yaml", dir}, 64, ""},
+		{"bad pattern", []string{"-run", "(", dir}, 64, ""},
+		{"missing", []string{filepath.Join(dir, "missing")}, 66, ""},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			out, err := os.CreateTemp(t.TempDir(), "stdout")
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer out.Close()
+			stdout, stderr := os.Stdout, os.Stderr
+			os.Stdout, os.Stderr = out, out
+			code := Test(test.args)
+			os.Stdout, os.Stderr = stdout, stderr
+
+			written, err := os.ReadFile(out.Name())
+			if err != nil {
+				t.Fatal(err)
+			}
+			if code != test.code {
+				t.Errorf("exited with %d, want %d:\n%s", code, test.code, written)
+			}
+			if !strings.Contains(string(written), test.output) {
+				t.Errorf("wrote\n%s\nwant %q in it", written, test.output)
+			}
+		})
+	}
+}
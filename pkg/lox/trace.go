@@ -0,0 +1,37 @@
+package lox
+
+import (
+	"fmt"
+	"io"
+
+	"github.com/neet-007/glox/pkg/trace"
+)
+
+// newTracer makes the tracer for -trace and -trace-events, nil when format
+// is empty.
+func newTracer(format string, events string, w io.Writer) (trace.Tracer, error) {
+	var tracer trace.Tracer
+	switch format {
+	case "":
+		if events != "" {
+			return nil, fmt.Errorf("-trace-events needs -trace")
+		}
+		return nil, nil
+	case "text":
+		tracer = trace.Text(w)
+	case "json":
+		tracer = trace.JSON(w)
+	default:
+		return nil, fmt.Errorf("Unknown trace format %s", format)
+	}
+
+	if events != "" {
+		kinds, err := trace.ParseKinds(events)
+		if err != nil {
+			return nil, err
+		}
+		tracer = trace.Filter(tracer, kinds...)
+	}
+
+	return tracer, nil
+}
@@ -0,0 +1,169 @@
+package loxc
+
+import (
+	"fmt"
+
+	"github.com/neet-007/glox/pkg/parser"
+)
+
+// checker follows the scopes of a decoded script the way the resolver made
+// them, so that a local whose depth or slot was damaged is caught before
+// the interpreter looks for it in an environment that does not have it.
+type checker struct {
+	locals map[parser.ID]local
+	// scopes holds how many locals each enclosing scope has declared so
+	// far, innermost last.
+	scopes []int
+	err    error
+}
+
+// checkLocals returns a *FormatError when a local in stmts points outside
+// of the scopes around it.
+func checkLocals(stmts []parser.Stmt, locals []local) error {
+	c := &checker{locals: make(map[parser.ID]local, len(locals))}
+	for _, local := range locals {
+		c.locals[local.expr.ID()] = local
+	}
+	c.stmts(stmts)
+	return c.err
+}
+
+func (c *checker) fail(format string, args ...any) {
+	if c.err == nil {
+		c.err = &FormatError{Message: fmt.Sprintf(format, args...)}
+	}
+}
+
+func (c *checker) begin(locals int) {
+	c.scopes = append(c.scopes, locals)
+}
+
+func (c *checker) end() {
+	c.scopes = c.scopes[:len(c.scopes)-1]
+}
+
+// declare gives a variable declared in the current scope its slot, globals
+// having none.
+func (c *checker) declare() {
+	if len(c.scopes) > 0 {
+		c.scopes[len(c.scopes)-1]++
+	}
+}
+
+// resolved checks the local expr refers to, if it is not a global.
+func (c *checker) resolved(expr parser.Expr, name string) {
+	local, ok := c.locals[expr.ID()]
+	if !ok {
+		return
+	}
+	if local.depth >= len(c.scopes) {
+		c.fail("%s resolved %d scopes out with %d around it", name, local.depth, len(c.scopes))
+		return
+	}
+	if declared := c.scopes[len(c.scopes)-1-local.depth]; local.slot >= declared {
+		c.fail("%s resolved to slot %d of a scope with %d locals", name, local.slot, declared)
+	}
+}
+
+func (c *checker) stmts(stmts []parser.Stmt) {
+	for _, stmt := range stmts {
+		c.stmt(stmt)
+	}
+}
+
+func (c *checker) stmt(stmt parser.Stmt) {
+	switch stmt := stmt.(type) {
+	case parser.Class:
+		c.declare()
+		var noSuperclass parser.Variable
+		subclass := stmt.SuperClass != noSuperclass
+		if subclass {
+			c.expr(stmt.SuperClass)
+			// "super"
+			c.begin(1)
+		}
+		// "this"
+		c.begin(1)
+		for _, method := range stmt.Methods {
+			c.function(method)
+		}
+		c.end()
+		if subclass {
+			c.end()
+		}
+	case parser.Return:
+		c.expr(stmt.Value)
+	case parser.Function:
+		c.declare()
+		c.function(stmt)
+	case parser.VarDeclaration:
+		c.declare()
+		c.expr(stmt.Initizlier)
+	case parser.WhileStmt:
+		c.expr(stmt.Condition)
+		c.stmt(stmt.Body)
+	case parser.Block:
+		c.begin(0)
+		c.stmts(stmt.Statements)
+		c.end()
+	case parser.IfStmt:
+		c.expr(stmt.Condition)
+		c.stmt(stmt.ThenBranch)
+		c.stmt(stmt.ElseBranch)
+	case parser.ExpressionStmt:
+		c.expr(stmt.Expression)
+	case parser.PrintStmt:
+		c.expr(stmt.Expression)
+	}
+}
+
+func (c *checker) function(function parser.Function) {
+	c.begin(len(function.Parameters))
+	c.stmts(function.Body)
+	c.end()
+}
+
+func (c *checker) exprs(exprs []parser.Expr) {
+	for _, expr := range exprs {
+		c.expr(expr)
+	}
+}
+
+func (c *checker) expr(expr parser.Expr) {
+	switch expr := expr.(type) {
+	case parser.ListSet:
+		c.exprs([]parser.Expr{expr.List, expr.Index, expr.Value})
+	case parser.ListGet:
+		c.exprs([]parser.Expr{expr.List, expr.Index})
+	case parser.ListExpr:
+		c.exprs(expr.Literals)
+	case parser.Super:
+		// "this" is looked up one scope in from "super"
+		if local, ok := c.locals[expr.ID()]; ok && local.depth < 1 {
+			c.fail("super resolved %d scopes out", local.depth)
+		}
+		c.resolved(expr, "super")
+	case parser.This:
+		c.resolved(expr, "this")
+	case parser.Set:
+		c.exprs([]parser.Expr{expr.Value, expr.Object})
+	case parser.Get:
+		c.expr(expr.Object)
+	case parser.Call:
+		c.expr(expr.Callee)
+		c.exprs(expr.Arguments)
+	case parser.Variable:
+		c.resolved(expr, expr.Name.Lexeme)
+	case parser.Assign:
+		c.expr(expr.Expr)
+		c.resolved(expr, expr.Lexem.Lexeme)
+	case parser.Binary:
+		c.exprs([]parser.Expr{expr.Left, expr.Right})
+	case parser.Grouping:
+		c.expr(expr.Expr)
+	case parser.Logical:
+		c.exprs([]parser.Expr{expr.Left, expr.Right})
+	case parser.Unary:
+		c.expr(expr.Right)
+	}
+}
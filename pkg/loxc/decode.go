@@ -0,0 +1,362 @@
+package loxc
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math"
+
+	"github.com/neet-007/glox/pkg/parser"
+	"github.com/neet-007/glox/pkg/scanner"
+)
+
+// decoder reads a compiled script, the first problem it finds is kept in
+// err and every read after it returns zero values.
+type decoder struct {
+	data    []byte
+	pos     int
+	err     error
+	file    string
+	strings []string
+	locals  []local
+}
+
+// Read reads a compiled script back. A script compiled to another version
+// of the format gives a *VersionError, anything else that cannot be read a
+// *FormatError.
+func Read(r io.Reader) (*Program, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	if !bytes.HasPrefix(data, magic) {
+		return nil, &FormatError{Message: "missing LOXC header"}
+	}
+
+	d := &decoder{data: data, pos: len(magic)}
+	if version := d.uvarint(); d.err == nil && version != FormatVersion {
+		return nil, &VersionError{Version: int(version)}
+	}
+	d.file = d.rawString()
+	d.strings = make([]string, d.count())
+	for i := range d.strings {
+		d.strings[i] = d.rawString()
+	}
+	stmts := make([]parser.Stmt, d.count())
+	for i := range stmts {
+		stmts[i] = d.stmt()
+	}
+	if d.err == nil && d.pos != len(d.data) {
+		d.fail("unexpected data after the statements")
+	}
+	if d.err == nil {
+		d.err = checkLocals(stmts, d.locals)
+	}
+	if d.err != nil {
+		return nil, d.err
+	}
+
+	return &Program{File: d.file, Stmts: stmts, locals: d.locals}, nil
+}
+
+func (d *decoder) fail(format string, args ...any) {
+	if d.err == nil {
+		d.err = &FormatError{Message: fmt.Sprintf(format, args...) + fmt.Sprintf(" at byte %d", d.pos)}
+	}
+}
+
+func (d *decoder) byte() byte {
+	if d.err != nil {
+		return 0
+	}
+	if d.pos >= len(d.data) {
+		d.fail("unexpected end of file")
+		return 0
+	}
+	b := d.data[d.pos]
+	d.pos++
+	return b
+}
+
+func (d *decoder) uvarint() uint64 {
+	if d.err != nil {
+		return 0
+	}
+	x, n := binary.Uvarint(d.data[d.pos:])
+	if n <= 0 {
+		d.fail("bad number")
+		return 0
+	}
+	d.pos += n
+	return x
+}
+
+func (d *decoder) varint() int64 {
+	if d.err != nil {
+		return 0
+	}
+	x, n := binary.Varint(d.data[d.pos:])
+	if n <= 0 {
+		d.fail("bad number")
+		return 0
+	}
+	d.pos += n
+	return x
+}
+
+func (d *decoder) int() int {
+	x := d.uvarint()
+	if x > math.MaxInt32 {
+		d.fail("number %d out of range", x)
+		return 0
+	}
+	return int(x)
+}
+
+// count reads the length of a list, which can be no longer than what is
+// left of the file as every element takes at least a byte.
+func (d *decoder) count() int {
+	n := d.uvarint()
+	if n > uint64(len(d.data)-d.pos) {
+		d.fail("length %d past the end of file", n)
+		return 0
+	}
+	return int(n)
+}
+
+func (d *decoder) rawString() string {
+	n := d.count()
+	if d.err != nil {
+		return ""
+	}
+	s := string(d.data[d.pos : d.pos+n])
+	d.pos += n
+	return s
+}
+
+func (d *decoder) string() string {
+	index := d.uvarint()
+	if d.err != nil {
+		return ""
+	}
+	if index >= uint64(len(d.strings)) {
+		d.fail("string %d not in the table", index)
+		return ""
+	}
+	return d.strings[index]
+}
+
+func (d *decoder) value() any {
+	switch tag := d.byte(); tag {
+	case valueNil:
+		return nil
+	case valueTrue:
+		return true
+	case valueFalse:
+		return false
+	case valueNumber:
+		if d.err != nil || len(d.data)-d.pos < 8 {
+			d.fail("unexpected end of file")
+			return nil
+		}
+		bits := binary.LittleEndian.Uint64(d.data[d.pos:])
+		d.pos += 8
+		return math.Float64frombits(bits)
+	case valueString:
+		return d.string()
+	default:
+		d.fail("unknown value tag %d", tag)
+		return nil
+	}
+}
+
+func (d *decoder) token() scanner.Token {
+	return scanner.Token{
+		TokenType: scanner.TokenType(d.int()),
+		Lexeme:    d.string(),
+		Line:      d.int(),
+		Column:    d.int(),
+		Offset:    d.int(),
+		File:      d.file,
+		Literal:   d.value(),
+	}
+}
+
+// resolved records the depth and slot that follow expr, when it is not a
+// global.
+func (d *decoder) resolved(expr parser.Expr) {
+	depth := d.varint()
+	if depth < -1 || depth > math.MaxInt32 {
+		d.fail("depth %d out of range", depth)
+		return
+	}
+	if depth >= 0 {
+		d.locals = append(d.locals, local{expr: expr, depth: int(depth), slot: d.int()})
+	}
+}
+
+func (d *decoder) stmts() []parser.Stmt {
+	stmts := make([]parser.Stmt, d.count())
+	for i := range stmts {
+		stmts[i] = d.stmt()
+	}
+	return stmts
+}
+
+// stmt reads a statement that must be there.
+func (d *decoder) stmt() parser.Stmt {
+	stmt := d.optionalStmt()
+	if stmt == nil {
+		d.fail("missing statement")
+	}
+	return stmt
+}
+
+// optionalStmt reads a statement that can be left out, as an else branch
+// can.
+func (d *decoder) optionalStmt() parser.Stmt {
+	switch tag := d.byte(); tag {
+	case tagNil:
+		return nil
+	case tagClass:
+		name := d.token()
+		methods := make([]parser.Function, d.count())
+		for i := range methods {
+			methods[i] = d.function()
+		}
+		var superclass parser.Variable
+		switch expr := d.optionalExpr().(type) {
+		case nil:
+		case parser.Variable:
+			superclass = expr
+		default:
+			d.fail("superclass of %s is a %T", name.Lexeme, expr)
+		}
+		return parser.NewClass(name, methods, superclass)
+	case tagReturn:
+		keyword := d.token()
+		return parser.NewReturn(keyword, d.optionalExpr())
+	case tagFunction:
+		return d.function()
+	case tagVarDeclaration:
+		initializer := d.optionalExpr()
+		return parser.NewVarDeclaration(d.token(), initializer)
+	case tagWhile:
+		keyword := d.token()
+		condition := d.expr()
+		return parser.NewWhileStmt(keyword, condition, d.stmt())
+	case tagBlock:
+		brace := d.token()
+		return parser.NewBlock(brace, d.stmts())
+	case tagIf:
+		keyword := d.token()
+		condition := d.expr()
+		thenBranch := d.stmt()
+		return parser.NewIfStmt(keyword, condition, thenBranch, d.optionalStmt())
+	case tagExpressionStmt:
+		start := d.token()
+		return parser.NewExpressionStmt(start, d.expr())
+	case tagPrint:
+		keyword := d.token()
+		return parser.NewPrintStmt(keyword, d.expr())
+	default:
+		d.fail("unknown statement tag %d", tag)
+		return nil
+	}
+}
+
+func (d *decoder) function() parser.Function {
+	name := d.token()
+	parameters := make([]scanner.Token, d.count())
+	for i := range parameters {
+		parameters[i] = d.token()
+	}
+	return parser.NewFunction(name, parameters, d.stmts())
+}
+
+func (d *decoder) exprs() []parser.Expr {
+	exprs := make([]parser.Expr, d.count())
+	for i := range exprs {
+		exprs[i] = d.expr()
+	}
+	return exprs
+}
+
+// expr reads an expression that must be there.
+func (d *decoder) expr() parser.Expr {
+	expr := d.optionalExpr()
+	if expr == nil {
+		d.fail("missing expression")
+	}
+	return expr
+}
+
+// optionalExpr reads an expression that can be left out, as the value of a
+// return, the initializer of a variable and a superclass can.
+func (d *decoder) optionalExpr() parser.Expr {
+	switch tag := d.byte(); tag {
+	case tagNil:
+		return nil
+	case tagListSet:
+		list := d.expr()
+		index := d.expr()
+		value := d.expr()
+		return parser.NewListSet(list, index, value, d.token())
+	case tagListGet:
+		list := d.expr()
+		index := d.expr()
+		return parser.NewListGet(list, index, d.token())
+	case tagList:
+		literals := d.exprs()
+		leftBracket := d.token()
+		return parser.NewListExpr(leftBracket, d.token(), literals)
+	case tagSuper:
+		keyword := d.token()
+		expr := parser.NewSuper(keyword, d.token())
+		d.resolved(expr)
+		return expr
+	case tagThis:
+		expr := parser.NewThis(d.token())
+		d.resolved(expr)
+		return expr
+	case tagSet:
+		value := d.expr()
+		object := d.expr()
+		return parser.NewSet(value, object, d.token())
+	case tagGet:
+		object := d.expr()
+		return parser.NewGet(object, d.token())
+	case tagCall:
+		callee := d.expr()
+		paren := d.token()
+		return parser.NewCall(callee, paren, d.exprs())
+	case tagVariable:
+		expr := parser.NewVariable(d.token())
+		d.resolved(expr)
+		return expr
+	case tagAssign:
+		lexem := d.token()
+		expr := parser.NewAssign(lexem, d.expr())
+		d.resolved(expr)
+		return expr
+	case tagBinary:
+		left := d.expr()
+		right := d.expr()
+		return parser.NewBinary(left, right, d.token())
+	case tagGrouping:
+		return parser.NewGrouping(d.expr())
+	case tagLiteral:
+		return parser.NewLiteral(d.value())
+	case tagLogical:
+		left := d.expr()
+		right := d.expr()
+		return parser.NewLogical(left, right, d.token())
+	case tagUnary:
+		right := d.expr()
+		return parser.NewUnary(right, d.token())
+	default:
+		d.fail("unknown expression tag %d", tag)
+		return nil
+	}
+}
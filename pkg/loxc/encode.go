@@ -0,0 +1,312 @@
+package loxc
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math"
+
+	"github.com/neet-007/glox/pkg/parser"
+	"github.com/neet-007/glox/pkg/scanner"
+)
+
+const (
+	tagNil byte = iota
+	tagClass
+	tagReturn
+	tagFunction
+	tagVarDeclaration
+	tagWhile
+	tagBlock
+	tagIf
+	tagExpressionStmt
+	tagPrint
+	tagListSet
+	tagListGet
+	tagList
+	tagSuper
+	tagThis
+	tagSet
+	tagGet
+	tagCall
+	tagVariable
+	tagAssign
+	tagBinary
+	tagGrouping
+	tagLiteral
+	tagLogical
+	tagUnary
+)
+
+const (
+	valueNil byte = iota
+	valueTrue
+	valueFalse
+	valueNumber
+	valueString
+)
+
+// Locals tells the encoder where the resolver found the variable of expr,
+// false for globals.
+type Locals func(expr parser.Expr) (depth int, slot int, ok bool)
+
+// encoder writes a compiled script, the first node it cannot write is kept
+// in err.
+type encoder struct {
+	err     error
+	buf     []byte
+	strings map[string]uint64
+	table   []string
+	locals  Locals
+}
+
+// Write writes stmts, the resolved statements of file, in compiled form. A
+// node or literal the format has no place for is an error, and nothing is
+// written.
+func Write(w io.Writer, file string, stmts []parser.Stmt, locals Locals) error {
+	e := &encoder{strings: map[string]uint64{}, locals: locals}
+	e.uvarint(uint64(len(stmts)))
+	for _, stmt := range stmts {
+		e.stmt(stmt)
+	}
+	if e.err != nil {
+		return e.err
+	}
+	body := e.buf
+
+	e.buf = append([]byte{}, magic...)
+	e.uvarint(FormatVersion)
+	e.buf = binary.AppendUvarint(e.buf, uint64(len(file)))
+	e.buf = append(e.buf, file...)
+	e.uvarint(uint64(len(e.table)))
+	for _, s := range e.table {
+		e.buf = binary.AppendUvarint(e.buf, uint64(len(s)))
+		e.buf = append(e.buf, s...)
+	}
+
+	if _, err := w.Write(e.buf); err != nil {
+		return err
+	}
+	_, err := w.Write(body)
+	return err
+}
+
+func (e *encoder) fail(format string, args ...any) {
+	if e.err == nil {
+		e.err = fmt.Errorf("loxc: "+format, args...)
+	}
+}
+
+func (e *encoder) uvarint(x uint64) {
+	e.buf = binary.AppendUvarint(e.buf, x)
+}
+
+func (e *encoder) varint(x int64) {
+	e.buf = binary.AppendVarint(e.buf, x)
+}
+
+func (e *encoder) string(s string) {
+	index, ok := e.strings[s]
+	if !ok {
+		index = uint64(len(e.table))
+		e.strings[s] = index
+		e.table = append(e.table, s)
+	}
+	e.uvarint(index)
+}
+
+func (e *encoder) value(value any) {
+	switch value := value.(type) {
+	case nil:
+		e.buf = append(e.buf, valueNil)
+	case bool:
+		if value {
+			e.buf = append(e.buf, valueTrue)
+		} else {
+			e.buf = append(e.buf, valueFalse)
+		}
+	case float64:
+		e.buf = append(e.buf, valueNumber)
+		e.buf = binary.LittleEndian.AppendUint64(e.buf, math.Float64bits(value))
+	case string:
+		e.buf = append(e.buf, valueString)
+		e.string(value)
+	default:
+		e.fail("cannot compile literal %v of type %T", value, value)
+	}
+}
+
+func (e *encoder) token(token scanner.Token) {
+	e.uvarint(uint64(token.TokenType))
+	e.string(token.Lexeme)
+	e.uvarint(uint64(token.Line))
+	e.uvarint(uint64(token.Column))
+	e.uvarint(uint64(token.Offset))
+	e.value(token.Literal)
+}
+
+func (e *encoder) local(expr parser.Expr) {
+	depth, slot, ok := e.locals(expr)
+	if !ok {
+		e.varint(-1)
+		return
+	}
+	e.varint(int64(depth))
+	e.uvarint(uint64(slot))
+}
+
+func (e *encoder) stmts(stmts []parser.Stmt) {
+	e.uvarint(uint64(len(stmts)))
+	for _, stmt := range stmts {
+		e.stmt(stmt)
+	}
+}
+
+func (e *encoder) stmt(stmt parser.Stmt) {
+	switch stmt := stmt.(type) {
+	case nil:
+		e.buf = append(e.buf, tagNil)
+	case parser.Class:
+		e.buf = append(e.buf, tagClass)
+		e.token(stmt.Name)
+		e.uvarint(uint64(len(stmt.Methods)))
+		for _, method := range stmt.Methods {
+			e.function(method)
+		}
+		var noSuperclass parser.Variable
+		if stmt.SuperClass == noSuperclass {
+			e.expr(nil)
+		} else {
+			e.expr(stmt.SuperClass)
+		}
+	case parser.Return:
+		e.buf = append(e.buf, tagReturn)
+		e.token(stmt.Keyword)
+		e.expr(stmt.Value)
+	case parser.Function:
+		e.buf = append(e.buf, tagFunction)
+		e.function(stmt)
+	case parser.VarDeclaration:
+		e.buf = append(e.buf, tagVarDeclaration)
+		e.expr(stmt.Initizlier)
+		e.token(stmt.Name)
+	case parser.WhileStmt:
+		e.buf = append(e.buf, tagWhile)
+		e.token(stmt.Keyword)
+		e.expr(stmt.Condition)
+		e.stmt(stmt.Body)
+	case parser.Block:
+		e.buf = append(e.buf, tagBlock)
+		e.token(stmt.Brace)
+		e.stmts(stmt.Statements)
+	case parser.IfStmt:
+		e.buf = append(e.buf, tagIf)
+		e.token(stmt.Keyword)
+		e.expr(stmt.Condition)
+		e.stmt(stmt.ThenBranch)
+		e.stmt(stmt.ElseBranch)
+	case parser.ExpressionStmt:
+		e.buf = append(e.buf, tagExpressionStmt)
+		e.token(stmt.Start)
+		e.expr(stmt.Expression)
+	case parser.PrintStmt:
+		e.buf = append(e.buf, tagPrint)
+		e.token(stmt.Keyword)
+		e.expr(stmt.Expression)
+	default:
+		e.fail("cannot compile statement %T", stmt)
+	}
+}
+
+func (e *encoder) function(function parser.Function) {
+	e.token(function.Name)
+	e.uvarint(uint64(len(function.Parameters)))
+	for _, parameter := range function.Parameters {
+		e.token(parameter)
+	}
+	e.stmts(function.Body)
+}
+
+func (e *encoder) exprs(exprs []parser.Expr) {
+	e.uvarint(uint64(len(exprs)))
+	for _, expr := range exprs {
+		e.expr(expr)
+	}
+}
+
+func (e *encoder) expr(expr parser.Expr) {
+	switch expr := expr.(type) {
+	case nil:
+		e.buf = append(e.buf, tagNil)
+	case parser.ListSet:
+		e.buf = append(e.buf, tagListSet)
+		e.expr(expr.List)
+		e.expr(expr.Index)
+		e.expr(expr.Value)
+		e.token(expr.Token)
+	case parser.ListGet:
+		e.buf = append(e.buf, tagListGet)
+		e.expr(expr.List)
+		e.expr(expr.Index)
+		e.token(expr.Token)
+	case parser.ListExpr:
+		e.buf = append(e.buf, tagList)
+		e.exprs(expr.Literals)
+		e.token(expr.LeftBracket)
+		e.token(expr.RightBracket)
+	case parser.Super:
+		e.buf = append(e.buf, tagSuper)
+		e.token(expr.Keyword)
+		e.token(expr.Method)
+		e.local(expr)
+	case parser.This:
+		e.buf = append(e.buf, tagThis)
+		e.token(expr.Keyword)
+		e.local(expr)
+	case parser.Set:
+		e.buf = append(e.buf, tagSet)
+		e.expr(expr.Value)
+		e.expr(expr.Object)
+		e.token(expr.Name)
+	case parser.Get:
+		e.buf = append(e.buf, tagGet)
+		e.expr(expr.Object)
+		e.token(expr.Name)
+	case parser.Call:
+		e.buf = append(e.buf, tagCall)
+		e.expr(expr.Callee)
+		e.token(expr.Paren)
+		e.exprs(expr.Arguments)
+	case parser.Variable:
+		e.buf = append(e.buf, tagVariable)
+		e.token(expr.Name)
+		e.local(expr)
+	case parser.Assign:
+		e.buf = append(e.buf, tagAssign)
+		e.token(expr.Lexem)
+		e.expr(expr.Expr)
+		e.local(expr)
+	case parser.Binary:
+		e.buf = append(e.buf, tagBinary)
+		e.expr(expr.Left)
+		e.expr(expr.Right)
+		e.token(expr.Operator)
+	case parser.Grouping:
+		e.buf = append(e.buf, tagGrouping)
+		e.expr(expr.Expr)
+	case parser.Literal:
+		e.buf = append(e.buf, tagLiteral)
+		e.value(expr.Value)
+	case parser.Logical:
+		e.buf = append(e.buf, tagLogical)
+		e.expr(expr.Left)
+		e.expr(expr.Right)
+		e.token(expr.Operator)
+	case parser.Unary:
+		e.buf = append(e.buf, tagUnary)
+		e.expr(expr.Right)
+		e.token(expr.Operator)
+	default:
+		e.fail("cannot compile expression %T", expr)
+	}
+}
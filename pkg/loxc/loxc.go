@@ -0,0 +1,73 @@
+package loxc
+
+import (
+	"fmt"
+
+	"github.com/neet-007/glox/pkg/interpreter"
+	"github.com/neet-007/glox/pkg/parser"
+)
+
+/*
+ NOTE:
+	a compiled script is
+
+		"LOXC" version file strings statements
+
+	numbers are varints, strings indexes into the string table that
+	follows the file name, and nodes a tag followed by their fields in the
+	order the parser's structs declare them. variables, assignments, this
+	and super carry the depth the resolver found them at, -1 for globals,
+	followed for locals by their slot.
+	tokens keep their line, column and offset so errors point into the
+	original source. any change to this layout must bump FormatVersion.
+*/
+
+// Extension is what compiled scripts are named with.
+const Extension = ".loxc"
+
+// FormatVersion is the version of the format written and the only one read.
+const FormatVersion = 2
+
+var magic = []byte("LOXC")
+
+// Program is a script read back from its compiled form.
+type Program struct {
+	File  string
+	Stmts []parser.Stmt
+	// locals are the resolved expressions and where they were resolved to.
+	locals []local
+}
+
+type local struct {
+	expr  parser.Expr
+	depth int
+	slot  int
+}
+
+// Resolve tells interpreter where the program's variables are, as the
+// resolver did when it was compiled.
+func (p *Program) Resolve(interpreter_ *interpreter.Interpreter) {
+	for _, local := range p.locals {
+		interpreter_.ResolveExpr(local.expr, local.depth, local.slot)
+	}
+}
+
+// VersionError is returned for a script compiled to another version of the
+// format, which has to be compiled again.
+type VersionError struct {
+	Version int
+}
+
+func (v *VersionError) Error() string {
+	return fmt.Sprintf("compiled with format version %d, this glox reads version %d: compile the script again", v.Version, FormatVersion)
+}
+
+// FormatError is returned for a file that is not a compiled script or is
+// damaged.
+type FormatError struct {
+	Message string
+}
+
+func (f *FormatError) Error() string {
+	return "not a valid compiled script: " + f.Message
+}
@@ -0,0 +1,313 @@
+package loxc
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/neet-007/glox/pkg/interpreter"
+	"github.com/neet-007/glox/pkg/parser"
+	"github.com/neet-007/glox/pkg/resolver"
+	"github.com/neet-007/glox/pkg/scanner"
+)
+
+// script uses every statement and expression the format has a tag for.
+const script = `class Base {
+  init(n) { this.n = n; }
+  get() { return this.n; }
+}
+class Derived < Base {
+  get() { return super.get() * 2; }
+}
+fun counter() {
+  var count = 0;
+  fun next() {
+    count = count + 1;
+    return count;
+  }
+  return next;
+}
+var c = counter();
+c();
+var l = [1, "two", nil, true];
+l[2] = false;
+var i = 0;
+while (i < 3) {
+  if (i == 1 and !false) print l[i]; else print -i;
+  i = i + 1;
+}
+{
+  var d = Derived(21);
+  print (d.get() or nil);
+}
+print c();
+print l;
+`
+
+// compile compiles source, returning it with the interpreter it was resolved
+// for.
+func compile(t *testing.T, source string) ([]byte, []parser.Stmt, *interpreter.Interpreter) {
+	t.Helper()
+	tokens, errs := scanner.NewFileScanner("script.lox", []byte(source), false).Scan()
+	if len(errs) > 0 {
+		t.Fatal(errs[0])
+	}
+	stmts, errs := parser.NewParser(tokens, false).Parse()
+	if len(errs) > 0 {
+		t.Fatal(errs[0])
+	}
+	interpreter_ := interpreter.NewInterpreter()
+	if diags := resolver.NewResolver(interpreter_).Resolve(stmts); len(diags) > 0 {
+		t.Fatal(diags[0])
+	}
+
+	var compiled bytes.Buffer
+	if err := Write(&compiled, "script.lox", stmts, interpreter_.Local); err != nil {
+		t.Fatal(err)
+	}
+	return compiled.Bytes(), stmts, interpreter_
+}
+
+func run(t *testing.T, interpreter_ *interpreter.Interpreter, stmts []parser.Stmt) string {
+	t.Helper()
+	var out strings.Builder
+	interpreter_.Stdout = &out
+	if err := interpreter_.Interpret(stmts); err != nil {
+		t.Fatal(err)
+	}
+	return out.String()
+}
+
+func TestRoundTrip(t *testing.T) {
+	compiled, stmts, original := compile(t, script)
+
+	program, err := Read(bytes.NewReader(compiled))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if program.File != "script.lox" {
+		t.Errorf("file %q, want script.lox", program.File)
+	}
+
+	interpreter_ := interpreter.NewInterpreter()
+	program.Resolve(interpreter_)
+
+	// writing what was read gives back the same bytes
+	var again bytes.Buffer
+	if err := Write(&again, program.File, program.Stmts, interpreter_.Local); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(again.Bytes(), compiled) {
+		t.Error("compiling the program read back gives different bytes")
+	}
+
+	want := run(t, original, stmts)
+	if got := run(t, interpreter_, program.Stmts); got != want {
+		t.Errorf("compiled script printed\n%s\nthe source printed\n%s", got, want)
+	}
+}
+
+func TestReadVersion(t *testing.T) {
+	compiled, _, _ := compile(t, "print 1;")
+	// the version is the byte after the magic
+	compiled[len(magic)] = FormatVersion + 1
+
+	_, err := Read(bytes.NewReader(compiled))
+	var version *VersionError
+	if !errors.As(err, &version) || version.Version != FormatVersion+1 {
+		t.Errorf("got %v, want a *VersionError for version %d", err, FormatVersion+1)
+	}
+}
+
+func TestReadDamaged(t *testing.T) {
+	compiled, _, _ := compile(t, script)
+
+	tests := map[string][]byte{
+		"empty":      {},
+		"not loxc":   []byte("print 1;"),
+		"header":     compiled[:len(magic)+1],
+		"trailing":   append(append([]byte{}, compiled...), 0),
+		"bad tag":    append(append([]byte{}, compiled[:len(compiled)-1]...), 0xff),
+		"big length": append(append([]byte{}, magic...), FormatVersion, 0xff, 0xff, 0xff, 0xff, 0x0f),
+	}
+	// every truncation of the file
+	for n := len(magic) + 1; n < len(compiled); n++ {
+		if _, err := Read(bytes.NewReader(compiled[:n])); !isFormatError(err) {
+			t.Fatalf("truncated to %d bytes: got %v, want a *FormatError", n, err)
+		}
+	}
+
+	for name, data := range tests {
+		if _, err := Read(bytes.NewReader(data)); !isFormatError(err) {
+			t.Errorf("%s: got %v, want a *FormatError", name, err)
+		}
+	}
+}
+
+func isFormatError(err error) bool {
+	var format *FormatError
+	return errors.As(err, &format)
+}
+
+// unknown and unknownStmt are nodes the format has no tag for.
+type unknown struct {
+	parser.Expr
+}
+
+type unknownStmt struct {
+	parser.Stmt
+}
+
+func TestWriteUnknown(t *testing.T) {
+	none := func(parser.Expr) (int, int, bool) { return 0, 0, false }
+	tests := map[string][]parser.Stmt{
+		"statement":  {unknownStmt{}},
+		"expression": {parser.PrintStmt{Expression: unknown{}}},
+		"literal":    {parser.PrintStmt{Expression: parser.Literal{Value: []int{}}}},
+	}
+
+	for name, stmts := range tests {
+		var out bytes.Buffer
+		if err := Write(&out, "script.lox", stmts, none); err == nil {
+			t.Errorf("%s: got no error", name)
+		}
+		if out.Len() != 0 {
+			t.Errorf("%s: wrote %d bytes", name, out.Len())
+		}
+	}
+}
+
+// TestReadCorrupted changes each byte of a compiled script in turn. What
+// Read accepts has to run without the interpreter panicking, whatever the
+// script then does.
+func TestReadCorrupted(t *testing.T) {
+	compiled, _, _ := compile(t, script)
+
+	for n := len(magic) + 1; n < len(compiled); n++ {
+		for _, change := range []func(byte) byte{
+			func(b byte) byte { return b ^ 1 },
+			func(b byte) byte { return b + 1 },
+			func(b byte) byte { return b - 1 },
+		} {
+			mutant := append([]byte{}, compiled...)
+			mutant[n] = change(mutant[n])
+
+			program, err := Read(bytes.NewReader(mutant))
+			if err != nil {
+				if !isFormatError(err) {
+					t.Fatalf("byte %d changed to %d: got %v, want a *FormatError", n, mutant[n], err)
+				}
+				continue
+			}
+			runMutant(t, program, n, mutant[n])
+		}
+	}
+}
+
+func runMutant(t *testing.T, program *Program, n int, b byte) {
+	t.Helper()
+	defer func() {
+		if r := recover(); r != nil {
+			t.Fatalf("byte %d changed to %d: the script panicked: %v", n, b, r)
+		}
+	}()
+	interpreter_ := interpreter.NewInterpreter()
+	interpreter_.Stdout = io.Discard
+	interpreter_.Limits.MaxSteps = 10000
+	program.Resolve(interpreter_)
+	interpreter_.Interpret(program.Stmts)
+}
+
+func TestReadMissingNodes(t *testing.T) {
+	token := scanner.Token{TokenType: scanner.IDENTIFIER, Lexeme: "x", Line: 1}
+	one := parser.NewLiteral(1.0)
+	print := func(expr parser.Expr) parser.Stmt {
+		return parser.NewPrintStmt(token, expr)
+	}
+
+	tests := []struct {
+		name  string
+		stmts []parser.Stmt
+		ok    bool
+	}{
+		{"binary operand", []parser.Stmt{print(parser.NewBinary(one, nil, token))}, false},
+		{"logical operand", []parser.Stmt{print(parser.NewLogical(nil, one, token))}, false},
+		{"unary operand", []parser.Stmt{print(parser.NewUnary(nil, token))}, false},
+		{"grouping", []parser.Stmt{print(parser.NewGrouping(nil))}, false},
+		{"callee", []parser.Stmt{print(parser.NewCall(nil, token, nil))}, false},
+		{"argument", []parser.Stmt{print(parser.NewCall(one, token, []parser.Expr{nil}))}, false},
+		{"get object", []parser.Stmt{print(parser.NewGet(nil, token))}, false},
+		{"set value", []parser.Stmt{print(parser.NewSet(nil, one, token))}, false},
+		{"printed", []parser.Stmt{print(nil)}, false},
+		{"expression", []parser.Stmt{parser.NewExpressionStmt(token, nil)}, false},
+		{"while condition", []parser.Stmt{parser.NewWhileStmt(token, nil, print(one))}, false},
+		{"while body", []parser.Stmt{parser.NewWhileStmt(token, one, nil)}, false},
+		{"if condition", []parser.Stmt{parser.NewIfStmt(token, nil, print(one), nil)}, false},
+		{"then branch", []parser.Stmt{parser.NewIfStmt(token, one, nil, nil)}, false},
+		{"statement", []parser.Stmt{nil}, false},
+		{"block statement", []parser.Stmt{parser.NewBlock(token, []parser.Stmt{nil})}, false},
+		{"else branch", []parser.Stmt{parser.NewIfStmt(token, one, print(one), nil)}, true},
+		{"initializer", []parser.Stmt{parser.NewVarDeclaration(token, nil)}, true},
+		{"return value", []parser.Stmt{parser.NewFunction(token, nil, []parser.Stmt{parser.NewReturn(token, nil)})}, true},
+		{"superclass", []parser.Stmt{parser.NewClass(token, nil, parser.Variable{})}, true},
+	}
+
+	none := func(parser.Expr) (int, int, bool) { return 0, 0, false }
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var compiled bytes.Buffer
+			if err := Write(&compiled, "script.lox", test.stmts, none); err != nil {
+				t.Fatal(err)
+			}
+			_, err := Read(&compiled)
+			if test.ok && err != nil {
+				t.Errorf("got %v, want the script read", err)
+			}
+			if !test.ok && !isFormatError(err) {
+				t.Errorf("got %v, want a *FormatError", err)
+			}
+		})
+	}
+}
+
+func TestReadLocalsOutOfRange(t *testing.T) {
+	tests := map[string]struct {
+		source string
+		// the depth and slot given to the expressions of type node
+		node        parser.Expr
+		depth, slot int
+	}{
+		"depth past the scopes":   {"{ var a = 1; print a; }", parser.Variable{}, 1, 0},
+		"slot past the locals":    {"{ var a = 1; print a; }", parser.Variable{}, 0, 1},
+		"local at the top level":  {"var a = 1; print a;", parser.Variable{}, 0, 0},
+		"slot declared later":     {"{ var a = 1; print a; var b = 2; }", parser.Variable{}, 0, 1},
+		"this outside of a class": {"class A { f() { return this; } }", parser.This{}, 2, 0},
+		"super without this":      {"class A {} class B < A { f() { return super.f; } }", parser.Super{}, 0, 0},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			tokens, _ := scanner.NewFileScanner("script.lox", []byte(test.source), false).Scan()
+			stmts, errs := parser.NewParser(tokens, false).Parse()
+			if len(errs) > 0 {
+				t.Fatal(errs[0])
+			}
+			damaged := func(expr parser.Expr) (int, int, bool) {
+				if reflect.TypeOf(expr) == reflect.TypeOf(test.node) {
+					return test.depth, test.slot, true
+				}
+				return 0, 0, false
+			}
+			var compiled bytes.Buffer
+			if err := Write(&compiled, "script.lox", stmts, damaged); err != nil {
+				t.Fatal(err)
+			}
+			if _, err := Read(&compiled); !isFormatError(err) {
+				t.Errorf("got %v, want a *FormatError", err)
+			}
+		})
+	}
+}
@@ -0,0 +1,189 @@
+package lsp
+
+import (
+	"strings"
+	"unicode/utf16"
+	"unicode/utf8"
+
+	"github.com/neet-007/glox/pkg/diagnostics"
+	"github.com/neet-007/glox/pkg/interpreter"
+	"github.com/neet-007/glox/pkg/parser"
+	"github.com/neet-007/glox/pkg/resolver"
+	"github.com/neet-007/glox/pkg/scanner"
+)
+
+// document is an open file and what was learnt from its last version.
+type document struct {
+	uri        string
+	version    int
+	source     []byte
+	lineStarts []int
+	tokens     []scanner.Token
+	index      *resolver.Index
+	// parsed is set when the version parsed without errors. lastParsed is
+	// the index of the last version that did, used for completion while
+	// the user is in the middle of typing.
+	parsed      bool
+	lastParsed  *resolver.Index
+	diagnostics []*diagnostics.Diagnostic
+}
+
+func newDocument(uri string) *document {
+	return &document{
+		uri: uri,
+	}
+}
+
+// update analyses text, the new content of the document. Declarations the
+// parser gave up on are left out of the index rather than failing it.
+func (d *document) update(version int, text string) {
+	d.version = version
+	d.source = []byte(text)
+	d.lineStarts = []int{0}
+	for i, c := range d.source {
+		if c == '\n' {
+			d.lineStarts = append(d.lineStarts, i+1)
+		}
+	}
+
+	tokens, scannerErrors := scanner.NewFileScanner(d.uri, d.source, false).Scan()
+	statements, parserErrors := parser.NewParser(tokens, false).Parse()
+
+	parsed := []parser.Stmt{}
+	for _, statement := range statements {
+		if statement != nil {
+			parsed = append(parsed, statement)
+		}
+	}
+
+	resolver_ := resolver.NewResolver(interpreter.NewInterpreter())
+	resolverErrors := resolver_.Resolve(parsed)
+
+	d.tokens = tokens
+	d.index = resolver_.Index()
+	d.index.SetExtents(tokens)
+	d.parsed = len(scannerErrors) == 0 && len(parserErrors) == 0
+	if d.parsed {
+		d.lastParsed = d.index
+	}
+
+	d.diagnostics = append(append(scannerErrors, parserErrors...), resolverErrors...)
+}
+
+// completionIndex is the index to complete from, the last one that parsed
+// if this version does not.
+func (d *document) completionIndex() *resolver.Index {
+	if !d.parsed && d.lastParsed != nil {
+		return d.lastParsed
+	}
+	return d.index
+}
+
+// position converts a byte offset into an LSP position, which counts
+// UTF-16 code units.
+func (d *document) position(offset int) Position {
+	offset = min(max(offset, 0), len(d.source))
+
+	line := 0
+	for line+1 < len(d.lineStarts) && d.lineStarts[line+1] <= offset {
+		line++
+	}
+
+	character := 0
+	for _, r := range string(d.source[d.lineStarts[line]:offset]) {
+		character += utf16.RuneLen(r)
+	}
+
+	return Position{
+		Line:      line,
+		Character: character,
+	}
+}
+
+// offset converts an LSP position into a byte offset, clamped to the line.
+func (d *document) offset(position Position) int {
+	if position.Line < 0 {
+		return 0
+	}
+	if position.Line >= len(d.lineStarts) {
+		return len(d.source)
+	}
+
+	offset := d.lineStarts[position.Line]
+	for character := 0; character < position.Character && offset < len(d.source) && d.source[offset] != '\n'; {
+		r, size := utf8.DecodeRune(d.source[offset:])
+		character += utf16.RuneLen(r)
+		offset += size
+	}
+
+	return offset
+}
+
+func (d *document) tokenRange(token scanner.Token) Range {
+	return d.span(token.Offset, token.Offset+len(token.Lexeme))
+}
+
+func (d *document) span(start int, end int) Range {
+	return Range{
+		Start: d.position(start),
+		End:   d.position(end),
+	}
+}
+
+func (d *document) lspDiagnostics() []Diagnostic {
+	converted := []Diagnostic{}
+	for _, diagnostic := range d.diagnostics {
+		severity := SeverityError
+		switch diagnostic.Severity {
+		case diagnostics.Warning:
+			severity = SeverityWarning
+		case diagnostics.Note:
+			severity = SeverityInformation
+		}
+
+		message := diagnostic.Message
+		for _, note := range diagnostic.Notes {
+			message += "\nnote: " + note
+		}
+		for _, hint := range diagnostic.Hints {
+			message += "\nhelp: " + hint
+		}
+
+		converted = append(converted, Diagnostic{
+			Range:    d.span(diagnostic.Span.Offset, diagnostic.Span.Offset+len(diagnostic.Span.Text)),
+			Severity: severity,
+			Code:     string(diagnostic.Code),
+			Source:   "glox",
+			Message:  message,
+		})
+	}
+
+	return converted
+}
+
+// declaration is how a symbol is shown on hover.
+func declaration(symbol *resolver.Symbol) string {
+	parameters := func() string {
+		names := []string{}
+		for _, parameter := range symbol.Parameters {
+			names = append(names, parameter.Lexeme)
+		}
+		return "(" + strings.Join(names, ", ") + ")"
+	}
+
+	switch symbol.Kind {
+	case resolver.FunctionSymbol:
+		return "fun " + symbol.Name.Lexeme + parameters()
+	case resolver.MethodSymbol:
+		return symbol.Class + "." + symbol.Name.Lexeme + parameters()
+	case resolver.ClassSymbol:
+		if symbol.Superclass != "" {
+			return "class " + symbol.Name.Lexeme + " < " + symbol.Superclass
+		}
+		return "class " + symbol.Name.Lexeme
+	case resolver.ParameterSymbol:
+		return "parameter " + symbol.Name.Lexeme
+	default:
+		return "var " + symbol.Name.Lexeme
+	}
+}
@@ -0,0 +1,94 @@
+package lsp
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+
+	"github.com/neet-007/glox/pkg/framing"
+)
+
+// JSON-RPC error codes used by the server.
+const (
+	parseError     = -32700
+	invalidRequest = -32600
+	methodNotFound = -32601
+	invalidParams  = -32602
+)
+
+// message is a request, a notification when ID is nil.
+type message struct {
+	JSONRPC string           `json:"jsonrpc"`
+	ID      *json.RawMessage `json:"id,omitempty"`
+	Method  string           `json:"method"`
+	Params  json.RawMessage  `json:"params,omitempty"`
+}
+
+type response struct {
+	JSONRPC string           `json:"jsonrpc"`
+	ID      *json.RawMessage `json:"id"`
+	Result  json.RawMessage  `json:"result,omitempty"`
+	Error   *responseError   `json:"error,omitempty"`
+}
+
+type responseError struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+func (e *responseError) Error() string {
+	return fmt.Sprintf("%s (%d)", e.Message, e.Code)
+}
+
+type notification struct {
+	JSONRPC string `json:"jsonrpc"`
+	Method  string `json:"method"`
+	Params  any    `json:"params"`
+}
+
+// conn reads and writes messages framed by a Content-Length header, as
+// LSP sends them over stdio.
+type conn struct {
+	reader *framing.Reader
+	writer *framing.Writer
+}
+
+func newConn(reader io.Reader, writer io.Writer) *conn {
+	return &conn{
+		reader: framing.NewReader(reader),
+		writer: framing.NewWriter(writer),
+	}
+}
+
+func (c *conn) read() ([]byte, error) {
+	return c.reader.ReadMessage()
+}
+
+func (c *conn) write(value any) error {
+	return c.writer.WriteMessage(value)
+}
+
+func (c *conn) reply(id *json.RawMessage, result any, replyErr *responseError) error {
+	resp := response{
+		JSONRPC: "2.0",
+		ID:      id,
+		Error:   replyErr,
+	}
+	if replyErr == nil {
+		encoded, err := json.Marshal(result)
+		if err != nil {
+			return err
+		}
+		resp.Result = encoded
+	}
+
+	return c.write(resp)
+}
+
+func (c *conn) notify(method string, params any) error {
+	return c.write(notification{
+		JSONRPC: "2.0",
+		Method:  method,
+		Params:  params,
+	})
+}
@@ -0,0 +1,421 @@
+package lsp
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"sort"
+
+	"github.com/neet-007/glox/pkg/resolver"
+	"github.com/neet-007/glox/pkg/scanner"
+)
+
+var keywords = []string{
+	"and", "class", "else", "false", "for", "fun", "if", "nil", "or",
+	"print", "return", "super", "this", "true", "var", "while",
+}
+
+// Server is a language server for lox speaking LSP over a reader and a
+// writer, usually stdin and stdout. Requests are handled one at a time.
+type Server struct {
+	conn      *conn
+	documents map[string]*document
+	shutdown  bool
+	logger    *log.Logger
+}
+
+func NewServer(reader io.Reader, writer io.Writer, logger *log.Logger) *Server {
+	if logger == nil {
+		logger = log.New(io.Discard, "", 0)
+	}
+
+	return &Server{
+		conn:      newConn(reader, writer),
+		documents: map[string]*document{},
+		logger:    logger,
+	}
+}
+
+// Run serves until the client sends exit or closes the connection and
+// returns the exit code, 1 when exit came before shutdown.
+func (s *Server) Run() int {
+	for {
+		body, err := s.conn.read()
+		if err != nil {
+			if errors.Is(err, io.EOF) {
+				return 1
+			}
+			s.logger.Printf("read: %v", err)
+			return 1
+		}
+
+		var msg message
+		if err := json.Unmarshal(body, &msg); err != nil {
+			s.logger.Printf("bad message: %v", err)
+			s.conn.reply(nil, nil, &responseError{Code: parseError, Message: err.Error()})
+			continue
+		}
+
+		if msg.Method == "exit" {
+			if s.shutdown {
+				return 0
+			}
+			return 1
+		}
+
+		result, replyErr := s.handle(msg)
+		if msg.ID == nil {
+			if replyErr != nil {
+				s.logger.Printf("%s: %v", msg.Method, replyErr)
+			}
+			continue
+		}
+		if err := s.conn.reply(msg.ID, result, replyErr); err != nil {
+			s.logger.Printf("write: %v", err)
+			return 1
+		}
+	}
+}
+
+func (s *Server) handle(msg message) (any, *responseError) {
+	s.logger.Printf("<- %s", msg.Method)
+
+	if s.shutdown && msg.Method != "exit" {
+		return nil, &responseError{Code: invalidRequest, Message: "server is shut down"}
+	}
+
+	switch msg.Method {
+	case "initialize":
+		return map[string]any{
+			"capabilities": map[string]any{
+				// full document sync
+				"textDocumentSync":       1,
+				"definitionProvider":     true,
+				"referencesProvider":     true,
+				"hoverProvider":          true,
+				"documentSymbolProvider": true,
+				"completionProvider": map[string]any{
+					"triggerCharacters": []string{"."},
+				},
+			},
+			"serverInfo": map[string]string{
+				"name": "glox",
+			},
+		}, nil
+	case "initialized":
+		return nil, nil
+	case "shutdown":
+		s.shutdown = true
+		return nil, nil
+	case "textDocument/didOpen":
+		var params DidOpenTextDocumentParams
+		if err := decode(msg.Params, &params); err != nil {
+			return nil, err
+		}
+		document := newDocument(params.TextDocument.URI)
+		s.documents[params.TextDocument.URI] = document
+		document.update(params.TextDocument.Version, params.TextDocument.Text)
+		s.publishDiagnostics(document)
+		return nil, nil
+	case "textDocument/didChange":
+		var params DidChangeTextDocumentParams
+		if err := decode(msg.Params, &params); err != nil {
+			return nil, err
+		}
+		document, ok := s.documents[params.TextDocument.URI]
+		if !ok || len(params.ContentChanges) == 0 {
+			return nil, nil
+		}
+		document.update(params.TextDocument.Version, params.ContentChanges[len(params.ContentChanges)-1].Text)
+		s.publishDiagnostics(document)
+		return nil, nil
+	case "textDocument/didClose":
+		var params DidCloseTextDocumentParams
+		if err := decode(msg.Params, &params); err != nil {
+			return nil, err
+		}
+		delete(s.documents, params.TextDocument.URI)
+		s.clearDiagnostics(params.TextDocument.URI)
+		return nil, nil
+	case "textDocument/definition":
+		var params TextDocumentPositionParams
+		if err := decode(msg.Params, &params); err != nil {
+			return nil, err
+		}
+		return s.definition(params), nil
+	case "textDocument/references":
+		var params ReferenceParams
+		if err := decode(msg.Params, &params); err != nil {
+			return nil, err
+		}
+		return s.references(params), nil
+	case "textDocument/hover":
+		var params TextDocumentPositionParams
+		if err := decode(msg.Params, &params); err != nil {
+			return nil, err
+		}
+		return s.hover(params), nil
+	case "textDocument/documentSymbol":
+		var params DocumentSymbolParams
+		if err := decode(msg.Params, &params); err != nil {
+			return nil, err
+		}
+		return s.documentSymbols(params), nil
+	case "textDocument/completion":
+		var params TextDocumentPositionParams
+		if err := decode(msg.Params, &params); err != nil {
+			return nil, err
+		}
+		return s.completion(params), nil
+	default:
+		if msg.ID == nil {
+			// unknown notifications, like $/cancelRequest, are ignored
+			return nil, nil
+		}
+		return nil, &responseError{Code: methodNotFound, Message: fmt.Sprintf("method %s not found", msg.Method)}
+	}
+}
+
+func decode(params json.RawMessage, out any) *responseError {
+	if err := json.Unmarshal(params, out); err != nil {
+		return &responseError{Code: invalidParams, Message: err.Error()}
+	}
+	return nil
+}
+
+func (s *Server) publishDiagnostics(document *document) {
+	err := s.conn.notify("textDocument/publishDiagnostics", PublishDiagnosticsParams{
+		URI:         document.uri,
+		Version:     document.version,
+		Diagnostics: document.lspDiagnostics(),
+	})
+	if err != nil {
+		s.logger.Printf("publish diagnostics: %v", err)
+	}
+}
+
+func (s *Server) clearDiagnostics(uri string) {
+	err := s.conn.notify("textDocument/publishDiagnostics", PublishDiagnosticsParams{
+		URI:         uri,
+		Diagnostics: []Diagnostic{},
+	})
+	if err != nil {
+		s.logger.Printf("publish diagnostics: %v", err)
+	}
+}
+
+// symbolAt returns the document and the symbol under the cursor, nil
+// when there is none.
+func (s *Server) symbolAt(params TextDocumentPositionParams) (*document, *resolver.Symbol) {
+	document, ok := s.documents[params.TextDocument.URI]
+	if !ok {
+		return nil, nil
+	}
+
+	return document, document.index.SymbolAt(document.offset(params.Position))
+}
+
+func (s *Server) definition(params TextDocumentPositionParams) []Location {
+	document, symbol := s.symbolAt(params)
+	if symbol == nil {
+		return []Location{}
+	}
+
+	return []Location{{
+		URI:   document.uri,
+		Range: document.tokenRange(symbol.Name),
+	}}
+}
+
+func (s *Server) references(params ReferenceParams) []Location {
+	document, symbol := s.symbolAt(params.TextDocumentPositionParams)
+	if symbol == nil {
+		return []Location{}
+	}
+
+	locations := []Location{}
+	if params.Context.IncludeDeclaration {
+		locations = append(locations, Location{
+			URI:   document.uri,
+			Range: document.tokenRange(symbol.Name),
+		})
+	}
+	for _, reference := range document.index.ReferencesTo(symbol) {
+		locations = append(locations, Location{
+			URI:   document.uri,
+			Range: document.tokenRange(reference),
+		})
+	}
+
+	return locations
+}
+
+func (s *Server) hover(params TextDocumentPositionParams) *Hover {
+	document, symbol := s.symbolAt(params)
+	if symbol == nil {
+		return nil
+	}
+
+	offset := document.offset(params.Position)
+	hovered := symbol.Name
+	for _, token := range document.tokens {
+		if token.Offset <= offset && offset <= token.Offset+len(token.Lexeme) && token.TokenType == scanner.IDENTIFIER {
+			hovered = token
+			break
+		}
+	}
+
+	return &Hover{
+		Contents: MarkupContent{
+			Kind:  "markdown",
+			Value: fmt.Sprintf("```lox\n%s\n```\ndeclared on line %d", declaration(symbol), symbol.Name.Line),
+		},
+		Range: document.tokenRange(hovered),
+	}
+}
+
+func (s *Server) documentSymbols(params DocumentSymbolParams) []DocumentSymbol {
+	document, ok := s.documents[params.TextDocument.URI]
+	if !ok {
+		return []DocumentSymbol{}
+	}
+	index := document.index
+
+	symbols := []DocumentSymbol{}
+	for _, symbol := range index.Symbols {
+		kind := SymbolKindVariable
+		switch symbol.Kind {
+		case resolver.ClassSymbol:
+			kind = SymbolKindClass
+		case resolver.FunctionSymbol:
+			kind = SymbolKindFunction
+		case resolver.VariableSymbol:
+			// only globals, locals are not worth an outline entry
+			if symbol.Scope != nil {
+				continue
+			}
+		default:
+			continue
+		}
+
+		documentSymbol := DocumentSymbol{
+			Name:           symbol.Name.Lexeme,
+			Detail:         declaration(symbol),
+			Kind:           kind,
+			Range:          document.span(symbol.Name.Offset, index.Extent(symbol)),
+			SelectionRange: document.tokenRange(symbol.Name),
+		}
+		if symbol.Kind == resolver.ClassSymbol {
+			for _, method := range index.Methods[symbol.Name.Lexeme] {
+				documentSymbol.Children = append(documentSymbol.Children, DocumentSymbol{
+					Name:           method.Name.Lexeme,
+					Detail:         declaration(method),
+					Kind:           SymbolKindMethod,
+					Range:          document.span(method.Name.Offset, index.Extent(method)),
+					SelectionRange: document.tokenRange(method.Name),
+				})
+			}
+		}
+		symbols = append(symbols, documentSymbol)
+	}
+
+	return symbols
+}
+
+func (s *Server) completion(params TextDocumentPositionParams) CompletionList {
+	list := CompletionList{
+		Items: []CompletionItem{},
+	}
+	document, ok := s.documents[params.TextDocument.URI]
+	if !ok {
+		return list
+	}
+	index := document.completionIndex()
+	offset := document.offset(params.Position)
+
+	// the tokens before the word being typed
+	before := []scanner.Token{}
+	for _, token := range document.tokens {
+		if token.TokenType == scanner.EOF || token.Offset+len(token.Lexeme) > offset {
+			break
+		}
+		before = append(before, token)
+	}
+	if len(before) > 0 && before[len(before)-1].TokenType == scanner.IDENTIFIER && before[len(before)-1].Offset+len(before[len(before)-1].Lexeme) == offset {
+		before = before[:len(before)-1]
+	}
+
+	if len(before) > 0 && before[len(before)-1].TokenType == scanner.DOT {
+		list.Items = methodCompletions(index, before, offset)
+		return list
+	}
+
+	for _, symbol := range index.Visible(offset) {
+		item := CompletionItem{
+			Label:  symbol.Name.Lexeme,
+			Kind:   CompletionKindVariable,
+			Detail: declaration(symbol),
+		}
+		switch symbol.Kind {
+		case resolver.FunctionSymbol:
+			item.Kind = CompletionKindFunction
+		case resolver.ClassSymbol:
+			item.Kind = CompletionKindClass
+		}
+		list.Items = append(list.Items, item)
+	}
+	for _, keyword := range keywords {
+		list.Items = append(list.Items, CompletionItem{
+			Label: keyword,
+			Kind:  CompletionKindKeyword,
+		})
+	}
+
+	return list
+}
+
+// methodCompletions completes a property name. After this the methods of
+// the enclosing class are offered, otherwise the methods of every class
+// since the type of the object is not known.
+func methodCompletions(index *resolver.Index, before []scanner.Token, offset int) []CompletionItem {
+	classes := []string{}
+	if len(before) > 1 && before[len(before)-2].TokenType == scanner.THIS {
+		var enclosing *resolver.Symbol
+		for _, symbol := range index.Symbols {
+			if symbol.Kind == resolver.ClassSymbol && symbol.Name.Offset < offset && offset < index.Extent(symbol) {
+				enclosing = symbol
+			}
+		}
+		seen := map[*resolver.Symbol]bool{}
+		for class := enclosing; class != nil && !seen[class]; class = index.Globals[class.Superclass] {
+			seen[class] = true
+			classes = append(classes, class.Name.Lexeme)
+		}
+	}
+	if len(classes) == 0 {
+		for class := range index.Methods {
+			classes = append(classes, class)
+		}
+		sort.Strings(classes)
+	}
+
+	items := []CompletionItem{}
+	seen := map[string]bool{}
+	for _, class := range classes {
+		for _, method := range index.Methods[class] {
+			if seen[method.Name.Lexeme] {
+				continue
+			}
+			seen[method.Name.Lexeme] = true
+			items = append(items, CompletionItem{
+				Label:  method.Name.Lexeme,
+				Kind:   CompletionKindMethod,
+				Detail: declaration(method),
+			})
+		}
+	}
+
+	return items
+}
@@ -0,0 +1,185 @@
+package lsp
+
+import (
+	"encoding/json"
+	"net"
+	"strings"
+	"testing"
+	"time"
+)
+
+// client drives a Server over a pipe the way an editor would.
+type client struct {
+	t    *testing.T
+	conn *conn
+	raw  net.Conn
+	id   int
+	// messages are read from the server as soon as it writes them, as
+	// net.Pipe blocks a write until the other side reads it.
+	messages chan []byte
+	// notifications are the ones read while waiting for a response.
+	notifications []message
+	done          chan int
+}
+
+func newClient(t *testing.T) *client {
+	t.Helper()
+	serverSide, clientSide := net.Pipe()
+	c := &client{
+		t:        t,
+		conn:     newConn(clientSide, clientSide),
+		raw:      clientSide,
+		messages: make(chan []byte, 16),
+		done:     make(chan int, 1),
+	}
+	go func() {
+		c.done <- NewServer(serverSide, serverSide, nil).Run()
+		serverSide.Close()
+	}()
+	go func() {
+		defer close(c.messages)
+		for {
+			body, err := c.conn.read()
+			if err != nil {
+				return
+			}
+			c.messages <- body
+		}
+	}()
+	t.Cleanup(func() { clientSide.Close() })
+	return c
+}
+
+func (c *client) send(value any) {
+	c.t.Helper()
+	c.raw.SetWriteDeadline(time.Now().Add(5 * time.Second))
+	if err := c.conn.write(value); err != nil {
+		c.t.Fatalf("write: %v", err)
+	}
+}
+
+func (c *client) notify(method string, params any) {
+	c.t.Helper()
+	c.send(notification{JSONRPC: "2.0", Method: method, Params: params})
+}
+
+// request sends method and returns its result decoded into out.
+func (c *client) request(method string, params any, out any) {
+	c.t.Helper()
+	c.id++
+	c.send(map[string]any{"jsonrpc": "2.0", "id": c.id, "method": method, "params": params})
+
+	for {
+		var body []byte
+		select {
+		case received, ok := <-c.messages:
+			if !ok {
+				c.t.Fatalf("%s: connection closed", method)
+			}
+			body = received
+		case <-time.After(5 * time.Second):
+			c.t.Fatalf("%s: no response", method)
+		}
+		var resp struct {
+			response
+			Method string          `json:"method"`
+			Params json.RawMessage `json:"params"`
+		}
+		if err := json.Unmarshal(body, &resp); err != nil {
+			c.t.Fatalf("bad message %s: %v", body, err)
+		}
+		if resp.Method != "" {
+			c.notifications = append(c.notifications, message{Method: resp.Method, Params: resp.Params})
+			continue
+		}
+		if resp.Error != nil {
+			c.t.Fatalf("%s: %v", method, resp.Error)
+		}
+		if out != nil {
+			if err := json.Unmarshal(resp.Result, out); err != nil {
+				c.t.Fatalf("%s result %s: %v", method, resp.Result, err)
+			}
+		}
+		return
+	}
+}
+
+// diagnostics returns the last diagnostics published for uri.
+func (c *client) diagnostics(uri string) []Diagnostic {
+	c.t.Helper()
+	// a request flushes the notifications sent before its response
+	c.request("textDocument/documentSymbol", DocumentSymbolParams{TextDocument: TextDocumentIdentifier{URI: uri}}, nil)
+	for j := len(c.notifications) - 1; j >= 0; j-- {
+		var params PublishDiagnosticsParams
+		if c.notifications[j].Method != "textDocument/publishDiagnostics" {
+			continue
+		}
+		if err := json.Unmarshal(c.notifications[j].Params, &params); err != nil {
+			c.t.Fatal(err)
+		}
+		if params.URI == uri {
+			return params.Diagnostics
+		}
+	}
+	c.t.Fatalf("no diagnostics published for %s", uri)
+	return nil
+}
+
+func (c *client) open(uri string, text string) {
+	c.t.Helper()
+	c.notify("textDocument/didOpen", DidOpenTextDocumentParams{
+		TextDocument: TextDocumentItem{URI: uri, LanguageID: "lox", Version: 1, Text: text},
+	})
+}
+
+func TestServer(t *testing.T) {
+	c := newClient(t)
+
+	var initialized struct {
+		Capabilities map[string]any `json:"capabilities"`
+	}
+	c.request("initialize", map[string]any{}, &initialized)
+	if initialized.Capabilities["hoverProvider"] != true || initialized.Capabilities["definitionProvider"] != true {
+		t.Errorf("capabilities %v lack hover or definition", initialized.Capabilities)
+	}
+	c.notify("initialized", map[string]any{})
+
+	c.open("file:///bad.lox", "print 1 +;\n")
+	if diagnostics := c.diagnostics("file:///bad.lox"); len(diagnostics) != 1 || diagnostics[0].Severity != SeverityError {
+		t.Errorf("got diagnostics %+v, want one error", diagnostics)
+	}
+
+	const uri = "file:///good.lox"
+	c.open(uri, "var total = 1;\nfun add(x) {\n  return total + x;\n}\nprint add(total);\n")
+	if diagnostics := c.diagnostics(uri); len(diagnostics) != 0 {
+		t.Errorf("got diagnostics %+v, want none", diagnostics)
+	}
+
+	// "total" in "print add(total);"
+	at := TextDocumentPositionParams{
+		TextDocument: TextDocumentIdentifier{URI: uri},
+		Position:     Position{Line: 4, Character: 11},
+	}
+
+	var hover Hover
+	c.request("textDocument/hover", at, &hover)
+	if !strings.Contains(hover.Contents.Value, "var total") || !strings.Contains(hover.Contents.Value, "line 1") {
+		t.Errorf("hover %q does not describe the declaration", hover.Contents.Value)
+	}
+	if want := (Range{Start: Position{Line: 4, Character: 10}, End: Position{Line: 4, Character: 15}}); hover.Range != want {
+		t.Errorf("hover range %+v, want %+v", hover.Range, want)
+	}
+
+	var locations []Location
+	c.request("textDocument/definition", at, &locations)
+	want := Location{URI: uri, Range: Range{Start: Position{Line: 0, Character: 4}, End: Position{Line: 0, Character: 9}}}
+	if len(locations) != 1 || locations[0] != want {
+		t.Errorf("definition %+v, want %+v", locations, want)
+	}
+
+	c.request("shutdown", nil, nil)
+	c.notify("exit", nil)
+	if code := <-c.done; code != 0 {
+		t.Errorf("exit code %d, want 0", code)
+	}
+}
@@ -0,0 +1,238 @@
+// Package optimizer rewrites a resolved script into one that does the same
+// with less work: constant expressions are folded, branches and loops
+// that can never run are dropped and blocks that declare nothing are
+// merged into the code around them.
+//
+// The rewritten statements are new nodes, some of them in other scopes
+// than before, so they have to be resolved again before they are run.
+package optimizer
+
+import (
+	"github.com/neet-007/glox/pkg/interpreter"
+	"github.com/neet-007/glox/pkg/parser"
+	"github.com/neet-007/glox/pkg/scanner"
+)
+
+// Optimize returns stmts optimized. stmts themselves are left as they are.
+func Optimize(stmts []parser.Stmt) []parser.Stmt {
+	return statements(stmts)
+}
+
+// statements optimizes a list of statements, leaving out the ones that
+// can never run and splicing in blocks that declare nothing.
+func statements(stmts []parser.Stmt) []parser.Stmt {
+	optimized := make([]parser.Stmt, 0, len(stmts))
+	for _, stmt := range stmts {
+		switch stmt := statement(stmt).(type) {
+		case nil:
+		case parser.Block:
+			if declares(stmt.Statements) {
+				optimized = append(optimized, stmt)
+			} else {
+				optimized = append(optimized, stmt.Statements...)
+			}
+		default:
+			optimized = append(optimized, stmt)
+		}
+	}
+	return optimized
+}
+
+// declares reports whether stmts define a name in the scope they run in.
+func declares(stmts []parser.Stmt) bool {
+	for _, stmt := range stmts {
+		switch stmt.(type) {
+		case parser.VarDeclaration, parser.Function, parser.Class:
+			return true
+		}
+	}
+	return false
+}
+
+// branch optimizes the body of an if or while, nil when it was dropped. A
+// block that declares nothing and holds a single statement is replaced by
+// that statement.
+func branch(stmt parser.Stmt) parser.Stmt {
+	optimized := statement(stmt)
+	if block, ok := optimized.(parser.Block); ok && len(block.Statements) == 1 && !declares(block.Statements) {
+		return block.Statements[0]
+	}
+	return optimized
+}
+
+// body is branch for places a statement must stay, an empty block taking
+// the place of one that was dropped.
+func body(stmt parser.Stmt, keyword scanner.Token) parser.Stmt {
+	optimized := branch(stmt)
+	if optimized == nil {
+		return parser.NewBlock(keyword, []parser.Stmt{})
+	}
+	return optimized
+}
+
+// statement optimizes stmt, nil when it can never run.
+func statement(stmt parser.Stmt) parser.Stmt {
+	switch stmt := stmt.(type) {
+	case parser.Class:
+		methods := make([]parser.Function, len(stmt.Methods))
+		for i, method := range stmt.Methods {
+			methods[i] = function(method)
+		}
+		return parser.NewClass(stmt.Name, methods, stmt.SuperClass)
+	case parser.Return:
+		return parser.NewReturn(stmt.Keyword, expression(stmt.Value))
+	case parser.Function:
+		return function(stmt)
+	case parser.VarDeclaration:
+		return parser.NewVarDeclaration(stmt.Name, expression(stmt.Initizlier))
+	case parser.WhileStmt:
+		condition := expression(stmt.Condition)
+		if literal, ok := condition.(parser.Literal); ok && !interpreter.Truthy(literal.Value) {
+			return nil
+		}
+		return parser.NewWhileStmt(stmt.Keyword, condition, body(stmt.Body, stmt.Keyword))
+	case parser.Block:
+		return parser.NewBlock(stmt.Brace, statements(stmt.Statements))
+	case parser.IfStmt:
+		condition := expression(stmt.Condition)
+		if literal, ok := condition.(parser.Literal); ok {
+			if interpreter.Truthy(literal.Value) {
+				return statement(stmt.ThenBranch)
+			}
+			return statement(stmt.ElseBranch)
+		}
+		return parser.NewIfStmt(stmt.Keyword, condition, body(stmt.ThenBranch, stmt.Keyword), branch(stmt.ElseBranch))
+	case parser.ExpressionStmt:
+		return parser.NewExpressionStmt(stmt.Start, expression(stmt.Expression))
+	case parser.PrintStmt:
+		return parser.NewPrintStmt(stmt.Keyword, expression(stmt.Expression))
+	default:
+		return stmt
+	}
+}
+
+func function(function parser.Function) parser.Function {
+	return parser.NewFunction(function.Name, function.Parameters, statements(function.Body))
+}
+
+func expressions(exprs []parser.Expr) []parser.Expr {
+	optimized := make([]parser.Expr, len(exprs))
+	for i, expr := range exprs {
+		optimized[i] = expression(expr)
+	}
+	return optimized
+}
+
+// expression optimizes expr, folding it into a literal when everything it
+// does is known before the script runs.
+func expression(expr parser.Expr) parser.Expr {
+	switch expr := expr.(type) {
+	case parser.ListSet:
+		return parser.NewListSet(expression(expr.List), expression(expr.Index), expression(expr.Value), expr.Token)
+	case parser.ListGet:
+		return parser.NewListGet(expression(expr.List), expression(expr.Index), expr.Token)
+	case parser.ListExpr:
+		return parser.NewListExpr(expr.LeftBracket, expr.RightBracket, expressions(expr.Literals))
+	case parser.Set:
+		return parser.NewSet(expression(expr.Value), expression(expr.Object), expr.Name)
+	case parser.Get:
+		return parser.NewGet(expression(expr.Object), expr.Name)
+	case parser.Call:
+		return parser.NewCall(expression(expr.Callee), expr.Paren, expressions(expr.Arguments))
+	case parser.Assign:
+		return parser.NewAssign(expr.Lexem, expression(expr.Expr))
+	case parser.Binary:
+		left := expression(expr.Left)
+		right := expression(expr.Right)
+		leftLiteral, leftOk := left.(parser.Literal)
+		rightLiteral, rightOk := right.(parser.Literal)
+		if leftOk && rightOk {
+			if value, ok := binary(expr.Operator.TokenType, leftLiteral.Value, rightLiteral.Value); ok {
+				return parser.NewLiteral(value)
+			}
+		}
+		return parser.NewBinary(left, right, expr.Operator)
+	case parser.Grouping:
+		inner := expression(expr.Expr)
+		if literal, ok := inner.(parser.Literal); ok {
+			return literal
+		}
+		return parser.NewGrouping(inner)
+	case parser.Logical:
+		left := expression(expr.Left)
+		if literal, ok := left.(parser.Literal); ok {
+			// "or" gives a truthy left side and "and" a falsey one without
+			// looking at the right.
+			if interpreter.Truthy(literal.Value) == (expr.Operator.TokenType == scanner.OR) {
+				return left
+			}
+			return expression(expr.Right)
+		}
+		return parser.NewLogical(left, expression(expr.Right), expr.Operator)
+	case parser.Unary:
+		right := expression(expr.Right)
+		if literal, ok := right.(parser.Literal); ok {
+			switch expr.Operator.TokenType {
+			case scanner.BANG:
+				return parser.NewLiteral(!interpreter.Truthy(literal.Value))
+			case scanner.MINUS:
+				if number, ok := literal.Value.(float64); ok {
+					return parser.NewLiteral(-number)
+				}
+			}
+		}
+		return parser.NewUnary(right, expr.Operator)
+	default:
+		return expr
+	}
+}
+
+// binary works out left operator right the way the interpreter does,
+// false when that would be a runtime error, which is left for the script
+// to raise when it runs.
+func binary(operator scanner.TokenType, left any, right any) (any, bool) {
+	switch operator {
+	case scanner.EQUAL_EQUAL:
+		return left == right, true
+	case scanner.BANG_EQUAL:
+		return left != right, true
+	}
+
+	if operator == scanner.PLUS {
+		if leftString, ok := left.(string); ok {
+			if rightString, ok := right.(string); ok {
+				return leftString + rightString, true
+			}
+		}
+	}
+
+	leftNumber, ok := left.(float64)
+	if !ok {
+		return nil, false
+	}
+	rightNumber, ok := right.(float64)
+	if !ok {
+		return nil, false
+	}
+
+	switch operator {
+	case scanner.PLUS:
+		return leftNumber + rightNumber, true
+	case scanner.MINUS:
+		return leftNumber - rightNumber, true
+	case scanner.STAR:
+		return leftNumber * rightNumber, true
+	case scanner.SLASH:
+		return leftNumber / rightNumber, true
+	case scanner.GREATER:
+		return leftNumber > rightNumber, true
+	case scanner.GREATER_EQUAL:
+		return leftNumber >= rightNumber, true
+	case scanner.LESS:
+		return leftNumber < rightNumber, true
+	case scanner.LESS_EQUAL:
+		return leftNumber <= rightNumber, true
+	default:
+		return nil, false
+	}
+}
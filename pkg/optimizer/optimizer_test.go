@@ -0,0 +1,138 @@
+package optimizer_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/neet-007/glox/pkg/interpreter"
+	"github.com/neet-007/glox/pkg/optimizer"
+	"github.com/neet-007/glox/pkg/parser"
+	"github.com/neet-007/glox/pkg/resolver"
+	"github.com/neet-007/glox/pkg/runtime"
+	"github.com/neet-007/glox/pkg/scanner"
+	"github.com/neet-007/glox/pkg/utils"
+)
+
+func parse(t *testing.T, source string) []parser.Stmt {
+	t.Helper()
+	tokens, errs := scanner.NewFileScanner("test.lox", []byte(source), false).Scan()
+	if len(errs) > 0 {
+		t.Fatal(errs[0])
+	}
+	stmts, errs := parser.NewParser(tokens, false).Parse()
+	if len(errs) > 0 {
+		t.Fatal(errs[0])
+	}
+	return stmts
+}
+
+// printed writes stmts the way the AST printer does, one statement a line.
+func printed(t *testing.T, stmts []parser.Stmt) string {
+	t.Helper()
+	printer := utils.NewAstPrinter()
+	lines := make([]string, len(stmts))
+	for i, stmt := range stmts {
+		line, err := stmt.Accept(&printer)
+		if err != nil {
+			t.Fatal(err)
+		}
+		lines[i] = line.(string)
+	}
+	return strings.Join(lines, "\n")
+}
+
+func TestOptimize(t *testing.T) {
+	tests := []struct {
+		name   string
+		source string
+		want   string
+	}{
+		{"arithmetic", "print 1 + 2 * 3;", "(print (value 7))"},
+		{"grouping", "print -(2 - 5);", "(print (value 3))"},
+		{"concatenation", `print "a" + "b";`, "(print (value ab))"},
+		{"comparison", "print 1 == 1 and 2 < 1;", "(print (value false))"},
+		{"not", "print !nil;", "(print (value true))"},
+		{"partly constant", "print x + 1 * 2;", "(print (value (+ x 2)))"},
+		{"or short circuit", "print true or x;", "(print (value true))"},
+		{"and short circuit", "print false and x;", "(print (value false))"},
+		{"or right side", "print nil or x;", "(print (value x))"},
+		{"operand error", `print "a" - 1;`, "(print (value (- a 1)))"},
+		{"negate string", `print -"a";`, `(print (value (- a)))`},
+		{"if true", "if (1 < 2) print 1; else print 2;", "(print (value 1))"},
+		{"if false", "if (nil) print 1; else print 2;", "(print (value 2))"},
+		{"if false without else", "if (false) print 1;\nprint 2;", "(print (value 2))"},
+		{"if unknown", "if (x) print 1 + 1;", "(if (condition x) (print (value 2)))"},
+		{"while false", "while (1 > 2) print 1;\nprint 2;", "(print (value 2))"},
+		{"while body", "while (x) { print 1; }", "(while (condition x) (print (value 1)))"},
+		{"dropped while body", "while (x) { if (false) print 1; }", "(while (condition x) (block ))"},
+		{"spliced block", "{ print 1; { print 2; } }", "(print (value 1))\n(print (value 2))"},
+		{"block with var", "var a = 1;\n{ var a = 2; print a; }", "(var a (initializer 1))\n(block (var a (initializer 2)) (print (value a)))"},
+		{"block with fun", "{ fun f() {} }", "(block (fun f () ))"},
+		{"block with class", "{ class A {} }", "(block (class A superclass [] ))"},
+		{"if true with var", "if (true) { var a = 1; }", "(block (var a (initializer 1)))"},
+		{"if body with var", "if (x) { var a = 1; }", "(if (condition x) (block (var a (initializer 1))))"},
+		{"function body", "fun f() { return 2 * 3; }", "(fun f () (return (value 6)))"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			stmts := parse(t, test.source)
+			before := printed(t, stmts)
+
+			if got := printed(t, optimizer.Optimize(stmts)); got != test.want {
+				t.Errorf("got\n%s\nwant\n%s", got, test.want)
+			}
+			if printed(t, stmts) != before {
+				t.Error("the statements given were changed")
+			}
+		})
+	}
+}
+
+// run resolves and runs stmts, optimized first when optimize is set.
+func run(t *testing.T, stmts []parser.Stmt, optimize bool) (string, *runtime.RuntimeError) {
+	t.Helper()
+	var out strings.Builder
+	interpreter_ := interpreter.NewInterpreter()
+	interpreter_.Stdout = &out
+	if diags := resolver.NewResolver(interpreter_).Resolve(stmts); len(diags) > 0 {
+		t.Fatal(diags[0])
+	}
+	if optimize {
+		stmts = optimizer.Optimize(stmts)
+		if diags := resolver.NewResolver(interpreter_).Resolve(stmts); len(diags) > 0 {
+			t.Fatal(diags[0])
+		}
+	}
+	return out.String(), interpreter_.Interpret(stmts)
+}
+
+// TestRuntimeErrors makes sure expressions that would fail are left for the
+// script to fail on, where it would have without the optimizer.
+func TestRuntimeErrors(t *testing.T) {
+	tests := []struct {
+		source string
+		line   int
+	}{
+		{"print 1;\n\nprint \"a\" - 1;\n", 3},
+		{"print 1 +\n  (2 * \"b\");\n", 2},
+		{"if (true) {\n  print -\"a\";\n}\n", 2},
+		{"{\n  print 1;\n  print nil < 2;\n}\n", 3},
+		{"while (true and nil == nil) {\n  print 1;\n  print \"a\" * 2;\n}\n", 3},
+	}
+
+	for _, test := range tests {
+		stmts := parse(t, test.source)
+		wantOut, want := run(t, stmts, false)
+		gotOut, got := run(t, stmts, true)
+		if want == nil || got == nil {
+			t.Fatalf("%q: got %v optimized and %v not, want errors", test.source, got, want)
+		}
+		if got.Token.Line != test.line || want.Token.Line != test.line || got.Message != want.Message {
+			t.Errorf("%q: got %q at line %d optimized and %q at line %d not, want line %d", test.source, got.Message, got.Token.Line, want.Message, want.Token.Line, test.line)
+		}
+		if gotOut != wantOut {
+			t.Errorf("%q: printed %q optimized and %q not", test.source, gotOut, wantOut)
+		}
+	}
+}
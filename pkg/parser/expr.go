@@ -1,16 +1,14 @@
 package parser
 
 import (
-	"time"
-
 	"github.com/neet-007/glox/pkg/scanner"
 )
 
 /*
  NOTE:
-	the timestamp field on the structs is to produce a unique hash for
-	each new struct becouse when used in the map for interpeter you need
-	to find the right one and dont overwrite
+	the id field on the structs is given by the constructors, the
+	interpreter keys what the resolver found by it so two nodes that look
+	the same still resolve apart
 :
 */
 
@@ -34,19 +32,20 @@ type VisitExpr interface {
 
 type Expr interface {
 	Accept(visitor VisitExpr) (any, error)
+	ID() ID
 }
 
 type Super struct {
-	Keyword   scanner.Token
-	Method    scanner.Token
-	timestamp int64 // Unique field
+	Keyword scanner.Token
+	Method  scanner.Token
+	id      ID
 }
 
 func NewSuper(keyword scanner.Token, method scanner.Token) Super {
 	return Super{
-		Keyword:   keyword,
-		Method:    method,
-		timestamp: time.Now().UnixNano(),
+		Keyword: keyword,
+		Method:  method,
+		id:      nextID(),
 	}
 }
 
@@ -54,15 +53,19 @@ func (s Super) Accept(visitor VisitExpr) (any, error) {
 	return visitor.VisitSuperExpr(s)
 }
 
+func (s Super) ID() ID {
+	return s.id
+}
+
 type This struct {
-	Keyword   scanner.Token
-	timestamp int64 // Unique field
+	Keyword scanner.Token
+	id      ID
 }
 
 func NewThis(keyword scanner.Token) This {
 	return This{
-		Keyword:   keyword,
-		timestamp: time.Now().UnixNano(),
+		Keyword: keyword,
+		id:      nextID(),
 	}
 }
 
@@ -70,19 +73,23 @@ func (t This) Accept(visitor VisitExpr) (any, error) {
 	return visitor.VisitThisExpr(t)
 }
 
+func (t This) ID() ID {
+	return t.id
+}
+
 type Set struct {
-	Value     Expr
-	Object    Expr
-	Name      scanner.Token
-	timestamp int64 // Unique field
+	Value  Expr
+	Object Expr
+	Name   scanner.Token
+	id     ID
 }
 
 func NewSet(value Expr, object Expr, name scanner.Token) Set {
 	return Set{
-		Value:     value,
-		Object:    object,
-		Name:      name,
-		timestamp: time.Now().UnixNano(),
+		Value:  value,
+		Object: object,
+		Name:   name,
+		id:     nextID(),
 	}
 }
 
@@ -90,17 +97,21 @@ func (s Set) Accept(visitor VisitExpr) (any, error) {
 	return visitor.VisitSetExpr(s)
 }
 
+func (s Set) ID() ID {
+	return s.id
+}
+
 type Get struct {
-	Object    Expr
-	Name      scanner.Token
-	timestamp int64 // Unique field
+	Object Expr
+	Name   scanner.Token
+	id     ID
 }
 
 func NewGet(object Expr, name scanner.Token) Get {
 	return Get{
-		Object:    object,
-		Name:      name,
-		timestamp: time.Now().UnixNano(),
+		Object: object,
+		Name:   name,
+		id:     nextID(),
 	}
 }
 
@@ -108,11 +119,15 @@ func (g Get) Accept(visitor VisitExpr) (any, error) {
 	return visitor.VisitGetExpr(g)
 }
 
+func (g Get) ID() ID {
+	return g.id
+}
+
 type Call struct {
 	Callee    Expr
 	Paren     scanner.Token
 	Arguments []Expr
-	timestamp int64 // Unique field
+	id        ID
 }
 
 func NewCall(callee Expr, paren scanner.Token, arguments []Expr) Call {
@@ -120,7 +135,7 @@ func NewCall(callee Expr, paren scanner.Token, arguments []Expr) Call {
 		Callee:    callee,
 		Paren:     paren,
 		Arguments: arguments,
-		timestamp: time.Now().UnixNano(),
+		id:        nextID(),
 	}
 }
 
@@ -128,15 +143,19 @@ func (c Call) Accept(visitor VisitExpr) (any, error) {
 	return visitor.VisitCallExpr(c)
 }
 
+func (c Call) ID() ID {
+	return c.id
+}
+
 type Variable struct {
-	Name      scanner.Token
-	timestamp int64 // Unique field
+	Name scanner.Token
+	id   ID
 }
 
 func NewVariable(name scanner.Token) Variable {
 	return Variable{
-		Name:      name,
-		timestamp: time.Now().UnixNano(),
+		Name: name,
+		id:   nextID(),
 	}
 }
 
@@ -144,17 +163,21 @@ func (v Variable) Accept(visitor VisitExpr) (any, error) {
 	return visitor.VisitVariableExpr(v)
 }
 
+func (v Variable) ID() ID {
+	return v.id
+}
+
 type Assign struct {
-	Lexem     scanner.Token
-	Expr      Expr
-	timestamp int64 // Unique field
+	Lexem scanner.Token
+	Expr  Expr
+	id    ID
 }
 
 func NewAssign(lexem scanner.Token, expr Expr) Assign {
 	return Assign{
-		Lexem:     lexem,
-		Expr:      expr,
-		timestamp: time.Now().UnixNano(),
+		Lexem: lexem,
+		Expr:  expr,
+		id:    nextID(),
 	}
 }
 
@@ -162,19 +185,23 @@ func (a Assign) Accept(visitor VisitExpr) (any, error) {
 	return visitor.VisitAssignExpr(a)
 }
 
+func (a Assign) ID() ID {
+	return a.id
+}
+
 type Binary struct {
-	Left      Expr
-	Right     Expr
-	Operator  scanner.Token
-	timestamp int64 // Unique field
+	Left     Expr
+	Right    Expr
+	Operator scanner.Token
+	id       ID
 }
 
 func NewBinary(left Expr, right Expr, operator scanner.Token) Binary {
 	return Binary{
-		Left:      left,
-		Right:     right,
-		Operator:  operator,
-		timestamp: time.Now().UnixNano(),
+		Left:     left,
+		Right:    right,
+		Operator: operator,
+		id:       nextID(),
 	}
 }
 
@@ -182,15 +209,19 @@ func (b Binary) Accept(visitor VisitExpr) (any, error) {
 	return visitor.VisitBinaryExpr(b)
 }
 
+func (b Binary) ID() ID {
+	return b.id
+}
+
 type Grouping struct {
-	Expr      Expr
-	timestamp int64 // Unique field
+	Expr Expr
+	id   ID
 }
 
 func NewGrouping(expr Expr) Grouping {
 	return Grouping{
-		Expr:      expr,
-		timestamp: time.Now().UnixNano(),
+		Expr: expr,
+		id:   nextID(),
 	}
 }
 
@@ -198,15 +229,19 @@ func (g Grouping) Accept(visitor VisitExpr) (any, error) {
 	return visitor.VisitGroupingExpr(g)
 }
 
+func (g Grouping) ID() ID {
+	return g.id
+}
+
 type Literal struct {
-	Value     any
-	timestamp int64 // Unique field
+	Value any
+	id    ID
 }
 
 func NewLiteral(value any) Literal {
 	return Literal{
-		Value:     value,
-		timestamp: time.Now().UnixNano(),
+		Value: value,
+		id:    nextID(),
 	}
 }
 
@@ -214,21 +249,25 @@ func (l Literal) Accept(visitor VisitExpr) (any, error) {
 	return visitor.VisitLiteralExpr(l)
 }
 
+func (l Literal) ID() ID {
+	return l.id
+}
+
 type ListSet struct {
-	List      Expr
-	Index     Expr
-	Value     Expr
-	Token     scanner.Token
-	timestamp int64 // Unique field
+	List  Expr
+	Index Expr
+	Value Expr
+	Token scanner.Token
+	id    ID
 }
 
 func NewListSet(list Expr, index Expr, value Expr, token scanner.Token) ListSet {
 	return ListSet{
-		List:      list,
-		Index:     index,
-		Value:     value,
-		Token:     token,
-		timestamp: time.Now().UnixNano(),
+		List:  list,
+		Index: index,
+		Value: value,
+		Token: token,
+		id:    nextID(),
 	}
 }
 
@@ -236,19 +275,23 @@ func (l ListSet) Accept(visitor VisitExpr) (any, error) {
 	return visitor.VisitListSet(l)
 }
 
+func (l ListSet) ID() ID {
+	return l.id
+}
+
 type ListGet struct {
-	List      Expr
-	Index     Expr
-	Token     scanner.Token
-	timestamp int64 // Unique field
+	List  Expr
+	Index Expr
+	Token scanner.Token
+	id    ID
 }
 
 func NewListGet(list Expr, index Expr, token scanner.Token) ListGet {
 	return ListGet{
-		List:      list,
-		Index:     index,
-		Token:     token,
-		timestamp: time.Now().UnixNano(),
+		List:  list,
+		Index: index,
+		Token: token,
+		id:    nextID(),
 	}
 }
 
@@ -256,11 +299,15 @@ func (l ListGet) Accept(visitor VisitExpr) (any, error) {
 	return visitor.VisitListGet(l)
 }
 
+func (l ListGet) ID() ID {
+	return l.id
+}
+
 type ListExpr struct {
 	Literals     []Expr
 	LeftBracket  scanner.Token
 	RightBracket scanner.Token
-	timestamp    int64 // Unique field
+	id           ID
 }
 
 func NewListExpr(leftBracket scanner.Token, rightBracket scanner.Token, literals []Expr) ListExpr {
@@ -268,7 +315,7 @@ func NewListExpr(leftBracket scanner.Token, rightBracket scanner.Token, literals
 		LeftBracket:  leftBracket,
 		RightBracket: rightBracket,
 		Literals:     literals,
-		timestamp:    time.Now().UnixNano(),
+		id:           nextID(),
 	}
 }
 
@@ -276,19 +323,23 @@ func (l ListExpr) Accept(visitor VisitExpr) (any, error) {
 	return visitor.VisitListExpr(l)
 }
 
+func (l ListExpr) ID() ID {
+	return l.id
+}
+
 type Logical struct {
-	Left      Expr
-	Right     Expr
-	Operator  scanner.Token
-	timestamp int64 // Unique field
+	Left     Expr
+	Right    Expr
+	Operator scanner.Token
+	id       ID
 }
 
 func NewLogical(left Expr, right Expr, operator scanner.Token) Logical {
 	return Logical{
-		Left:      left,
-		Right:     right,
-		Operator:  operator,
-		timestamp: time.Now().UnixNano(),
+		Left:     left,
+		Right:    right,
+		Operator: operator,
+		id:       nextID(),
 	}
 }
 
@@ -296,20 +347,28 @@ func (l Logical) Accept(visitor VisitExpr) (any, error) {
 	return visitor.VisitLogicalExpr(l)
 }
 
+func (l Logical) ID() ID {
+	return l.id
+}
+
 type Unary struct {
-	Right     Expr
-	Operator  scanner.Token
-	timestamp int64 // Unique field
+	Right    Expr
+	Operator scanner.Token
+	id       ID
 }
 
 func NewUnary(right Expr, operator scanner.Token) Unary {
 	return Unary{
-		Right:     right,
-		Operator:  operator,
-		timestamp: time.Now().UnixNano(),
+		Right:    right,
+		Operator: operator,
+		id:       nextID(),
 	}
 }
 
 func (u Unary) Accept(visitor VisitExpr) (any, error) {
 	return visitor.VisitUnaryExpr(u)
 }
+
+func (u Unary) ID() ID {
+	return u.id
+}
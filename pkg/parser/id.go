@@ -0,0 +1,13 @@
+package parser
+
+import "sync/atomic"
+
+// ID identifies a syntax node. Every node made by its constructor gets one
+// no other node in the process has, the zero ID being left for zero values.
+type ID uint64
+
+var lastID atomic.Uint64
+
+func nextID() ID {
+	return ID(lastID.Add(1))
+}
@@ -0,0 +1,84 @@
+package parser
+
+import (
+	"sync"
+	"testing"
+
+	"github.com/neet-007/glox/pkg/scanner"
+)
+
+const idSource = `class A < B {
+  init(n) {
+    this.n = [n, 1];
+    print -n;
+    super.init();
+  }
+}
+fun f(a, b) {
+  if (a and b or !a) return a.n[0] = (b + 1) * 2;
+  while (a < b) { var c = a; a = c; }
+  print f(a, b);
+}
+`
+
+// ids parses idSource and returns the ID of every node in it.
+func ids(t *testing.T) []ID {
+	tokens, errs := scanner.NewFileScanner("test.lox", []byte(idSource), false).Scan()
+	if len(errs) > 0 {
+		t.Error(errs[0])
+		return nil
+	}
+	stmts, errs := NewParser(tokens, false).Parse()
+	if len(errs) > 0 {
+		t.Error(errs[0])
+		return nil
+	}
+
+	found := []ID{}
+	Inspect(stmts, func(node any) bool {
+		found = append(found, node.(interface{ ID() ID }).ID())
+		return true
+	})
+	return found
+}
+
+func TestIDsUniqueAcrossGoroutines(t *testing.T) {
+	const goroutines, parses = 8, 50
+
+	results := make([][]ID, goroutines)
+	var wg sync.WaitGroup
+	for g := range goroutines {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for range parses {
+				results[g] = append(results[g], ids(t)...)
+			}
+		}()
+	}
+	wg.Wait()
+
+	seen := map[ID]bool{}
+	total := 0
+	for _, found := range results {
+		for _, id := range found {
+			if id == 0 {
+				t.Fatal("a parsed node has the zero ID")
+			}
+			if seen[id] {
+				t.Fatalf("ID %d given to two nodes", id)
+			}
+			seen[id] = true
+			total++
+		}
+	}
+	if perParse := len(ids(t)); total != goroutines*parses*perParse || perParse < 30 {
+		t.Errorf("got %d IDs, want %d parses of %d nodes", total, goroutines*parses, perParse)
+	}
+}
+
+func TestZeroNodeHasZeroID(t *testing.T) {
+	if id := (Variable{}).ID(); id != 0 {
+		t.Errorf("a zero Variable has ID %d", id)
+	}
+}
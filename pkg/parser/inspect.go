@@ -0,0 +1,88 @@
+package parser
+
+// Inspect walks stmts depth first, calling visit with every statement and
+// expression in them. When visit returns false the children of that node
+// are skipped.
+func Inspect(stmts []Stmt, visit func(node any) bool) {
+	for _, stmt := range stmts {
+		inspectStmt(stmt, visit)
+	}
+}
+
+func inspectStmt(stmt Stmt, visit func(node any) bool) {
+	if stmt == nil || !visit(stmt) {
+		return
+	}
+
+	switch stmt := stmt.(type) {
+	case Class:
+		var zero Variable
+		if stmt.SuperClass != zero {
+			inspectExpr(stmt.SuperClass, visit)
+		}
+		for _, method := range stmt.Methods {
+			inspectStmt(method, visit)
+		}
+	case Return:
+		inspectExpr(stmt.Value, visit)
+	case Function:
+		Inspect(stmt.Body, visit)
+	case VarDeclaration:
+		inspectExpr(stmt.Initizlier, visit)
+	case WhileStmt:
+		inspectExpr(stmt.Condition, visit)
+		inspectStmt(stmt.Body, visit)
+	case Block:
+		Inspect(stmt.Statements, visit)
+	case IfStmt:
+		inspectExpr(stmt.Condition, visit)
+		inspectStmt(stmt.ThenBranch, visit)
+		inspectStmt(stmt.ElseBranch, visit)
+	case ExpressionStmt:
+		inspectExpr(stmt.Expression, visit)
+	case PrintStmt:
+		inspectExpr(stmt.Expression, visit)
+	}
+}
+
+func inspectExpr(expr Expr, visit func(node any) bool) {
+	if expr == nil || !visit(expr) {
+		return
+	}
+
+	switch expr := expr.(type) {
+	case Set:
+		inspectExpr(expr.Object, visit)
+		inspectExpr(expr.Value, visit)
+	case Get:
+		inspectExpr(expr.Object, visit)
+	case Call:
+		inspectExpr(expr.Callee, visit)
+		for _, argument := range expr.Arguments {
+			inspectExpr(argument, visit)
+		}
+	case Assign:
+		inspectExpr(expr.Expr, visit)
+	case Binary:
+		inspectExpr(expr.Left, visit)
+		inspectExpr(expr.Right, visit)
+	case Grouping:
+		inspectExpr(expr.Expr, visit)
+	case ListSet:
+		inspectExpr(expr.List, visit)
+		inspectExpr(expr.Index, visit)
+		inspectExpr(expr.Value, visit)
+	case ListGet:
+		inspectExpr(expr.List, visit)
+		inspectExpr(expr.Index, visit)
+	case ListExpr:
+		for _, literal := range expr.Literals {
+			inspectExpr(literal, visit)
+		}
+	case Logical:
+		inspectExpr(expr.Left, visit)
+		inspectExpr(expr.Right, visit)
+	case Unary:
+		inspectExpr(expr.Right, visit)
+	}
+}
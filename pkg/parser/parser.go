@@ -1,25 +1,14 @@
 package parser
 
 import (
-	"fmt"
-
+	"github.com/neet-007/glox/pkg/diagnostics"
 	"github.com/neet-007/glox/pkg/scanner"
 )
 
-type ParseError struct {
-	Token   scanner.Token
-	Message string
-}
+type ParseError = diagnostics.Diagnostic
 
-func newParseError(token scanner.Token, message string) *ParseError {
-	return &ParseError{
-		Token:   token,
-		Message: message,
-	}
-}
-
-func (p *ParseError) Error() string {
-	return fmt.Sprintf("parse error at %d with message %s\n", p.Token.Line, p.Message)
+func newParseError(token scanner.Token, code diagnostics.Code, message string) *ParseError {
+	return diagnostics.New(diagnostics.PhaseParser, code, token.Span(), message)
 }
 
 type Parser struct {
@@ -69,14 +58,14 @@ func (p *Parser) declaration() (Stmt, *ParseError) {
 }
 
 func (p *Parser) class() (Stmt, *ParseError) {
-	name, parseErr := p.consume(scanner.IDENTIFIER, "Expect identeifer for class")
+	name, parseErr := p.consume(scanner.IDENTIFIER, diagnostics.ExpectClassName, "Expect identeifer for class")
 	if parseErr != nil {
 		return nil, parseErr
 	}
 
 	var superClass Variable
 	if p.match(scanner.LESS) {
-		_, parseErr = p.consume(scanner.IDENTIFIER, "Expect identeifer for super class")
+		_, parseErr = p.consume(scanner.IDENTIFIER, diagnostics.ExpectSuperclassName, "Expect identeifer for super class")
 		if parseErr != nil {
 			return nil, parseErr
 		}
@@ -84,7 +73,7 @@ func (p *Parser) class() (Stmt, *ParseError) {
 		superClass = NewVariable(p.previous())
 	}
 
-	_, parseErr = p.consume(scanner.LEFT_BRACE, "Expect '{' after class")
+	_, parseErr = p.consume(scanner.LEFT_BRACE, diagnostics.ExpectClassBodyStart, "Expect '{' after class")
 	if parseErr != nil {
 		return nil, parseErr
 	}
@@ -99,7 +88,7 @@ func (p *Parser) class() (Stmt, *ParseError) {
 		methods = append(methods, method)
 	}
 
-	_, parseErr = p.consume(scanner.RIGHT_BRACE, "Expect ']' after class")
+	_, parseErr = p.consume(scanner.RIGHT_BRACE, diagnostics.ExpectClassBodyEnd, "Expect ']' after class")
 	if parseErr != nil {
 		return nil, parseErr
 	}
@@ -107,12 +96,12 @@ func (p *Parser) class() (Stmt, *ParseError) {
 }
 
 func (p *Parser) function(kind string) (Function, *ParseError) {
-	name, parseErr := p.consume(scanner.IDENTIFIER, "Expect function "+kind+" name")
+	name, parseErr := p.consume(scanner.IDENTIFIER, diagnostics.ExpectFunctionName, "Expect function "+kind+" name")
 	if parseErr != nil {
 		return Function{}, parseErr
 	}
 
-	_, parseErr = p.consume(scanner.LEFT_PAREN, "Expect '(' for function")
+	_, parseErr = p.consume(scanner.LEFT_PAREN, diagnostics.ExpectParametersStart, "Expect '(' for function")
 	if parseErr != nil {
 		return Function{}, parseErr
 	}
@@ -120,7 +109,7 @@ func (p *Parser) function(kind string) (Function, *ParseError) {
 	parameters := []scanner.Token{}
 	var paramSizeErr *ParseError
 	if !p.check(scanner.RIGHT_PAREN) {
-		_, parseErr = p.consume(scanner.IDENTIFIER, "Expect identefier for parameter")
+		_, parseErr = p.consume(scanner.IDENTIFIER, diagnostics.ExpectParameterName, "Expect identefier for parameter")
 		if parseErr != nil {
 			return Function{}, parseErr
 		}
@@ -128,9 +117,9 @@ func (p *Parser) function(kind string) (Function, *ParseError) {
 		parameters = append(parameters, p.previous())
 		for p.match(scanner.COMMA) {
 			if len(parameters) >= 255 {
-				paramSizeErr = newParseError(name, kind+"s have a max of 256 parameters")
+				paramSizeErr = newParseError(name, diagnostics.TooManyParameters, kind+"s have a max of 256 parameters")
 			}
-			_, parseErr = p.consume(scanner.IDENTIFIER, "Expect identefier for parameter")
+			_, parseErr = p.consume(scanner.IDENTIFIER, diagnostics.ExpectParameterName, "Expect identefier for parameter")
 			if parseErr != nil {
 				return Function{}, parseErr
 			}
@@ -138,12 +127,12 @@ func (p *Parser) function(kind string) (Function, *ParseError) {
 		}
 	}
 
-	_, parseErr = p.consume(scanner.RIGHT_PAREN, "Expect ')' for function")
+	_, parseErr = p.consume(scanner.RIGHT_PAREN, diagnostics.ExpectParametersEnd, "Expect ')' for function")
 	if parseErr != nil {
 		return Function{}, parseErr
 	}
 
-	_, parseErr = p.consume(scanner.LEFT_BRACE, "Expect '{' for block")
+	_, parseErr = p.consume(scanner.LEFT_BRACE, diagnostics.ExpectFunctionBodyStart, "Expect '{' for block")
 	if parseErr != nil {
 		return Function{}, parseErr
 	}
@@ -161,7 +150,8 @@ func (p *Parser) function(kind string) (Function, *ParseError) {
 }
 
 func (p *Parser) forStatement() (Stmt, *ParseError) {
-	_, parseErr := p.consume(scanner.LEFT_PAREN, "Expect '(' after for statement")
+	keyword := p.previous()
+	_, parseErr := p.consume(scanner.LEFT_PAREN, diagnostics.ExpectForStart, "Expect '(' after for statement")
 	if parseErr != nil {
 		return nil, parseErr
 	}
@@ -188,19 +178,20 @@ func (p *Parser) forStatement() (Stmt, *ParseError) {
 			return nil, parseErr
 		}
 	}
-	_, parseErr = p.consume(scanner.SEMICOLON, "Expect ';' after condition")
+	_, parseErr = p.consume(scanner.SEMICOLON, diagnostics.ExpectForConditionEnd, "Expect ';' after condition")
 	if parseErr != nil {
 		return nil, parseErr
 	}
 
 	var increment Expr
+	incrementStart := p.peek()
 	if !p.check(scanner.RIGHT_PAREN) {
 		increment, parseErr = p.expression()
 		if parseErr != nil {
 			return nil, parseErr
 		}
 	}
-	_, parseErr = p.consume(scanner.RIGHT_PAREN, "Expect ')' after increment")
+	_, parseErr = p.consume(scanner.RIGHT_PAREN, diagnostics.ExpectForClausesEnd, "Expect ')' after increment")
 	if parseErr != nil {
 		return nil, parseErr
 	}
@@ -211,24 +202,24 @@ func (p *Parser) forStatement() (Stmt, *ParseError) {
 	}
 
 	if increment != nil {
-		body = NewBlock([]Stmt{body, NewExpressionStmt(increment)})
+		body = NewBlock(keyword, []Stmt{body, NewExpressionStmt(incrementStart, increment)})
 	}
 
 	if condition == nil {
 		condition = NewLiteral(true)
 	}
 
-	body = NewWhileStmt(condition, body)
+	body = NewWhileStmt(keyword, condition, body)
 
 	if initizlier != nil {
-		body = NewBlock([]Stmt{initizlier, body})
+		body = NewBlock(keyword, []Stmt{initizlier, body})
 	}
 
 	return body, nil
 }
 
 func (p *Parser) varDeclaration() (Stmt, *ParseError) {
-	identifier, parserErr := p.consume(scanner.IDENTIFIER, "Expect identefier for variable")
+	identifier, parserErr := p.consume(scanner.IDENTIFIER, diagnostics.ExpectVariableName, "Expect identefier for variable")
 	if parserErr != nil {
 		return nil, parserErr
 	}
@@ -241,22 +232,27 @@ func (p *Parser) varDeclaration() (Stmt, *ParseError) {
 		}
 	}
 
-	_, parserErr = p.consume(scanner.SEMICOLON, "Expect ';' after expression")
+	_, parserErr = p.consume(scanner.SEMICOLON, diagnostics.ExpectVarSemicolon, "Expect ';' after expression")
+	if parserErr != nil {
+		return nil, parserErr
+	}
+
 	return NewVarDeclaration(identifier, initilizer), nil
 }
 
 func (p *Parser) statement() (Stmt, *ParseError) {
 	if p.match(scanner.PRINT) {
+		keyword := p.previous()
 		expr, parseErr := p.expression()
 		if parseErr != nil {
 			return nil, parseErr
 		}
 
-		_, parseErr = p.consume(scanner.SEMICOLON, "Expect ';' after expression")
+		_, parseErr = p.consume(scanner.SEMICOLON, diagnostics.ExpectPrintSemicolon, "Expect ';' after expression")
 		if parseErr != nil {
 			return nil, parseErr
 		}
-		return NewPrintStmt(expr), nil
+		return NewPrintStmt(keyword, expr), nil
 	}
 	if p.match(scanner.FOR) {
 		return p.forStatement()
@@ -271,12 +267,13 @@ func (p *Parser) statement() (Stmt, *ParseError) {
 		return p.returnStatemnt()
 	}
 	if p.match(scanner.LEFT_BRACE) {
+		brace := p.previous()
 		statements, parseErr := p.block()
 		if parseErr != nil {
 			return nil, parseErr
 		}
 
-		return NewBlock(statements), nil
+		return NewBlock(brace, statements), nil
 	}
 
 	return p.expressionStatement()
@@ -293,7 +290,7 @@ func (p *Parser) returnStatemnt() (Stmt, *ParseError) {
 		}
 	}
 
-	_, parseErr = p.consume(scanner.SEMICOLON, "Expect ';' after expression")
+	_, parseErr = p.consume(scanner.SEMICOLON, diagnostics.ExpectReturnSemicolon, "Expect ';' after expression")
 	if parseErr != nil {
 		return nil, parseErr
 	}
@@ -302,7 +299,8 @@ func (p *Parser) returnStatemnt() (Stmt, *ParseError) {
 }
 
 func (p *Parser) whileStatement() (Stmt, *ParseError) {
-	_, parseErr := p.consume(scanner.LEFT_PAREN, "Expect '(' afer if statemnt")
+	keyword := p.previous()
+	_, parseErr := p.consume(scanner.LEFT_PAREN, diagnostics.ExpectWhileStart, "Expect '(' afer if statemnt")
 	if parseErr != nil {
 		return nil, parseErr
 	}
@@ -311,7 +309,7 @@ func (p *Parser) whileStatement() (Stmt, *ParseError) {
 	if parseErr != nil {
 		return nil, parseErr
 	}
-	_, parseErr = p.consume(scanner.RIGHT_PAREN, "Expect ')' afer if statemnt")
+	_, parseErr = p.consume(scanner.RIGHT_PAREN, diagnostics.ExpectWhileEnd, "Expect ')' afer if statemnt")
 	if parseErr != nil {
 		return nil, parseErr
 	}
@@ -321,11 +319,12 @@ func (p *Parser) whileStatement() (Stmt, *ParseError) {
 		return nil, parseErr
 	}
 
-	return NewWhileStmt(expr, body), nil
+	return NewWhileStmt(keyword, expr, body), nil
 }
 
 func (p *Parser) ifStatement() (Stmt, *ParseError) {
-	_, parseErr := p.consume(scanner.LEFT_PAREN, "Expect '(' afer if statemnt")
+	keyword := p.previous()
+	_, parseErr := p.consume(scanner.LEFT_PAREN, diagnostics.ExpectIfStart, "Expect '(' afer if statemnt")
 	if parseErr != nil {
 		return nil, parseErr
 	}
@@ -335,7 +334,7 @@ func (p *Parser) ifStatement() (Stmt, *ParseError) {
 		return nil, parseErr
 	}
 
-	_, parseErr = p.consume(scanner.RIGHT_PAREN, "Expect ')' afer if statemnt")
+	_, parseErr = p.consume(scanner.RIGHT_PAREN, diagnostics.ExpectIfEnd, "Expect ')' afer if statemnt")
 	if parseErr != nil {
 		return nil, parseErr
 	}
@@ -353,7 +352,7 @@ func (p *Parser) ifStatement() (Stmt, *ParseError) {
 		}
 
 	}
-	return NewIfStmt(expr, ifBracnh, elseBranch), nil
+	return NewIfStmt(keyword, expr, ifBracnh, elseBranch), nil
 }
 
 func (p *Parser) block() ([]Stmt, *ParseError) {
@@ -367,7 +366,7 @@ func (p *Parser) block() ([]Stmt, *ParseError) {
 		statemnts = append(statemnts, statement)
 	}
 
-	_, parseErr := p.consume(scanner.RIGHT_BRACE, "Expect '}' after block")
+	_, parseErr := p.consume(scanner.RIGHT_BRACE, diagnostics.ExpectBlockEnd, "Expect '}' after block")
 	if parseErr != nil {
 		return nil, parseErr
 	}
@@ -376,17 +375,18 @@ func (p *Parser) block() ([]Stmt, *ParseError) {
 }
 
 func (p *Parser) expressionStatement() (Stmt, *ParseError) {
+	start := p.peek()
 	expr, parseErr := p.expression()
 	if parseErr != nil {
 		return nil, parseErr
 	}
 
-	_, parseErr = p.consume(scanner.SEMICOLON, "Expect ';' after expression")
+	_, parseErr = p.consume(scanner.SEMICOLON, diagnostics.ExpectExpressionSemicolon, "Expect ';' after expression")
 	if parseErr != nil {
 		return nil, parseErr
 	}
 
-	return NewExpressionStmt(expr), nil
+	return NewExpressionStmt(start, expr), nil
 }
 
 func (p *Parser) expression() (Expr, *ParseError) {
@@ -414,7 +414,7 @@ func (p *Parser) assignment() (Expr, *ParseError) {
 			return NewListSet(exprListGet.List, exprListGet.Index, val, exprListGet.Token), nil
 		}
 
-		return nil, newParseError(equal, "assigenmnt to invalid value")
+		return nil, newParseError(equal, diagnostics.InvalidAssignmentTarget, "assigenmnt to invalid value")
 	}
 	return expr, nil
 }
@@ -559,7 +559,7 @@ func (p *Parser) call() (Expr, *ParseError) {
 		if p.match(scanner.LEFT_PAREN) {
 			expr, parseErr = p.finishCall(expr)
 		} else if p.match(scanner.DOT) {
-			name, parseErr := p.consume(scanner.IDENTIFIER, "Expect idetnitfier for prop")
+			name, parseErr := p.consume(scanner.IDENTIFIER, diagnostics.ExpectPropertyName, "Expect idetnitfier for prop")
 			if parseErr != nil {
 				return nil, parseErr
 			}
@@ -579,7 +579,7 @@ func (p *Parser) finishList(expr Expr) (Expr, *ParseError) {
 	if parseErr != nil {
 		return nil, parseErr
 	}
-	token, parseErr := p.consume(scanner.RIGHT_BRACKET, "Expect ']' after index")
+	token, parseErr := p.consume(scanner.RIGHT_BRACKET, diagnostics.ExpectIndexEnd, "Expect ']' after index")
 	if parseErr != nil {
 		return nil, parseErr
 	}
@@ -590,7 +590,7 @@ func (p *Parser) finishList(expr Expr) (Expr, *ParseError) {
 func (p *Parser) finishCall(expr Expr) (Expr, *ParseError) {
 	arguments := []Expr{}
 
-	var argumentSizeErr *ParseError
+	tooManyArguments := false
 	if !p.check(scanner.RIGHT_PAREN) {
 		expr, parseErr := p.expression()
 		if parseErr != nil {
@@ -601,7 +601,7 @@ func (p *Parser) finishCall(expr Expr) (Expr, *ParseError) {
 
 		for p.match(scanner.COMMA) {
 			if len(arguments) > 255 {
-				argumentSizeErr = newParseError(scanner.Token{}, "calls have a max of 256 parameters")
+				tooManyArguments = true
 			}
 
 			expr, parseErr := p.expression()
@@ -613,14 +613,13 @@ func (p *Parser) finishCall(expr Expr) (Expr, *ParseError) {
 		}
 	}
 
-	paren, parseErr := p.consume(scanner.RIGHT_PAREN, "Expect ')' after call")
+	paren, parseErr := p.consume(scanner.RIGHT_PAREN, diagnostics.ExpectArgumentsEnd, "Expect ')' after call")
 	if parseErr != nil {
 		return nil, parseErr
 	}
 
-	if argumentSizeErr != nil {
-		argumentSizeErr.Token = paren
-		return nil, argumentSizeErr
+	if tooManyArguments {
+		return nil, newParseError(paren, diagnostics.TooManyArguments, "calls have a max of 256 parameters")
 	}
 	return NewCall(expr, paren, arguments), nil
 }
@@ -640,12 +639,12 @@ func (p *Parser) primary() (Expr, *ParseError) {
 	}
 	if p.match(scanner.SUPER) {
 		super := p.previous()
-		_, parseErr := p.consume(scanner.DOT, "Expect '.' for super call")
+		_, parseErr := p.consume(scanner.DOT, diagnostics.ExpectSuperDot, "Expect '.' for super call")
 		if parseErr != nil {
 			return nil, parseErr
 		}
 
-		method, parseErr := p.consume(scanner.IDENTIFIER, "Expect '.' for super call")
+		method, parseErr := p.consume(scanner.IDENTIFIER, diagnostics.ExpectSuperMethod, "Expect '.' for super call")
 		if parseErr != nil {
 			return nil, parseErr
 		}
@@ -672,7 +671,7 @@ func (p *Parser) primary() (Expr, *ParseError) {
 			}
 		}
 
-		rightBracket, parseErr := p.consume(scanner.RIGHT_BRACKET, "expect ']' after list")
+		rightBracket, parseErr := p.consume(scanner.RIGHT_BRACKET, diagnostics.ExpectListEnd, "expect ']' after list")
 		if parseErr != nil {
 			return nil, parseErr
 		}
@@ -685,7 +684,7 @@ func (p *Parser) primary() (Expr, *ParseError) {
 			return nil, parseErr
 		}
 
-		_, parseErr = p.consume(scanner.RIGHT_PAREN, "Expect ')' after grouping")
+		_, parseErr = p.consume(scanner.RIGHT_PAREN, diagnostics.ExpectGroupingEnd, "Expect ')' after grouping")
 
 		if parseErr != nil {
 			return nil, parseErr
@@ -702,19 +701,21 @@ func (p *Parser) primary() (Expr, *ParseError) {
 		return NewVariable(p.previous()), nil
 	}
 
-	return nil, newParseError(p.peek(), "invalid primary")
+	return nil, newParseError(p.peek(), diagnostics.ExpectExpression, "invalid primary")
 }
 
-func (p *Parser) consume(tokenType scanner.TokenType, message string) (scanner.Token, *ParseError) {
+func (p *Parser) consume(tokenType scanner.TokenType, code diagnostics.Code, message string) (scanner.Token, *ParseError) {
 	if p.check(tokenType) {
 		return p.advnace(), nil
 	}
 
-	return scanner.Token{}, newParseError(p.peek(), message)
+	return scanner.Token{}, newParseError(p.peek(), code, message)
 }
 
 func (p *Parser) advnace() scanner.Token {
-	p.current++
+	if !p.isAtEnd() {
+		p.current++
+	}
 
 	return p.previous()
 }
@@ -3,16 +3,15 @@ package parser
 import (
 	"fmt"
 	"strings"
-	"time"
 
 	"github.com/neet-007/glox/pkg/scanner"
 )
 
 /*
  NOTE:
-	the timestamp field on the structs is to produce a unique hash for
-	each new struct becouse when used in the map for interpeter you need
-	to find the right one and dont overwrite
+	the id field on the structs is given by the constructors, the
+	interpreter keys what the resolver found by it so two nodes that look
+	the same still resolve apart
 :
 */
 
@@ -30,13 +29,14 @@ type VisitStmt interface {
 
 type Stmt interface {
 	Accept(visitor VisitStmt) (any, error)
+	ID() ID
 }
 
 type Class struct {
 	Name       scanner.Token
 	Methods    []Function
 	SuperClass Variable
-	timestamp  int64 // Unique field
+	id         ID
 }
 
 func NewClass(name scanner.Token, methods []Function, superClass Variable) Class {
@@ -44,7 +44,7 @@ func NewClass(name scanner.Token, methods []Function, superClass Variable) Class
 		Name:       name,
 		Methods:    methods,
 		SuperClass: superClass,
-		timestamp:  time.Now().UnixNano(),
+		id:         nextID(),
 	}
 }
 
@@ -75,17 +75,21 @@ func (c Class) Accept(visitor VisitStmt) (any, error) {
 	return visitor.VisitClassStmt(c)
 }
 
+func (c Class) ID() ID {
+	return c.id
+}
+
 type Return struct {
-	Keyword   scanner.Token
-	Value     Expr
-	timestamp int64 // Unique field
+	Keyword scanner.Token
+	Value   Expr
+	id      ID
 }
 
 func NewReturn(keyword scanner.Token, value Expr) Return {
 	return Return{
-		Keyword:   keyword,
-		Value:     value,
-		timestamp: time.Now().UnixNano(),
+		Keyword: keyword,
+		Value:   value,
+		id:      nextID(),
 	}
 }
 
@@ -97,11 +101,15 @@ func (r Return) Accept(visitor VisitStmt) (any, error) {
 	return visitor.VisitReturnStmt(r)
 }
 
+func (r Return) ID() ID {
+	return r.id
+}
+
 type Function struct {
 	Name       scanner.Token
 	Parameters []scanner.Token
 	Body       []Stmt
-	timestamp  int64 // Unique field
+	id         ID
 }
 
 func NewFunction(name scanner.Token, parameters []scanner.Token, body []Stmt) Function {
@@ -109,7 +117,7 @@ func NewFunction(name scanner.Token, parameters []scanner.Token, body []Stmt) Fu
 		Name:       name,
 		Parameters: parameters,
 		Body:       body,
-		timestamp:  time.Now().UnixNano(),
+		id:         nextID(),
 	}
 }
 
@@ -121,10 +129,14 @@ func (f Function) Accept(visitor VisitStmt) (any, error) {
 	return visitor.VisitFunctionStmt(f)
 }
 
+func (f Function) ID() ID {
+	return f.id
+}
+
 type VarDeclaration struct {
 	Initizlier Expr
 	Name       scanner.Token
-	timestamp  int64 // Unique field
+	id         ID
 }
 
 func (v VarDeclaration) String() string {
@@ -135,7 +147,7 @@ func NewVarDeclaration(name scanner.Token, initizlier Expr) VarDeclaration {
 	return VarDeclaration{
 		Name:       name,
 		Initizlier: initizlier,
-		timestamp:  time.Now().UnixNano(),
+		id:         nextID(),
 	}
 }
 
@@ -143,21 +155,27 @@ func (v VarDeclaration) Accept(visitor VisitStmt) (any, error) {
 	return visitor.VisitVarDeclaration(v)
 }
 
+func (v VarDeclaration) ID() ID {
+	return v.id
+}
+
 type WhileStmt struct {
+	Keyword   scanner.Token
 	Condition Expr
 	Body      Stmt
-	timestamp int64 // Unique field
+	id        ID
 }
 
 func (w WhileStmt) String() string {
 	return fmt.Sprintf("body:%v conditno:%v\n", w.Body, w.Condition)
 }
 
-func NewWhileStmt(condition Expr, block Stmt) WhileStmt {
+func NewWhileStmt(keyword scanner.Token, condition Expr, block Stmt) WhileStmt {
 	return WhileStmt{
+		Keyword:   keyword,
 		Condition: condition,
 		Body:      block,
-		timestamp: time.Now().UnixNano(),
+		id:        nextID(),
 	}
 }
 
@@ -165,9 +183,14 @@ func (w WhileStmt) Accept(visitor VisitStmt) (any, error) {
 	return visitor.VisitWhileStmt(w)
 }
 
+func (w WhileStmt) ID() ID {
+	return w.id
+}
+
 type Block struct {
+	Brace      scanner.Token
 	Statements []Stmt
-	timestamp  int64 // Unique field
+	id         ID
 }
 
 func (b Block) String() string {
@@ -180,10 +203,11 @@ func (b Block) String() string {
 	return fmt.Sprintf("%s\n", builder.String())
 }
 
-func NewBlock(statements []Stmt) Block {
+func NewBlock(brace scanner.Token, statements []Stmt) Block {
 	return Block{
+		Brace:      brace,
 		Statements: statements,
-		timestamp:  time.Now().UnixNano(),
+		id:         nextID(),
 	}
 }
 
@@ -191,23 +215,29 @@ func (b Block) Accept(visitor VisitStmt) (any, error) {
 	return visitor.VisitBlockStmt(b)
 }
 
+func (b Block) ID() ID {
+	return b.id
+}
+
 type IfStmt struct {
+	Keyword    scanner.Token
 	Condition  Expr
 	ThenBranch Stmt
 	ElseBranch Stmt
-	timestamp  int64 // Unique field
+	id         ID
 }
 
 func (i IfStmt) String() string {
 	return fmt.Sprintf("condtion:%v then:%v else:%v\n", i.Condition, i.ThenBranch, i.ElseBranch)
 }
 
-func NewIfStmt(condition Expr, thenBranch Stmt, elseBranch Stmt) IfStmt {
+func NewIfStmt(keyword scanner.Token, condition Expr, thenBranch Stmt, elseBranch Stmt) IfStmt {
 	return IfStmt{
+		Keyword:    keyword,
 		Condition:  condition,
 		ThenBranch: thenBranch,
 		ElseBranch: elseBranch,
-		timestamp:  time.Now().UnixNano(),
+		id:         nextID(),
 	}
 }
 
@@ -215,19 +245,25 @@ func (i IfStmt) Accept(visitor VisitStmt) (any, error) {
 	return visitor.VisitIfStmt(i)
 }
 
+func (i IfStmt) ID() ID {
+	return i.id
+}
+
 type ExpressionStmt struct {
+	Start      scanner.Token
 	Expression Expr
-	timestamp  int64 // Unique field
+	id         ID
 }
 
 func (e ExpressionStmt) String() string {
 	return fmt.Sprintf("expr:%v\n", e.Expression)
 }
 
-func NewExpressionStmt(expr Expr) ExpressionStmt {
+func NewExpressionStmt(start scanner.Token, expr Expr) ExpressionStmt {
 	return ExpressionStmt{
+		Start:      start,
 		Expression: expr,
-		timestamp:  time.Now().UnixNano(),
+		id:         nextID(),
 	}
 }
 
@@ -235,22 +271,85 @@ func (e ExpressionStmt) Accept(visitor VisitStmt) (any, error) {
 	return visitor.VisitExpressionStmt(e)
 }
 
+func (e ExpressionStmt) ID() ID {
+	return e.id
+}
+
 type PrintStmt struct {
+	Keyword    scanner.Token
 	Expression Expr
-	timestamp  int64 // Unique field
+	id         ID
 }
 
 func (p PrintStmt) String() string {
 	return fmt.Sprintf("print expr:%v\n", p.Expression)
 }
 
-func NewPrintStmt(expr Expr) PrintStmt {
+func NewPrintStmt(keyword scanner.Token, expr Expr) PrintStmt {
 	return PrintStmt{
+		Keyword:    keyword,
 		Expression: expr,
-		timestamp:  time.Now().UnixNano(),
+		id:         nextID(),
 	}
 }
 
 func (p PrintStmt) Accept(visitor VisitStmt) (any, error) {
 	return visitor.VisitPrintStmt(p)
 }
+
+func (p PrintStmt) ID() ID {
+	return p.id
+}
+
+// StmtToken returns the token a statement starts at, used to report the
+// line of errors raised while executing it.
+func StmtToken(stmt Stmt) scanner.Token {
+	switch stmt := stmt.(type) {
+	case Class:
+		return stmt.Name
+	case Return:
+		return stmt.Keyword
+	case Function:
+		return stmt.Name
+	case VarDeclaration:
+		return stmt.Name
+	case WhileStmt:
+		return stmt.Keyword
+	case Block:
+		return stmt.Brace
+	case IfStmt:
+		return stmt.Keyword
+	case ExpressionStmt:
+		return stmt.Start
+	case PrintStmt:
+		return stmt.Keyword
+	default:
+		return scanner.Token{TokenType: scanner.Error}
+	}
+}
+
+// StmtName is the kind of stmt as a word, like "print" or "while".
+func StmtName(stmt Stmt) string {
+	switch stmt.(type) {
+	case Class:
+		return "class"
+	case Return:
+		return "return"
+	case Function:
+		return "fun"
+	case VarDeclaration:
+		return "var"
+	case WhileStmt:
+		return "while"
+	case Block:
+		return "block"
+	case IfStmt:
+		return "if"
+	case ExpressionStmt:
+		return "expression"
+	case PrintStmt:
+		return "print"
+	default:
+		return "unknown"
+	}
+}
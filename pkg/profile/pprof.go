@@ -0,0 +1,240 @@
+package profile
+
+import (
+	"compress/gzip"
+	"io"
+)
+
+// The profile is encoded by hand following
+// https://github.com/google/pprof/blob/main/proto/profile.proto, with one
+// sample per call path. Its values are the number of calls on the path and
+// the nanoseconds spent in the path's own code, so pprof's flat and cum
+// columns are the exclusive and inclusive time.
+
+// Field numbers of the profile.proto messages used.
+const (
+	profileSampleType        = 1
+	profileSample            = 2
+	profileLocation          = 4
+	profileFunction          = 5
+	profileStringTable       = 6
+	profileTimeNanos         = 9
+	profileDurationNanos     = 10
+	profilePeriodType        = 11
+	profilePeriod            = 12
+	profileDefaultSampleType = 14
+
+	valueTypeType = 1
+	valueTypeUnit = 2
+
+	sampleLocationID = 1
+	sampleValue      = 2
+
+	locationID   = 1
+	locationLine = 4
+
+	lineFunctionID = 1
+	lineLine       = 2
+
+	functionID         = 1
+	functionName       = 2
+	functionSystemName = 3
+	functionFilename   = 4
+)
+
+// WriteTo writes the profile gzipped in pprof's format.
+func (p *Profiler) WriteTo(w io.Writer) (int64, error) {
+	p.Stop()
+
+	counter := &countingWriter{w: w}
+	zipped := gzip.NewWriter(counter)
+	if _, err := zipped.Write(p.encode()); err != nil {
+		return counter.n, err
+	}
+	err := zipped.Close()
+	return counter.n, err
+}
+
+type locationKey struct {
+	function string
+	line     int
+}
+
+func (p *Profiler) encode() []byte {
+	strings := newStringTable()
+	functions := map[string]uint64{}
+	functionOrder := []string{}
+	locations := map[locationKey]uint64{}
+	locationOrder := []locationKey{}
+
+	location := func(function string, line int) uint64 {
+		if _, ok := functions[function]; !ok {
+			functions[function] = uint64(len(functions) + 1)
+			functionOrder = append(functionOrder, function)
+		}
+
+		key := locationKey{function: function, line: line}
+		if _, ok := locations[key]; !ok {
+			locations[key] = uint64(len(locations) + 1)
+			locationOrder = append(locationOrder, key)
+		}
+		return locations[key]
+	}
+
+	out := &encoder{}
+	for _, sampleType := range [][2]string{{"calls", "count"}, {"time", "nanoseconds"}} {
+		out.message(profileSampleType, func(e *encoder) {
+			e.int64(valueTypeType, strings.index(sampleType[0]))
+			e.int64(valueTypeUnit, strings.index(sampleType[1]))
+		})
+	}
+
+	p.root.walk(func(n *node) {
+		// the stack from the leaf out, each frame at the line of the call
+		// into the one before it
+		ids := []uint64{location(n.name, 0)}
+		for child, parent := n, n.parent; parent != nil; child, parent = parent, parent.parent {
+			ids = append(ids, location(parent.name, child.line))
+		}
+
+		out.message(profileSample, func(e *encoder) {
+			e.packedUint64(sampleLocationID, ids)
+			e.packedInt64(sampleValue, []int64{n.calls, n.self().Nanoseconds()})
+		})
+	})
+
+	for _, key := range locationOrder {
+		out.message(profileLocation, func(e *encoder) {
+			e.uint64(locationID, locations[key])
+			e.message(locationLine, func(e *encoder) {
+				e.uint64(lineFunctionID, functions[key.function])
+				e.int64(lineLine, int64(key.line))
+			})
+		})
+	}
+
+	for _, function := range functionOrder {
+		out.message(profileFunction, func(e *encoder) {
+			e.uint64(functionID, functions[function])
+			e.int64(functionName, strings.index(function))
+			e.int64(functionSystemName, strings.index(function))
+			e.int64(functionFilename, strings.index(p.file))
+		})
+	}
+
+	out.int64(profileTimeNanos, p.start.UnixNano())
+	out.int64(profileDurationNanos, p.end.Sub(p.start).Nanoseconds())
+	out.message(profilePeriodType, func(e *encoder) {
+		e.int64(valueTypeType, strings.index("time"))
+		e.int64(valueTypeUnit, strings.index("nanoseconds"))
+	})
+	out.int64(profilePeriod, 1)
+	out.int64(profileDefaultSampleType, strings.index("time"))
+
+	// the string table goes last, once every string has been given an index
+	for _, s := range strings.strings {
+		out.bytes(profileStringTable, []byte(s))
+	}
+
+	return out.buf
+}
+
+type stringTable struct {
+	strings []string
+	indexes map[string]int64
+}
+
+func newStringTable() *stringTable {
+	// string 0 is always the empty string
+	return &stringTable{
+		strings: []string{""},
+		indexes: map[string]int64{"": 0},
+	}
+}
+
+func (t *stringTable) index(s string) int64 {
+	if index, ok := t.indexes[s]; ok {
+		return index
+	}
+
+	index := int64(len(t.strings))
+	t.strings = append(t.strings, s)
+	t.indexes[s] = index
+	return index
+}
+
+// encoder writes protocol buffer fields. Zero values are left out, as
+// proto3 does.
+type encoder struct {
+	buf []byte
+}
+
+const (
+	wireVarint = 0
+	wireBytes  = 2
+)
+
+func (e *encoder) varint(x uint64) {
+	for x >= 0x80 {
+		e.buf = append(e.buf, byte(x)|0x80)
+		x >>= 7
+	}
+	e.buf = append(e.buf, byte(x))
+}
+
+func (e *encoder) tag(field int, wire int) {
+	e.varint(uint64(field)<<3 | uint64(wire))
+}
+
+func (e *encoder) uint64(field int, x uint64) {
+	if x == 0 {
+		return
+	}
+	e.tag(field, wireVarint)
+	e.varint(x)
+}
+
+func (e *encoder) int64(field int, x int64) {
+	e.uint64(field, uint64(x))
+}
+
+// bytes writes a length delimited field. Unlike the others it is written
+// when empty, the string table needs its leading "".
+func (e *encoder) bytes(field int, b []byte) {
+	e.tag(field, wireBytes)
+	e.varint(uint64(len(b)))
+	e.buf = append(e.buf, b...)
+}
+
+func (e *encoder) message(field int, build func(e *encoder)) {
+	inner := &encoder{}
+	build(inner)
+	e.bytes(field, inner.buf)
+}
+
+func (e *encoder) packedUint64(field int, xs []uint64) {
+	inner := &encoder{}
+	for _, x := range xs {
+		inner.varint(x)
+	}
+	e.bytes(field, inner.buf)
+}
+
+func (e *encoder) packedInt64(field int, xs []int64) {
+	inner := &encoder{}
+	for _, x := range xs {
+		inner.varint(uint64(x))
+	}
+	e.bytes(field, inner.buf)
+}
+
+type countingWriter struct {
+	w io.Writer
+	n int64
+}
+
+func (c *countingWriter) Write(p []byte) (int, error) {
+	n, err := c.w.Write(p)
+	c.n += int64(n)
+	return n, err
+}
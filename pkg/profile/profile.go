@@ -0,0 +1,122 @@
+package profile
+
+import (
+	"time"
+
+	"github.com/neet-007/glox/pkg/trace"
+)
+
+// Profiler is a trace.Tracer that times lox calls. It keeps a tree of the
+// call paths seen, with the number of calls and the time spent on each, and
+// writes it out as a pprof profile.
+type Profiler struct {
+	root  *node
+	stack []*call
+	// file is the script, taken from the first call.
+	file  string
+	start time.Time
+	end   time.Time
+}
+
+// node is a call path, a function called from its parent at line.
+type node struct {
+	name     string
+	line     int
+	parent   *node
+	children []*node
+	calls    int64
+	// total is the time spent in the calls, children included.
+	total time.Duration
+}
+
+type call struct {
+	node  *node
+	start time.Time
+}
+
+func NewProfiler() *Profiler {
+	return &Profiler{
+		root:  &node{name: "main", calls: 1},
+		start: time.Now(),
+	}
+}
+
+func (p *Profiler) Trace(event trace.Event) {
+	switch event.Kind {
+	case trace.Call:
+		if p.file == "" {
+			p.file = event.File
+		}
+		p.stack = append(p.stack, &call{
+			node:  p.top().child(event.Name, event.Line),
+			start: time.Now(),
+		})
+	case trace.Return:
+		if len(p.stack) > 0 {
+			p.pop(time.Now())
+		}
+	case trace.Error:
+		// the error ends every call in progress
+		now := time.Now()
+		for len(p.stack) > 0 {
+			p.pop(now)
+		}
+	}
+}
+
+func (p *Profiler) top() *node {
+	if len(p.stack) == 0 {
+		return p.root
+	}
+	return p.stack[len(p.stack)-1].node
+}
+
+func (p *Profiler) pop(now time.Time) {
+	call := p.stack[len(p.stack)-1]
+	p.stack = p.stack[:len(p.stack)-1]
+	call.node.calls++
+	call.node.total += now.Sub(call.start)
+}
+
+// Stop ends the profile, closing calls still in progress. Writing the
+// profile stops it if it was not already.
+func (p *Profiler) Stop() {
+	if !p.end.IsZero() {
+		return
+	}
+
+	p.end = time.Now()
+	for len(p.stack) > 0 {
+		p.pop(p.end)
+	}
+	p.root.total = p.end.Sub(p.start)
+}
+
+func (n *node) child(name string, line int) *node {
+	for _, child := range n.children {
+		if child.name == name && child.line == line {
+			return child
+		}
+	}
+
+	child := &node{name: name, line: line, parent: n}
+	n.children = append(n.children, child)
+	return child
+}
+
+// self is the time spent in the node's own code.
+func (n *node) self() time.Duration {
+	self := n.total
+	for _, child := range n.children {
+		self -= child.total
+	}
+	return max(self, 0)
+}
+
+// walk calls visit on n and every node under it, parents first.
+func (n *node) walk(visit func(*node)) {
+	visit(n)
+	for _, child := range n.children {
+		child.walk(visit)
+	}
+}
@@ -0,0 +1,253 @@
+package profile
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/neet-007/glox/pkg/trace"
+)
+
+// field is a protocol buffer field, value holding a varint and data the
+// bytes of a length delimited one.
+type field struct {
+	number int
+	value  uint64
+	data   []byte
+}
+
+func fields(t *testing.T, b []byte) []field {
+	t.Helper()
+	found := []field{}
+	for len(b) > 0 {
+		tag, n := binary.Uvarint(b)
+		if n <= 0 {
+			t.Fatalf("bad tag in %x", b)
+		}
+		b = b[n:]
+
+		f := field{number: int(tag >> 3)}
+		switch tag & 7 {
+		case wireVarint:
+			f.value, n = binary.Uvarint(b)
+			if n <= 0 {
+				t.Fatalf("bad varint in %x", b)
+			}
+			b = b[n:]
+		case wireBytes:
+			length, n := binary.Uvarint(b)
+			if n <= 0 || uint64(len(b)-n) < length {
+				t.Fatalf("bad length in %x", b)
+			}
+			f.data = b[n : n+int(length)]
+			b = b[n+int(length):]
+		default:
+			t.Fatalf("unexpected wire type %d", tag&7)
+		}
+		found = append(found, f)
+	}
+	return found
+}
+
+func varints(t *testing.T, b []byte) []uint64 {
+	t.Helper()
+	found := []uint64{}
+	for len(b) > 0 {
+		x, n := binary.Uvarint(b)
+		if n <= 0 {
+			t.Fatalf("bad varint in %x", b)
+		}
+		found = append(found, x)
+		b = b[n:]
+	}
+	return found
+}
+
+// sample is a decoded sample, its stack written leaf first as
+// "function:line" with the line of the call made from each frame.
+type sample struct {
+	stack string
+	calls int64
+}
+
+// decode reads a profile written by WriteTo back into its samples, the
+// file the functions were named with and the sample types.
+func decode(t *testing.T, profile []byte) (samples []sample, file string, types []string) {
+	t.Helper()
+	zipped, err := gzip.NewReader(bytes.NewReader(profile))
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := io.ReadAll(zipped)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	strings := []string{}
+	type location struct {
+		function uint64
+		line     uint64
+	}
+	locations := map[uint64]location{}
+	functions := map[uint64][2]uint64{}
+	rawSamples := [][]field{}
+	sampleTypes := [][]field{}
+	for _, f := range fields(t, data) {
+		switch f.number {
+		case profileStringTable:
+			strings = append(strings, string(f.data))
+		case profileSample:
+			rawSamples = append(rawSamples, fields(t, f.data))
+		case profileSampleType:
+			sampleTypes = append(sampleTypes, fields(t, f.data))
+		case profileLocation:
+			var id uint64
+			var l location
+			for _, f := range fields(t, f.data) {
+				switch f.number {
+				case locationID:
+					id = f.value
+				case locationLine:
+					for _, f := range fields(t, f.data) {
+						switch f.number {
+						case lineFunctionID:
+							l.function = f.value
+						case lineLine:
+							l.line = f.value
+						}
+					}
+				}
+			}
+			locations[id] = l
+		case profileFunction:
+			var id, name, filename uint64
+			for _, f := range fields(t, f.data) {
+				switch f.number {
+				case functionID:
+					id = f.value
+				case functionName:
+					name = f.value
+				case functionFilename:
+					filename = f.value
+				}
+			}
+			functions[id] = [2]uint64{name, filename}
+		}
+	}
+
+	str := func(index uint64) string {
+		if index >= uint64(len(strings)) {
+			t.Fatalf("string %d of %d", index, len(strings))
+		}
+		return strings[index]
+	}
+	for _, sampleType := range sampleTypes {
+		var typ, unit uint64
+		for _, f := range sampleType {
+			switch f.number {
+			case valueTypeType:
+				typ = f.value
+			case valueTypeUnit:
+				unit = f.value
+			}
+		}
+		types = append(types, str(typ)+"/"+str(unit))
+	}
+	for _, raw := range rawSamples {
+		var s sample
+		for _, f := range raw {
+			switch f.number {
+			case sampleLocationID:
+				for j, id := range varints(t, f.data) {
+					l, ok := locations[id]
+					if !ok {
+						t.Fatalf("sample has unknown location %d", id)
+					}
+					if j > 0 {
+						s.stack += " "
+					}
+					s.stack += fmt.Sprintf("%s:%d", str(functions[l.function][0]), l.line)
+					file = str(functions[l.function][1])
+				}
+			case sampleValue:
+				values := varints(t, f.data)
+				if len(values) != 2 {
+					t.Fatalf("got %d values, want calls and time", len(values))
+				}
+				s.calls = int64(values[0])
+			}
+		}
+		samples = append(samples, s)
+	}
+	return samples, file, types
+}
+
+func TestProfile(t *testing.T) {
+	profiler := NewProfiler()
+	call := func(name string, line int) {
+		profiler.Trace(trace.Event{Kind: trace.Call, File: "test.lox", Line: line, Name: name})
+	}
+	ret := func(name string) {
+		profiler.Trace(trace.Event{Kind: trace.Return, File: "test.lox", Name: name})
+	}
+
+	// main calls f on line 10 twice, f calling g on line 2 each time, and
+	// h on line 11, which fails inside i
+	for range 2 {
+		call("f", 10)
+		call("g", 2)
+		ret("g")
+		ret("f")
+	}
+	call("h", 11)
+	call("i", 5)
+	profiler.Trace(trace.Event{Kind: trace.Error, File: "test.lox", Line: 6, Message: "boom"})
+
+	var out bytes.Buffer
+	if _, err := profiler.WriteTo(&out); err != nil {
+		t.Fatal(err)
+	}
+	samples, file, types := decode(t, out.Bytes())
+
+	want := []sample{
+		{"main:0", 1},
+		{"f:0 main:10", 2},
+		{"g:0 f:2 main:10", 2},
+		{"h:0 main:11", 1},
+		{"i:0 h:5 main:11", 1},
+	}
+	if !reflect.DeepEqual(samples, want) {
+		t.Errorf("got samples %v, want %v", samples, want)
+	}
+	if file != "test.lox" {
+		t.Errorf("got functions in %q, want test.lox", file)
+	}
+	if want := []string{"calls/count", "time/nanoseconds"}; !reflect.DeepEqual(types, want) {
+		t.Errorf("got sample types %v, want %v", types, want)
+	}
+}
+
+func TestProfileTime(t *testing.T) {
+	profiler := NewProfiler()
+	profiler.Trace(trace.Event{Kind: trace.Call, File: "test.lox", Line: 1, Name: "f"})
+	profiler.Trace(trace.Event{Kind: trace.Call, File: "test.lox", Line: 2, Name: "g"})
+	time.Sleep(10 * time.Millisecond)
+	profiler.Trace(trace.Event{Kind: trace.Return, File: "test.lox", Name: "g"})
+	profiler.Trace(trace.Event{Kind: trace.Return, File: "test.lox", Name: "f"})
+	profiler.Stop()
+
+	f := profiler.root.children[0]
+	g := f.children[0]
+	if g.self() < 10*time.Millisecond {
+		t.Errorf("g took %v of its own, want at least 10ms", g.self())
+	}
+	// the time in g is not f's own
+	if f.self() >= g.self() || f.total < g.total {
+		t.Errorf("f took %v of its own and %v in all, g %v", f.self(), f.total, g.total)
+	}
+}
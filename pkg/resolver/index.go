@@ -0,0 +1,322 @@
+package resolver
+
+import (
+	"github.com/neet-007/glox/pkg/scanner"
+)
+
+type SymbolKind int
+
+const (
+	VariableSymbol SymbolKind = iota
+	ParameterSymbol
+	FunctionSymbol
+	ClassSymbol
+	MethodSymbol
+)
+
+func (k SymbolKind) String() string {
+	switch k {
+	case VariableSymbol:
+		return "var"
+	case ParameterSymbol:
+		return "parameter"
+	case FunctionSymbol:
+		return "fun"
+	case ClassSymbol:
+		return "class"
+	case MethodSymbol:
+		return "method"
+	default:
+		return "UNKNOWN_SYMBOL_KIND"
+	}
+}
+
+// Symbol is a declared name. Scope is nil for globals, Class is the class
+// a method belongs to.
+type Symbol struct {
+	Name       scanner.Token
+	Kind       SymbolKind
+	Parameters []scanner.Token
+	Class      string
+	Superclass string
+	Scope      *Scope
+}
+
+// Scope is a block, function or class scope. Start is the token it opens
+// at and End the offset just after its last token, set by SetExtents.
+type Scope struct {
+	Start   scanner.Token
+	End     int
+	Parent  *Scope
+	Symbols []*Symbol
+}
+
+func (s *Scope) Contains(offset int) bool {
+	return s.Start.Offset <= offset && offset < s.End
+}
+
+// Reference is a use of a symbol.
+type Reference struct {
+	Name   scanner.Token
+	Symbol *Symbol
+}
+
+// Index is what the resolver learnt about the names of a script, for
+// editor tooling. Properties are only tied to a method when they are read
+// from this or super, since anything else needs the type of the object.
+type Index struct {
+	Symbols    []*Symbol
+	Scopes     []*Scope
+	References []Reference
+	Globals    map[string]*Symbol
+	// Methods holds the methods of each class by class name.
+	Methods map[string][]*Symbol
+	pending []pendingReference
+}
+
+// pendingReference is a name that is looked up once the whole script is
+// resolved: a global, which may be declared after a function using it, or
+// a method of class.
+type pendingReference struct {
+	name  scanner.Token
+	class string
+}
+
+func NewIndex() *Index {
+	return &Index{
+		Symbols:    []*Symbol{},
+		Scopes:     []*Scope{},
+		References: []Reference{},
+		Globals:    map[string]*Symbol{},
+		Methods:    map[string][]*Symbol{},
+		pending:    []pendingReference{},
+	}
+}
+
+func (i *Index) declare(symbol *Symbol) {
+	i.Symbols = append(i.Symbols, symbol)
+	if symbol.Scope != nil {
+		symbol.Scope.Symbols = append(symbol.Scope.Symbols, symbol)
+		return
+	}
+
+	switch symbol.Kind {
+	case MethodSymbol:
+		i.Methods[symbol.Class] = append(i.Methods[symbol.Class], symbol)
+	default:
+		if _, ok := i.Globals[symbol.Name.Lexeme]; !ok {
+			i.Globals[symbol.Name.Lexeme] = symbol
+		}
+	}
+}
+
+func (i *Index) reference(name scanner.Token, symbol *Symbol) {
+	i.References = append(i.References, Reference{
+		Name:   name,
+		Symbol: symbol,
+	})
+}
+
+// resolvePending ties the references left for the end of resolution to
+// their globals and methods.
+func (i *Index) resolvePending() {
+	for _, pending := range i.pending {
+		var symbol *Symbol
+		if pending.class == "" {
+			symbol = i.Globals[pending.name.Lexeme]
+		} else {
+			symbol = i.Method(pending.class, pending.name.Lexeme)
+		}
+
+		if symbol != nil {
+			i.reference(pending.name, symbol)
+		}
+	}
+	i.pending = i.pending[:0]
+}
+
+// Method finds the method name of class, looking through its superclasses.
+func (i *Index) Method(class string, name string) *Symbol {
+	seen := map[string]bool{}
+	for class != "" && !seen[class] {
+		seen[class] = true
+		for _, method := range i.Methods[class] {
+			if method.Name.Lexeme == name {
+				return method
+			}
+		}
+
+		classSymbol, ok := i.Globals[class]
+		if !ok || classSymbol.Kind != ClassSymbol {
+			break
+		}
+		class = classSymbol.Superclass
+	}
+
+	return nil
+}
+
+// SymbolAt returns the symbol declared or referenced by the token at
+// offset.
+func (i *Index) SymbolAt(offset int) *Symbol {
+	for _, symbol := range i.Symbols {
+		if covers(symbol.Name, offset) {
+			return symbol
+		}
+	}
+	for _, reference := range i.References {
+		if covers(reference.Name, offset) {
+			return reference.Symbol
+		}
+	}
+
+	return nil
+}
+
+// ReferencesTo returns the uses of symbol in source order, not counting
+// its declaration.
+func (i *Index) ReferencesTo(symbol *Symbol) []scanner.Token {
+	tokens := []scanner.Token{}
+	for _, reference := range i.References {
+		if reference.Symbol == symbol {
+			tokens = append(tokens, reference.Name)
+		}
+	}
+
+	return tokens
+}
+
+// Visible returns the symbols that can be named at offset, innermost
+// first. Locals are only visible after their declaration, globals
+// everywhere.
+func (i *Index) Visible(offset int) []*Symbol {
+	visible := []*Symbol{}
+	seen := map[string]bool{}
+	add := func(symbol *Symbol) {
+		if seen[symbol.Name.Lexeme] {
+			return
+		}
+		seen[symbol.Name.Lexeme] = true
+		visible = append(visible, symbol)
+	}
+
+	var innermost *Scope
+	for _, scope := range i.Scopes {
+		if scope.Contains(offset) && (innermost == nil || scope.Start.Offset >= innermost.Start.Offset) {
+			innermost = scope
+		}
+	}
+	for scope := innermost; scope != nil; scope = scope.Parent {
+		for _, symbol := range scope.Symbols {
+			if symbol.Name.Offset < offset {
+				add(symbol)
+			}
+		}
+	}
+
+	for _, symbol := range i.Symbols {
+		if symbol.Scope == nil && symbol.Kind != MethodSymbol {
+			add(symbol)
+		}
+	}
+
+	return visible
+}
+
+// Extent returns the offset just after the declaration of symbol, the
+// end of the body of a function, method or class. SetExtents must have
+// been called.
+func (i *Index) Extent(symbol *Symbol) int {
+	end := endOf(symbol.Name)
+	for _, scope := range i.Scopes {
+		if scope.Start.Offset == symbol.Name.Offset && scope.End > end {
+			end = scope.End
+		}
+	}
+
+	return end
+}
+
+// SetExtents finds where each scope ends in tokens, the tokens the
+// indexed script was parsed from. A scope opened by a '{' ends at its
+// matching '}', a for loop ends with its body and a function or class
+// scope, opened at its name, ends with the block after it.
+func (i *Index) SetExtents(tokens []scanner.Token) {
+	for _, scope := range i.Scopes {
+		start := 0
+		for start < len(tokens) && tokens[start].Offset < scope.Start.Offset {
+			start++
+		}
+		scope.End = scopeEnd(tokens, start)
+	}
+}
+
+func scopeEnd(tokens []scanner.Token, start int) int {
+	if start >= len(tokens) {
+		return endOf(tokens[len(tokens)-1])
+	}
+
+	switch tokens[start].TokenType {
+	case scanner.LEFT_BRACE:
+		return endOf(tokens[matching(tokens, start)])
+	case scanner.FOR:
+		body := matching(tokens, start+1) + 1
+		if body < len(tokens) && tokens[body].TokenType == scanner.LEFT_BRACE {
+			return endOf(tokens[matching(tokens, body)])
+		}
+		return endOf(tokens[statementEnd(tokens, body)])
+	default:
+		for j := start; j < len(tokens); j++ {
+			if tokens[j].TokenType == scanner.LEFT_BRACE {
+				return endOf(tokens[matching(tokens, j)])
+			}
+		}
+		return endOf(tokens[len(tokens)-1])
+	}
+}
+
+// matching returns the index of the bracket closing the one at open, or
+// the last token when it is never closed.
+func matching(tokens []scanner.Token, open int) int {
+	depth := 0
+	for j := open; j < len(tokens); j++ {
+		switch tokens[j].TokenType {
+		case scanner.LEFT_BRACE, scanner.LEFT_PAREN, scanner.LEFT_BRACKET:
+			depth++
+		case scanner.RIGHT_BRACE, scanner.RIGHT_PAREN, scanner.RIGHT_BRACKET:
+			depth--
+			if depth == 0 {
+				return j
+			}
+		}
+	}
+
+	return len(tokens) - 1
+}
+
+// statementEnd returns the index of the ';' or '}' ending the statement
+// starting at start.
+func statementEnd(tokens []scanner.Token, start int) int {
+	for j := start; j < len(tokens); j++ {
+		switch tokens[j].TokenType {
+		case scanner.LEFT_BRACE, scanner.LEFT_PAREN, scanner.LEFT_BRACKET:
+			j = matching(tokens, j)
+			// an else can follow the block of an if
+			if tokens[j].TokenType == scanner.RIGHT_BRACE && tokens[start].TokenType != scanner.IF {
+				return j
+			}
+		case scanner.SEMICOLON:
+			return j
+		}
+	}
+
+	return len(tokens) - 1
+}
+
+func endOf(token scanner.Token) int {
+	return token.Offset + len(token.Lexeme)
+}
+
+func covers(token scanner.Token, offset int) bool {
+	return token.Offset <= offset && offset <= endOf(token)
+}
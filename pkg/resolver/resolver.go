@@ -1,11 +1,11 @@
 package resolver
 
 import (
-	"fmt"
-
+	"github.com/neet-007/glox/pkg/diagnostics"
 	"github.com/neet-007/glox/pkg/interpreter"
 	"github.com/neet-007/glox/pkg/parser"
 	"github.com/neet-007/glox/pkg/scanner"
+	"github.com/neet-007/glox/pkg/trace"
 )
 
 type FunctionType int
@@ -52,39 +52,36 @@ func (c ClassType) String() string {
 	}
 }
 
-type CompileError struct {
-	Token   scanner.Token
-	Message string
-}
-
-func (e *CompileError) Error() string {
-	return e.Message
-}
+type CompileError = diagnostics.Diagnostic
 
-func NewCompileError(token scanner.Token, message string) *CompileError {
-	return &CompileError{
-		Token:   token,
-		Message: message,
-	}
+func NewCompileError(token scanner.Token, code diagnostics.Code, message string) *CompileError {
+	return diagnostics.New(diagnostics.PhaseResolver, code, token.Span(), message)
 }
 
 type Resolver struct {
 	interpreter     *interpreter.Interpreter
-	scopes          []map[string]bool
+	scopes          []map[string]*local
 	errors          []*CompileError
 	currentFunction FunctionType
 	currentClass    ClassType
-	debug           bool
+	returns         returnKinds
+	index           *Index
+	indexScopes     []*Scope
+	className       string
+	superclassName  string
+	// Tracer, when set, is told where each variable was resolved to.
+	Tracer trace.Tracer
 }
 
-func NewResolver(interpreter *interpreter.Interpreter, debug bool) *Resolver {
+func NewResolver(interpreter *interpreter.Interpreter) *Resolver {
 	return &Resolver{
 		interpreter:     interpreter,
-		scopes:          []map[string]bool{},
+		scopes:          []map[string]*local{},
 		errors:          []*CompileError{},
 		currentFunction: NONE_FUNCTION,
 		currentClass:    NONE_CLASS,
-		debug:           debug,
+		index:           NewIndex(),
+		indexScopes:     []*Scope{},
 	}
 }
 
@@ -92,11 +89,18 @@ func (r *Resolver) Resolve(stmts []parser.Stmt) []*CompileError {
 	for _, stmt := range stmts {
 		r.resolveStmt(stmt)
 	}
+	r.index.resolvePending()
 
 	return r.errors
 }
 
+// Index returns the declarations and references found by Resolve.
+func (r *Resolver) Index() *Index {
+	return r.index
+}
+
 func (r *Resolver) resolveStmts(stmts []parser.Stmt) {
+	r.checkUnreachable(stmts)
 	for _, stmt := range stmts {
 		r.resolveStmt(stmt)
 	}
@@ -110,57 +114,88 @@ func (r *Resolver) resolveExpr(expr parser.Expr) (any, error) {
 	return expr.Accept(r)
 }
 
-func (r *Resolver) resolveLocal(expr parser.Expr, name scanner.Token) {
-	if r.debug {
-		fmt.Printf("resolve local name:%s\n", name.Lexeme)
-	}
+func (r *Resolver) resolveLocal(expr parser.Expr, name scanner.Token, read bool) {
 	for i := len(r.scopes) - 1; i >= 0; i-- {
-		if _, ok := r.scopes[i][name.Lexeme]; ok {
-			if r.debug {
-				fmt.Printf("resolve local name:%s found dist %d\n", name.Lexeme, len(r.scopes)-1-i)
+		if local, ok := r.scopes[i][name.Lexeme]; ok {
+			if read {
+				local.read = true
+			}
+			if local.symbol != nil {
+				r.index.reference(name, local.symbol)
 			}
-			r.interpreter.ResolveExpr(expr, len(r.scopes)-1-i)
+			r.interpreter.ResolveExpr(expr, len(r.scopes)-1-i, local.slot)
+			r.traceResolve(name, len(r.scopes)-1-i)
 			return
 		}
 	}
-	if r.debug {
-		fmt.Printf("resolve local name:%s not found\n", name.Lexeme)
+	r.traceResolve(name, -1)
+	r.index.pending = append(r.index.pending, pendingReference{
+		name: name,
+	})
+}
+
+func (r *Resolver) traceResolve(name scanner.Token, depth int) {
+	if r.Tracer == nil {
+		return
 	}
+
+	r.Tracer.Trace(trace.Event{
+		Kind:   trace.Resolve,
+		File:   name.File,
+		Line:   name.Line,
+		Column: name.Column,
+		Depth:  depth,
+		Name:   name.Lexeme,
+	})
 }
 
 func (r *Resolver) resolveFunction(stmt parser.Function, functionType FunctionType) {
-	if r.debug {
-		fmt.Printf("resolve function name: %s type:%s\n", stmt.Name.Lexeme, functionType)
-	}
 	enclosingFunction := r.currentFunction
+	enclosingReturns := r.returns
 	r.currentFunction = functionType
-	r.beginScope()
+	r.returns = returnKinds{}
+	r.beginScope(stmt.Name)
 
 	for _, param := range stmt.Parameters {
-		r.declare(param)
+		r.declare(param, ParameterSymbol)
 		r.define(param)
 	}
 
-	if r.debug {
-		fmt.Printf("resolve function name: %s type:%s finish\n", stmt.Name.Lexeme, functionType)
-	}
 	r.resolveStmts(stmt.Body)
 	r.endScope()
+	if functionType != INITIALIZER {
+		r.checkReturns(stmt)
+	}
 	r.currentFunction = enclosingFunction
+	r.returns = enclosingReturns
 }
 
-func (r *Resolver) declare(name scanner.Token) {
+func (r *Resolver) declare(name scanner.Token, kind SymbolKind) *Symbol {
+	symbol := &Symbol{
+		Name: name,
+		Kind: kind,
+	}
 	if len(r.scopes) == 0 {
-		return
+		r.index.declare(symbol)
+		return symbol
 	}
 
 	scope := r.scopes[len(r.scopes)-1]
 	if _, ok := scope[name.Lexeme]; ok {
-		r.error(NewCompileError(name, "Already a variable with this name in this scope"))
-		return
+		r.error(NewCompileError(name, diagnostics.AlreadyDeclared, "Already a variable with this name in this scope"))
+		return symbol
+	}
+	r.checkShadowing(name)
+
+	symbol.Scope = r.indexScopes[len(r.indexScopes)-1]
+	r.index.declare(symbol)
+	scope[name.Lexeme] = &local{
+		name:   name,
+		kind:   kind,
+		symbol: symbol,
+		slot:   len(scope),
 	}
-	scope[name.Lexeme] = false
-	return
+	return symbol
 }
 
 func (r *Resolver) define(name scanner.Token) {
@@ -168,15 +203,38 @@ func (r *Resolver) define(name scanner.Token) {
 		return
 	}
 
-	r.scopes[len(r.scopes)-1][name.Lexeme] = true
+	r.scopes[len(r.scopes)-1][name.Lexeme].defined = true
 }
 
-func (r *Resolver) beginScope() {
-	r.scopes = append(r.scopes, map[string]bool{})
+// defineSynthetic adds a name the interpreter binds itself, like "this".
+func (r *Resolver) defineSynthetic(name string) {
+	scope := r.scopes[len(r.scopes)-1]
+	scope[name] = &local{
+		synthetic: true,
+		defined:   true,
+		slot:      len(scope),
+	}
+}
+
+// beginScope opens a scope, start is the token it begins at in the
+// source.
+func (r *Resolver) beginScope(start scanner.Token) {
+	r.scopes = append(r.scopes, map[string]*local{})
+
+	scope := &Scope{
+		Start: start,
+	}
+	if len(r.indexScopes) > 0 {
+		scope.Parent = r.indexScopes[len(r.indexScopes)-1]
+	}
+	r.indexScopes = append(r.indexScopes, scope)
+	r.index.Scopes = append(r.index.Scopes, scope)
 }
 
 func (r *Resolver) endScope() {
+	r.checkUnused(r.scopes[len(r.scopes)-1])
 	r.scopes = r.scopes[:len(r.scopes)-1]
+	r.indexScopes = r.indexScopes[:len(r.indexScopes)-1]
 }
 
 func (r *Resolver) error(errros ...error) {
@@ -191,23 +249,27 @@ func (r *Resolver) error(errros ...error) {
 
 func (r *Resolver) VisitSuperExpr(expr parser.Super) (any, error) {
 	if r.currentClass == NONE_CLASS {
-		r.error(NewCompileError(expr.Keyword, "Can't use 'super' outside of a class"))
+		r.error(NewCompileError(expr.Keyword, diagnostics.SuperOutsideClass, "Can't use 'super' outside of a class"))
 		return nil, nil
 	} else if r.currentClass != SUBCLASS {
-		r.error(NewCompileError(expr.Keyword, "Can't use 'super' in a class with no superclass"))
+		r.error(NewCompileError(expr.Keyword, diagnostics.SuperWithoutSuperclass, "Can't use 'super' in a class with no superclass"))
 		return nil, nil
 	}
 
-	r.resolveLocal(expr, expr.Keyword)
+	r.resolveLocal(expr, expr.Keyword, true)
+	r.index.pending = append(r.index.pending, pendingReference{
+		name:  expr.Method,
+		class: r.superclassName,
+	})
 	return nil, nil
 }
 
 func (r *Resolver) VisitThisExpr(expr parser.This) (any, error) {
 	if r.currentClass == NONE_CLASS {
-		r.error(NewCompileError(expr.Keyword, "Can't use 'this' outside of a class"))
+		r.error(NewCompileError(expr.Keyword, diagnostics.ThisOutsideClass, "Can't use 'this' outside of a class"))
 		return nil, nil
 	}
-	r.resolveLocal(expr, expr.Keyword)
+	r.resolveLocal(expr, expr.Keyword, true)
 	return nil, nil
 }
 
@@ -220,6 +282,12 @@ func (r *Resolver) VisitSetExpr(expr parser.Set) (any, error) {
 
 func (r *Resolver) VisitGetExpr(expr parser.Get) (any, error) {
 	r.resolveExpr(expr.Object)
+	if _, ok := expr.Object.(parser.This); ok && r.className != "" {
+		r.index.pending = append(r.index.pending, pendingReference{
+			name:  expr.Name,
+			class: r.className,
+		})
+	}
 	return nil, nil
 }
 
@@ -234,19 +302,19 @@ func (r *Resolver) VisitCallExpr(expr parser.Call) (any, error) {
 
 func (r *Resolver) VisitVariableExpr(expr parser.Variable) (any, error) {
 	if len(r.scopes) > 0 {
-		if val, ok := r.scopes[len(r.scopes)-1][expr.Name.Lexeme]; ok && !val {
-			r.error(NewCompileError(expr.Name, "Can't read local variable in its own initializer"))
+		if local, ok := r.scopes[len(r.scopes)-1][expr.Name.Lexeme]; ok && !local.defined {
+			r.error(NewCompileError(expr.Name, diagnostics.ReadInOwnInitializer, "Can't read local variable in its own initializer"))
 			return nil, nil
 		}
 	}
 
-	r.resolveLocal(expr, expr.Name)
+	r.resolveLocal(expr, expr.Name, true)
 	return nil, nil
 }
 
 func (r *Resolver) VisitAssignExpr(expr parser.Assign) (any, error) {
 	r.resolveExpr(expr.Expr)
-	r.resolveLocal(expr, expr.Lexem)
+	r.resolveLocal(expr, expr.Lexem, false)
 
 	return nil, nil
 }
@@ -302,41 +370,46 @@ func (r *Resolver) VisitUnaryExpr(expr parser.Unary) (any, error) {
 }
 
 func (r *Resolver) VisitClassStmt(stmt parser.Class) (any, error) {
-	if r.debug {
-		fmt.Printf("resolver visit class name:%s\n", stmt.Name.Lexeme)
-	}
 	currentClass := r.currentClass
+	className, superclassName := r.className, r.superclassName
 	r.currentClass = CLASS
-	r.declare(stmt.Name)
+	r.className, r.superclassName = stmt.Name.Lexeme, stmt.SuperClass.Name.Lexeme
+	symbol := r.declare(stmt.Name, ClassSymbol)
+	symbol.Superclass = stmt.SuperClass.Name.Lexeme
 	r.define(stmt.Name)
 
 	var zeroVariabe parser.Variable
 	if stmt.SuperClass != zeroVariabe {
-		if r.debug {
-			fmt.Printf("resolver visit class name:%s has superclass\n", stmt.Name.Lexeme)
-		}
 		r.currentClass = SUBCLASS
-		if stmt.SuperClass.Name == stmt.Name {
-			r.error(NewCompileError(stmt.Name, "A class can't inherit from itself."))
+		if stmt.SuperClass.Name.Lexeme == stmt.Name.Lexeme {
+			r.error(NewCompileError(stmt.Name, diagnostics.InheritFromSelf, "A class can't inherit from itself."))
+			r.currentClass = currentClass
+			r.className, r.superclassName = className, superclassName
 			return nil, nil
 		}
 		r.resolveExpr(stmt.SuperClass)
 	}
 
 	if stmt.SuperClass != zeroVariabe {
-		r.beginScope()
-		r.scopes[len(r.scopes)-1]["super"] = true
+		r.beginScope(stmt.Name)
+		r.defineSynthetic("super")
 	}
 
-	r.beginScope()
-	r.scopes[len(r.scopes)-1]["this"] = true
+	r.beginScope(stmt.Name)
+	r.defineSynthetic("this")
+
+	for _, method := range stmt.Methods {
+		r.index.declare(&Symbol{
+			Name:       method.Name,
+			Kind:       MethodSymbol,
+			Parameters: method.Parameters,
+			Class:      stmt.Name.Lexeme,
+		})
+	}
 
 	for _, method := range stmt.Methods {
 		declaation := METHOD
 		if method.Name.Lexeme == "init" {
-			if r.debug {
-				fmt.Printf("resolver visit class name:%s has init method\n", stmt.Name.Lexeme)
-			}
 			declaation = INITIALIZER
 		}
 		r.resolveFunction(method, declaation)
@@ -349,53 +422,38 @@ func (r *Resolver) VisitClassStmt(stmt parser.Class) (any, error) {
 		r.endScope()
 	}
 
-	if r.debug {
-		fmt.Printf("resolver visit class name:%s finished\n", stmt.Name.Lexeme)
-	}
 	r.currentClass = currentClass
+	r.className, r.superclassName = className, superclassName
 	return nil, nil
 }
 
 func (r *Resolver) VisitReturnStmt(stmt parser.Return) (any, error) {
-	if r.debug {
-		fmt.Printf("resolver visit return\n")
-	}
 	if r.currentFunction == NONE_FUNCTION {
-		if r.debug {
-			fmt.Printf("resolver visit return not function\n")
-		}
-		r.error(NewCompileError(stmt.Keyword, "Can't return from top-level code."))
+		r.error(NewCompileError(stmt.Keyword, diagnostics.ReturnFromTopLevel, "Can't return from top-level code."))
 		return nil, nil
 	}
+	r.recordReturn(stmt)
 	if stmt.Value != nil {
-		if r.debug {
-			fmt.Printf("resolver visit return has value\n")
-		}
 		if r.currentFunction == INITIALIZER {
-			if r.debug {
-				fmt.Printf("resolver visit return has value but in init method\n")
-			}
-			r.error(NewCompileError(stmt.Keyword, "Can't return a value from an initializer."))
+			r.error(NewCompileError(stmt.Keyword, diagnostics.ReturnValueFromInit, "Can't return a value from an initializer."))
 			return nil, nil
 		}
 		r.resolveExpr(stmt.Value)
 	}
 
-	if r.debug {
-		fmt.Printf("resolver visit return finished\n")
-	}
 	return nil, nil
 }
 
 func (r *Resolver) VisitFunctionStmt(stmt parser.Function) (any, error) {
-	r.declare(stmt.Name)
+	symbol := r.declare(stmt.Name, FunctionSymbol)
+	symbol.Parameters = stmt.Parameters
 	r.define(stmt.Name)
 	r.resolveFunction(stmt, FUNCTION)
 	return nil, nil
 }
 
 func (r *Resolver) VisitVarDeclaration(stmt parser.VarDeclaration) (any, error) {
-	r.declare(stmt.Name)
+	r.declare(stmt.Name, VariableSymbol)
 	if stmt.Initizlier != nil {
 		r.resolveExpr(stmt.Initizlier)
 	}
@@ -410,7 +468,7 @@ func (r *Resolver) VisitWhileStmt(stmt parser.WhileStmt) (any, error) {
 }
 
 func (r *Resolver) VisitBlockStmt(stmt parser.Block) (any, error) {
-	r.beginScope()
+	r.beginScope(stmt.Brace)
 	r.resolveStmts(stmt.Statements)
 	r.endScope()
 	return nil, nil
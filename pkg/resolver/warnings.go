@@ -0,0 +1,168 @@
+package resolver
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/neet-007/glox/pkg/diagnostics"
+	"github.com/neet-007/glox/pkg/parser"
+	"github.com/neet-007/glox/pkg/scanner"
+)
+
+// local is a name declared in a block or function scope. read is set once
+// the name is used as a value, assigning to it does not count. Synthetic
+// locals are the ones the interpreter binds itself, like "this".
+type local struct {
+	name      scanner.Token
+	kind      SymbolKind
+	symbol    *Symbol
+	synthetic bool
+	defined   bool
+	read      bool
+	// slot is where the interpreter keeps the variable in its scope's
+	// environment, scopes numbering their variables in declaration order.
+	slot int
+}
+
+// returnKinds records the return statements seen in the function being
+// resolved.
+type returnKinds struct {
+	value scanner.Token
+	bare  scanner.Token
+}
+
+func NewCompileWarning(token scanner.Token, code diagnostics.Code, message string) *CompileError {
+	return diagnostics.NewWarning(diagnostics.PhaseResolver, code, token.Span(), message)
+}
+
+func (r *Resolver) warn(token scanner.Token, code diagnostics.Code, message string) *CompileError {
+	warning := NewCompileWarning(token, code, message)
+	r.errors = append(r.errors, warning)
+	return warning
+}
+
+// checkUnused warns about the variables and parameters of scope that were
+// never read. Names starting with "_" are taken to be unused on purpose.
+func (r *Resolver) checkUnused(scope map[string]*local) {
+	unused := []*local{}
+	for _, local := range scope {
+		if local.read || local.synthetic || strings.HasPrefix(local.name.Lexeme, "_") {
+			continue
+		}
+		if local.kind == VariableSymbol || local.kind == ParameterSymbol {
+			unused = append(unused, local)
+		}
+	}
+
+	// map order is random, report in source order
+	sort.Slice(unused, func(i, j int) bool {
+		return unused[i].name.Offset < unused[j].name.Offset
+	})
+
+	for _, local := range unused {
+		if local.kind == ParameterSymbol {
+			r.warn(local.name, diagnostics.UnusedParameter, fmt.Sprintf("Parameter '%s' is never used.", local.name.Lexeme)).
+				WithHint(fmt.Sprintf("rename it to '_%s' if this is on purpose", local.name.Lexeme))
+			continue
+		}
+		r.warn(local.name, diagnostics.UnusedVariable, fmt.Sprintf("Local variable '%s' is never used.", local.name.Lexeme))
+	}
+}
+
+// checkShadowing warns when name hides a local of an enclosing scope.
+// Globals are left alone since they can be redefined freely.
+func (r *Resolver) checkShadowing(name scanner.Token) {
+	for i := len(r.scopes) - 2; i >= 0; i-- {
+		shadowed, ok := r.scopes[i][name.Lexeme]
+		if !ok || shadowed.synthetic {
+			continue
+		}
+
+		r.warn(name, diagnostics.ShadowedVariable, fmt.Sprintf("'%s' shadows a variable of an enclosing scope.", name.Lexeme)).
+			WithNote(fmt.Sprintf("'%s' is declared on line %d", name.Lexeme, shadowed.name.Line))
+		return
+	}
+}
+
+// checkUnreachable warns about the first statement of stmts that comes
+// after one that always returns.
+func (r *Resolver) checkUnreachable(stmts []parser.Stmt) {
+	for i, stmt := range stmts[:max(len(stmts)-1, 0)] {
+		if !alwaysReturns(stmt) {
+			continue
+		}
+
+		note := fmt.Sprintf("the statement on line %d always returns", parser.StmtToken(stmt).Line)
+		if _, ok := stmt.(parser.WhileStmt); ok {
+			note = fmt.Sprintf("the loop on line %d only ends by returning", parser.StmtToken(stmt).Line)
+		}
+		r.warn(parser.StmtToken(stmts[i+1]), diagnostics.UnreachableCode, "Unreachable code.").
+			WithNote(note)
+		return
+	}
+}
+
+// checkReturns warns when stmt returns a value on some paths and nil on
+// others, either with a bare return or by running off the end of its body.
+func (r *Resolver) checkReturns(stmt parser.Function) {
+	if r.returns.value.Line == 0 {
+		return
+	}
+
+	if r.returns.bare.Line != 0 {
+		r.warn(stmt.Name, diagnostics.InconsistentReturn, fmt.Sprintf("Function '%s' returns a value on some paths and nil on others.", stmt.Name.Lexeme)).
+			WithNote(fmt.Sprintf("a value is returned on line %d", r.returns.value.Line)).
+			WithNote(fmt.Sprintf("nil is returned on line %d", r.returns.bare.Line))
+		return
+	}
+
+	if !alwaysReturnsAll(stmt.Body) {
+		r.warn(stmt.Name, diagnostics.InconsistentReturn, fmt.Sprintf("Function '%s' returns a value on some paths and nil on others.", stmt.Name.Lexeme)).
+			WithNote(fmt.Sprintf("a value is returned on line %d", r.returns.value.Line)).
+			WithNote("the end of the function can be reached, which returns nil")
+	}
+}
+
+func (r *Resolver) recordReturn(stmt parser.Return) {
+	if stmt.Value != nil {
+		if r.returns.value.Line == 0 {
+			r.returns.value = stmt.Keyword
+		}
+		return
+	}
+
+	if r.returns.bare.Line == 0 {
+		r.returns.bare = stmt.Keyword
+	}
+}
+
+// alwaysReturns reports whether stmt never lets the statements after it
+// run, as a return does and a loop whose condition is true.
+func alwaysReturns(stmt parser.Stmt) bool {
+	switch stmt := stmt.(type) {
+	case parser.Return:
+		return true
+	case parser.WhileStmt:
+		// lox has no break, so "while (true)" and "for (;;)" are only
+		// left by returning
+		literal, ok := stmt.Condition.(parser.Literal)
+		return ok && literal.Value == true
+	case parser.Block:
+		return alwaysReturnsAll(stmt.Statements)
+	case parser.IfStmt:
+		return stmt.ElseBranch != nil && alwaysReturns(stmt.ThenBranch) && alwaysReturns(stmt.ElseBranch)
+	default:
+		return false
+	}
+}
+
+func alwaysReturnsAll(stmts []parser.Stmt) bool {
+	for _, stmt := range stmts {
+		if alwaysReturns(stmt) {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,96 @@
+package resolver_test
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+
+	"github.com/neet-007/glox/pkg/diagnostics"
+	"github.com/neet-007/glox/pkg/interpreter"
+	"github.com/neet-007/glox/pkg/parser"
+	"github.com/neet-007/glox/pkg/resolver"
+	"github.com/neet-007/glox/pkg/scanner"
+)
+
+// warnings resolves source and returns its warnings as "code line", with
+// the ones turned off by a "// glox:ignore" comment left out.
+func warnings(t *testing.T, source string) []string {
+	t.Helper()
+	scanner_ := scanner.NewFileScanner("test.lox", []byte(source), false)
+	tokens, errs := scanner_.Scan()
+	if len(errs) > 0 {
+		t.Fatal(errs[0])
+	}
+	stmts, errs := parser.NewParser(tokens, false).Parse()
+	if len(errs) > 0 {
+		t.Fatal(errs[0])
+	}
+
+	suppressions := diagnostics.NewSuppressions()
+	for _, comment := range scanner_.Comments() {
+		suppressions.AddComment(comment.Line, comment.Text, comment.OwnLine)
+	}
+
+	found := []string{}
+	for _, diagnostic := range suppressions.Filter(resolver.NewResolver(interpreter.NewInterpreter()).Resolve(stmts)) {
+		if diagnostic.Severity == diagnostics.Error {
+			t.Fatalf("unexpected error: %v", diagnostic)
+		}
+		found = append(found, fmt.Sprintf("%s %d", diagnostic.Code, diagnostic.Span.Line))
+	}
+	return found
+}
+
+func TestWarnings(t *testing.T) {
+	unused := string(diagnostics.UnusedVariable)
+	parameter := string(diagnostics.UnusedParameter)
+	unreachable := string(diagnostics.UnreachableCode)
+	shadowed := string(diagnostics.ShadowedVariable)
+	inconsistent := string(diagnostics.InconsistentReturn)
+
+	tests := []struct {
+		name   string
+		source string
+		want   []string
+	}{
+		{"unused variable", "{\n  var a = 1;\n}", []string{unused + " 2"}},
+		{"assigned only", "{\n  var a;\n  a = 1;\n}", []string{unused + " 2"}},
+		{"used variable", "{\n  var a = 1;\n  print a;\n}", nil},
+		{"unused on purpose", "{\n  var _a = 1;\n}", nil},
+		{"unused global", "var a = 1;", nil},
+		{"unused parameter", "fun f(a, b) {\n  return a;\n}\nf(1, 2);", []string{parameter + " 1"}},
+		{"unused in source order", "{\n  var b = 1;\n  var a = 2;\n}", []string{unused + " 2", unused + " 3"}},
+		{"shadowing", "{\n  var a = 1;\n  {\n    var a = 2;\n    print a;\n  }\n  print a;\n}", []string{shadowed + " 4"}},
+		{"shadowing a parameter", "fun f(a) {\n  {\n    var a = 2;\n    print a;\n  }\n  return a;\n}\nf(1);", []string{shadowed + " 3"}},
+		{"shadowing a global", "var a = 1;\n{\n  var a = 2;\n  print a;\n}", nil},
+		{"after return", "fun f() {\n  return 1;\n  print 2;\n}\nf();", []string{unreachable + " 3"}},
+		{"after if returning", "fun f(x) {\n  if (x) return 1; else return 2;\n  print 3;\n}\nf(1);", []string{unreachable + " 3"}},
+		{"after if maybe returning", "fun f(x) {\n  if (x) return 1;\n  return 3;\n}\nf(1);", nil},
+		{"after endless loop", "fun f(x) {\n  while (true) {\n    if (x) return 1;\n  }\n  print 2;\n}\nf(1);", []string{unreachable + " 5"}},
+		{"value and bare return", "fun f(x) {\n  if (x) return 1;\n  return;\n}\nf(1);", []string{inconsistent + " 1"}},
+		{"value and falling off", "fun f(x) {\n  if (x) return 1;\n}\nf(1);", []string{inconsistent + " 1"}},
+		{"value on every path", "fun f(x) {\n  if (x) return 1;\n  return 2;\n}\nf(1);", nil},
+		{"while true returning", "fun f(x) {\n  while (true) {\n    if (x) return 1;\n  }\n}\nf(1);", nil},
+		{"for ever returning", "fun f(x) {\n  for (;;) {\n    if (x) return 1;\n  }\n}\nf(1);", nil},
+		{"while maybe returning", "fun f(x) {\n  while (x) {\n    return 1;\n  }\n}\nf(1);", []string{inconsistent + " 1"}},
+		{"initializer", "class A {\n  init(x) {\n    if (x) return;\n    this.x = x;\n  }\n}\nA(1);", nil},
+		{"ignore all", "{\n  var a = 1; // glox:ignore\n}", nil},
+		{"ignore by name", "{\n  var a = 1; // glox:ignore unused-variable\n}", nil},
+		{"ignore by code", "{\n  var a = 1; // glox:ignore W0301\n}", nil},
+		{"ignore the next line", "{\n  // glox:ignore unused-variable\n  var a = 1;\n}", nil},
+		{"ignore another rule", "{\n  var a = 1; // glox:ignore shadowing\n}", []string{unused + " 2"}},
+		{"ignore one of two", "fun f(a) {\n  {\n    var a = 1; // glox:ignore shadowing\n  }\n  return a;\n}\nf(1);", []string{unused + " 3"}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got := warnings(t, test.source)
+			if test.want == nil {
+				test.want = []string{}
+			}
+			if !reflect.DeepEqual(got, test.want) {
+				t.Errorf("got %v, want %v", got, test.want)
+			}
+		})
+	}
+}
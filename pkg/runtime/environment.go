@@ -1,35 +1,72 @@
 package runtime
 
 import (
+	"github.com/neet-007/glox/pkg/diagnostics"
 	"github.com/neet-007/glox/pkg/scanner"
 )
 
+// Environment holds the variables of a scope. Globals are kept by name,
+// as they can be defined after the code using them is resolved, while
+// locals sit in slots in the order they are defined, the order the resolver
+// numbered them in.
 type Environment struct {
 	Enclosing *Environment
 	values    map[string]any
+	slots     []slot
 }
 
+// slot is a local variable, named for lookups made without the resolver,
+// as the debugger's are.
+type slot struct {
+	name  string
+	value any
+}
+
+// NewGlobals returns the environment for global variables.
+func NewGlobals() *Environment {
+	return &Environment{
+		values: map[string]any{},
+	}
+}
+
+// NewEnvironment returns the environment for a local scope inside
+// enclosing.
 func NewEnvironment(enclosing *Environment) *Environment {
 	return &Environment{
 		Enclosing: enclosing,
-		values:    map[string]any{},
 	}
 }
 
+// lookup returns the slot of the local variable name, -1 when e holds no
+// such local.
+func (e *Environment) lookup(name string) int {
+	for i, slot := range e.slots {
+		if slot.name == name {
+			return i
+		}
+	}
+	return -1
+}
+
+// Get looks name up by name through e and its enclosing environments.
 func (e *Environment) Get(name scanner.Token) (any, *RuntimeError) {
-	val, ok := e.values[name.Lexeme]
-	if !ok {
-		if e.Enclosing != nil {
-			return e.Enclosing.Get(name)
+	if e.values != nil {
+		if val, ok := e.values[name.Lexeme]; ok {
+			return val, nil
 		}
-		return nil, NewRuntimeError(name, "undefiend variable "+name.Lexeme)
+	} else if slot := e.lookup(name.Lexeme); slot >= 0 {
+		return e.slots[slot].value, nil
 	}
 
-	return val, nil
+	if e.Enclosing != nil {
+		return e.Enclosing.Get(name)
+	}
+	return nil, NewRuntimeError(name, diagnostics.UndefinedVariable, "undefiend variable "+name.Lexeme)
 }
 
-func (e *Environment) GetAt(dist int, name string) (any, *RuntimeError) {
-	return e.ancestor(dist).values[name], nil
+// GetAt returns the local in slot of the environment dist scopes out.
+func (e *Environment) GetAt(dist int, slot int) any {
+	return e.ancestor(dist).slots[slot].value
 }
 
 func (e *Environment) ancestor(dist int) *Environment {
@@ -42,9 +79,15 @@ func (e *Environment) ancestor(dist int) *Environment {
 	return environment
 }
 
+// Assign sets name by name through e and its enclosing environments.
 func (e *Environment) Assign(name scanner.Token, value any) *RuntimeError {
-	if _, ok := e.values[name.Lexeme]; ok {
-		e.values[name.Lexeme] = value
+	if e.values != nil {
+		if _, ok := e.values[name.Lexeme]; ok {
+			e.values[name.Lexeme] = value
+			return nil
+		}
+	} else if slot := e.lookup(name.Lexeme); slot >= 0 {
+		e.slots[slot].value = value
 		return nil
 	}
 
@@ -52,13 +95,34 @@ func (e *Environment) Assign(name scanner.Token, value any) *RuntimeError {
 		return e.Enclosing.Assign(name, value)
 	}
 
-	return NewRuntimeError(name, "undefiend variable "+name.Lexeme)
+	return NewRuntimeError(name, diagnostics.UndefinedVariable, "undefiend variable "+name.Lexeme)
+}
+
+// AssignAt sets the local in slot of the environment dist scopes out.
+func (e *Environment) AssignAt(dist int, slot int, value any) {
+	e.ancestor(dist).slots[slot].value = value
 }
 
-func (e *Environment) AssignAt(dist int, name scanner.Token, value any) {
-	e.ancestor(dist).values[name.Lexeme] = value
+// Values returns the variables defined in e itself, not its enclosing
+// environments. The map must not be modified.
+func (e *Environment) Values() map[string]any {
+	if e.values != nil {
+		return e.values
+	}
+
+	values := make(map[string]any, len(e.slots))
+	for _, slot := range e.slots {
+		values[slot.name] = slot.value
+	}
+	return values
 }
 
+// Define adds a variable to e, a local taking the next slot.
 func (e *Environment) Define(name string, value any) {
-	e.values[name] = value
+	if e.values != nil {
+		e.values[name] = value
+		return
+	}
+
+	e.slots = append(e.slots, slot{name: name, value: value})
 }
@@ -0,0 +1,70 @@
+package runtime
+
+import (
+	"fmt"
+	"testing"
+)
+
+// mapEnvironment keeps its variables the way Environment did before locals
+// had slots, every scope a map from name to value.
+type mapEnvironment struct {
+	enclosing *mapEnvironment
+	values    map[string]any
+}
+
+func (e *mapEnvironment) ancestor(dist int) *mapEnvironment {
+	environment := e
+	for range dist {
+		environment = environment.enclosing
+	}
+	return environment
+}
+
+func (e *mapEnvironment) getAt(dist int, name string) any {
+	return e.ancestor(dist).values[name]
+}
+
+func (e *mapEnvironment) assignAt(dist int, name string, value any) {
+	e.ancestor(dist).values[name] = value
+}
+
+// scopeNames are the locals of every scope in BenchmarkLocals, the one read
+// and written being the third.
+var scopeNames = []string{"i", "total", "row", "column"}
+
+// BenchmarkLocals reads and writes a local depth scopes out, by the name
+// hashed into each scope's map as before and by the resolver's depth and
+// slot as now.
+func BenchmarkLocals(b *testing.B) {
+	for _, depth := range []int{0, 1, 4, 16} {
+		b.Run(fmt.Sprintf("map/depth=%d", depth), func(b *testing.B) {
+			var environment *mapEnvironment
+			for range depth + 1 {
+				environment = &mapEnvironment{enclosing: environment, values: map[string]any{}}
+				for _, name := range scopeNames {
+					environment.values[name] = 0.0
+				}
+			}
+			b.ReportAllocs()
+			for range b.N {
+				value := environment.getAt(depth, "row")
+				environment.assignAt(depth, "row", value.(float64)+1)
+			}
+		})
+
+		b.Run(fmt.Sprintf("slots/depth=%d", depth), func(b *testing.B) {
+			environment := NewGlobals()
+			for range depth + 1 {
+				environment = NewEnvironment(environment)
+				for _, name := range scopeNames {
+					environment.Define(name, 0.0)
+				}
+			}
+			b.ReportAllocs()
+			for range b.N {
+				value := environment.GetAt(depth, 2)
+				environment.AssignAt(depth, 2, value.(float64)+1)
+			}
+		})
+	}
+}
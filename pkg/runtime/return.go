@@ -1,5 +1,7 @@
 package runtime
 
+import "github.com/neet-007/glox/pkg/scanner"
+
 type Return struct {
 	Value any
 }
@@ -13,3 +15,24 @@ func NewReturn(value any) *Return {
 func (r *Return) Error() string {
 	return "return statemnt"
 }
+
+// TailCall is returned in place of a Return by "return f(...)", leaving
+// the call to be made by whoever called the function returning, once
+// that function is done with, so calls in tail position do not nest.
+type TailCall struct {
+	Callee    any
+	Arguments []any
+	CallSite  scanner.Token
+}
+
+func NewTailCall(callee any, arguments []any, callSite scanner.Token) *TailCall {
+	return &TailCall{
+		Callee:    callee,
+		Arguments: arguments,
+		CallSite:  callSite,
+	}
+}
+
+func (t *TailCall) Error() string {
+	return "tail call"
+}
@@ -3,21 +3,90 @@ package runtime
 import (
 	"fmt"
 
+	"github.com/neet-007/glox/pkg/diagnostics"
 	"github.com/neet-007/glox/pkg/scanner"
 )
 
 type RuntimeError struct {
 	Token   scanner.Token
+	Code    diagnostics.Code
 	Message string
+	Err     error
+	// Trace is the lox call stack when the error happened, innermost call
+	// first and the top level script last.
+	Trace []StackFrame
+	// Notes explain the error further, shown after the trace.
+	Notes []string
 }
 
-func NewRuntimeError(token scanner.Token, message string) *RuntimeError {
+type StackFrame struct {
+	Function string
+	Line     int
+}
+
+func (s StackFrame) String() string {
+	if s.Function == "" {
+		return fmt.Sprintf("at main line %d", s.Line)
+	}
+	return fmt.Sprintf("at %s() line %d", s.Function, s.Line)
+}
+
+func NewRuntimeError(token scanner.Token, code diagnostics.Code, message string) *RuntimeError {
+	return &RuntimeError{
+		Token:   token,
+		Code:    code,
+		Message: message,
+	}
+}
+
+// WrapRuntimeError reports err at token, keeping err so callers can match
+// it with errors.Is and errors.As.
+func WrapRuntimeError(token scanner.Token, code diagnostics.Code, message string, err error) *RuntimeError {
 	return &RuntimeError{
 		Token:   token,
+		Code:    code,
 		Message: message,
+		Err:     err,
 	}
 }
 
+// maxTraceFrames is how many frames of the trace are kept from each end of
+// the stack when converting to a diagnostic, deep recursion would otherwise
+// produce thousands of notes. Every way of reporting a runtime error goes
+// through Diagnostic, so this is the only place traces are trimmed.
+const maxTraceFrames = 10
+
+// Diagnostic converts the error to the form every phase reports, the trace
+// becomes its notes.
+func (r *RuntimeError) Diagnostic() *diagnostics.Diagnostic {
+	diagnostic := diagnostics.New(diagnostics.PhaseRuntime, r.Code, r.Token.Span(), r.Message)
+	if len(r.Trace) <= 1 {
+		for _, note := range r.Notes {
+			diagnostic.WithNote(note)
+		}
+		return diagnostic
+	}
+
+	for j, frame := range r.Trace {
+		if j == maxTraceFrames && len(r.Trace) > 2*maxTraceFrames {
+			diagnostic.WithNote(fmt.Sprintf("... %d more frames", len(r.Trace)-2*maxTraceFrames))
+		}
+		if j >= maxTraceFrames && j < len(r.Trace)-maxTraceFrames {
+			continue
+		}
+		diagnostic.WithNote(frame.String())
+	}
+	for _, note := range r.Notes {
+		diagnostic.WithNote(note)
+	}
+
+	return diagnostic
+}
+
 func (r *RuntimeError) Error() string {
-	return fmt.Sprintf("%v %v\n", r.Token, r.Message)
+	return r.Diagnostic().Error()
+}
+
+func (r *RuntimeError) Unwrap() error {
+	return r.Err
 }
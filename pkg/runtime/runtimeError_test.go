@@ -0,0 +1,81 @@
+package runtime
+
+import (
+	"fmt"
+	"testing"
+)
+
+func trace(frames int) []StackFrame {
+	trace := make([]StackFrame, frames)
+	for j := range trace {
+		trace[j] = StackFrame{Function: fmt.Sprintf("f%d", j), Line: j + 1}
+	}
+	return trace
+}
+
+func TestDiagnosticTrace(t *testing.T) {
+	tests := []struct {
+		frames int
+		// kept are the frames shown, -1 where the rest are left out.
+		kept []int
+	}{
+		{frames: 1, kept: nil},
+		{frames: 2, kept: span(0, 2)},
+		{frames: 2 * maxTraceFrames, kept: span(0, 2*maxTraceFrames)},
+		{frames: 2*maxTraceFrames + 1, kept: append(append(span(0, maxTraceFrames), -1), span(maxTraceFrames+1, 2*maxTraceFrames+1)...)},
+		{frames: 10000, kept: append(append(span(0, maxTraceFrames), -1), span(10000-maxTraceFrames, 10000)...)},
+	}
+
+	for _, test := range tests {
+		t.Run(fmt.Sprint(test.frames), func(t *testing.T) {
+			frames := trace(test.frames)
+			want := []string{}
+			for _, j := range test.kept {
+				if j < 0 {
+					want = append(want, fmt.Sprintf("... %d more frames", test.frames-2*maxTraceFrames))
+					continue
+				}
+				want = append(want, frames[j].String())
+			}
+
+			err := &RuntimeError{Message: "boom", Trace: frames}
+			notes := err.Diagnostic().Notes
+			if len(notes) != len(want) {
+				t.Fatalf("got %d notes, want %d", len(notes), len(want))
+			}
+			for j := range want {
+				if notes[j] != want[j] {
+					t.Errorf("note %d is %q, want %q", j, notes[j], want[j])
+				}
+			}
+		})
+	}
+}
+
+// span returns the numbers from start up to end.
+func span(start int, end int) []int {
+	numbers := []int{}
+	for j := start; j < end; j++ {
+		numbers = append(numbers, j)
+	}
+	return numbers
+}
+
+func TestStackFrameString(t *testing.T) {
+	if got := (StackFrame{Function: "f", Line: 3}).String(); got != "at f() line 3" {
+		t.Errorf("got %q", got)
+	}
+	if got := (StackFrame{Line: 7}).String(); got != "at main line 7" {
+		t.Errorf("got %q", got)
+	}
+}
+
+func TestDiagnosticNotes(t *testing.T) {
+	for _, frames := range []int{1, 3, 10000} {
+		err := &RuntimeError{Message: "boom", Trace: trace(frames), Notes: []string{"why"}}
+		notes := err.Diagnostic().Notes
+		if len(notes) == 0 || notes[len(notes)-1] != "why" {
+			t.Errorf("with %d frames got notes %q, want the note after the trace", frames, notes)
+		}
+	}
+}
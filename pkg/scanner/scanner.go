@@ -1,38 +1,56 @@
 package scanner
 
 import (
-	"fmt"
+	"bytes"
 	"strconv"
+
+	"github.com/neet-007/glox/pkg/diagnostics"
 )
 
-type ScannerError struct {
-	Token   Token
-	Message string
-}
+type ScannerError = diagnostics.Diagnostic
 
-func newScannerError(token Token, message string) *ScannerError {
-	return &ScannerError{
-		Token:   token,
-		Message: message,
-	}
+func newScannerError(token Token, code diagnostics.Code, message string) *ScannerError {
+	return diagnostics.New(diagnostics.PhaseScanner, code, token.Span(), message)
 }
 
-func (err *ScannerError) Error() string {
-	return fmt.Sprintf("parse error at %d with message %s", err.Token.Line, err.Message)
+// Comment is a "//" comment, OwnLine when nothing but whitespace comes
+// before it on its line.
+type Comment struct {
+	Line            int
+	Text            string
+	OwnLine         bool
+	BlankLineBefore bool
 }
 
 type Scanner struct {
-	keywords map[string]TokenType
-	tokens   []Token
-	source   []byte
-	start    int
-	current  int
-	length   int
-	line     int
+	keywords  map[string]TokenType
+	tokens    []Token
+	comments  []Comment
+	trivia    *Trivia
+	source    []byte
+	file      string
+	start     int
+	current   int
+	length    int
+	line      int
+	lineStart int
+	// startLine and startColumn are where the current token begins, a
+	// string literal may end lines later.
+	startLine   int
+	startColumn int
+	// lastLine is the line the last token or comment ended on, to find
+	// blank lines between them.
+	lastLine int
 	debug    bool
 }
 
 func NewScanner(source []byte, debug bool) *Scanner {
+	return NewFileScanner("", source, debug)
+}
+
+// NewFileScanner is NewScanner for source read from file, the name is
+// recorded on every token for error reporting.
+func NewFileScanner(file string, source []byte, debug bool) *Scanner {
 	return &Scanner{
 		keywords: map[string]TokenType{
 			"and":    AND,
@@ -53,6 +71,7 @@ func NewScanner(source []byte, debug bool) *Scanner {
 			"while":  WHILE,
 		},
 		source: source,
+		file:   file,
 		line:   1,
 		length: len(source),
 		debug:  debug,
@@ -62,17 +81,23 @@ func NewScanner(source []byte, debug bool) *Scanner {
 func (s *Scanner) Scan() ([]Token, []*ScannerError) {
 	errors := []*ScannerError{}
 	for !s.isAtEnd() {
-		s.start = s.current
+		s.markStart()
 		scannerErr := s.scanToken()
 		if scannerErr != nil {
 			errors = append(errors, scannerErr)
 		}
 	}
 
+	s.markStart()
 	s.addToken(EOF, nil)
 	return s.tokens, errors
 }
 
+// Comments returns the comments seen by Scan in source order.
+func (s *Scanner) Comments() []Comment {
+	return s.comments
+}
+
 func (s *Scanner) scanToken() *ScannerError {
 	c := s.advance()
 
@@ -147,6 +172,12 @@ func (s *Scanner) scanToken() *ScannerError {
 				for !s.isAtEnd() && s.peek() != '\n' {
 					s.advance()
 				}
+				s.addComment(Comment{
+					Line:            s.line,
+					Text:            string(bytes.TrimRight(s.source[s.start:s.current], " \t\r")),
+					OwnLine:         len(bytes.TrimSpace(s.source[s.lineStart:s.start])) == 0,
+					BlankLineBefore: s.blankLineBefore(),
+				})
 				break
 			}
 			s.addToken(SLASH, nil)
@@ -203,7 +234,7 @@ func (s *Scanner) scanToken() *ScannerError {
 		}
 	case '\n':
 		{
-			s.line++
+			s.newLine()
 			break
 		}
 	default:
@@ -217,7 +248,7 @@ func (s *Scanner) scanToken() *ScannerError {
 				break
 			}
 
-			return newScannerError(Token{TokenType: Error, Lexeme: string(c), Line: s.line, Literal: nil}, "unknown charecter")
+			return newScannerError(s.errorToken(), diagnostics.UnexpectedCharacter, "unknown charecter")
 		}
 	}
 
@@ -225,12 +256,58 @@ func (s *Scanner) scanToken() *ScannerError {
 }
 
 func (s *Scanner) addToken(tokenType TokenType, literal any) {
-	s.tokens = append(s.tokens, Token{
+	token := s.makeToken(tokenType, literal)
+	if s.blankLineBefore() {
+		if s.trivia == nil {
+			s.trivia = &Trivia{}
+		}
+		s.trivia.BlankLineBefore = true
+	}
+	token.Trivia = s.trivia
+
+	s.trivia = nil
+	s.lastLine = s.line
+	s.tokens = append(s.tokens, token)
+}
+
+func (s *Scanner) addComment(comment Comment) {
+	if s.trivia == nil {
+		s.trivia = &Trivia{}
+	}
+	s.trivia.Comments = append(s.trivia.Comments, comment)
+	s.comments = append(s.comments, comment)
+	s.lastLine = s.line
+}
+
+func (s *Scanner) blankLineBefore() bool {
+	return s.lastLine > 0 && s.startLine-s.lastLine > 1
+}
+
+func (s *Scanner) makeToken(tokenType TokenType, literal any) Token {
+	return Token{
 		TokenType: tokenType,
 		Literal:   literal,
-		Line:      s.line,
+		Line:      s.startLine,
+		Column:    s.startColumn,
+		Offset:    s.start,
+		File:      s.file,
 		Lexeme:    string(s.source[s.start:s.current]),
-	})
+	}
+}
+
+func (s *Scanner) errorToken() Token {
+	return s.makeToken(Error, nil)
+}
+
+func (s *Scanner) markStart() {
+	s.start = s.current
+	s.startLine = s.line
+	s.startColumn = s.start - s.lineStart + 1
+}
+
+func (s *Scanner) newLine() {
+	s.line++
+	s.lineStart = s.current
 }
 
 func (s *Scanner) identifier() {
@@ -249,14 +326,14 @@ func (s *Scanner) identifier() {
 
 func (s *Scanner) stringLiteral() *ScannerError {
 	for !s.isAtEnd() && s.peek() != '"' {
-		if s.peek() == '\n' {
-			s.line++
-		}
 		s.advance()
+		if s.previousByte() == '\n' {
+			s.newLine()
+		}
 	}
 
 	if s.isAtEnd() {
-		return newScannerError(Token{TokenType: Error, Lexeme: string(s.source[s.start:s.current]), Line: s.line, Literal: nil}, "unterminated string")
+		return newScannerError(s.errorToken(), diagnostics.UnterminatedString, "unterminated string")
 	}
 
 	s.advance()
@@ -280,7 +357,7 @@ func (s *Scanner) number() *ScannerError {
 	num, err := strconv.ParseFloat(string(s.source[s.start:s.current]), 64)
 
 	if err != nil {
-		return newScannerError(Token{TokenType: Error, Lexeme: string(s.source[s.start:s.current]), Line: s.line, Literal: nil}, "invalid number")
+		return newScannerError(s.errorToken(), diagnostics.InvalidNumber, "invalid number")
 	}
 
 	s.addToken(NUMBER, num)
@@ -302,6 +379,10 @@ func (s *Scanner) advance() byte {
 	return returnVal
 }
 
+func (s *Scanner) previousByte() byte {
+	return s.source[s.current-1]
+}
+
 func (s *Scanner) isAtEnd() bool {
 	return s.current >= s.length
 }
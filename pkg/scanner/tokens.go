@@ -1,6 +1,10 @@
 package scanner
 
-import "fmt"
+import (
+	"fmt"
+
+	"github.com/neet-007/glox/pkg/diagnostics"
+)
 
 type TokenType int
 
@@ -100,10 +104,38 @@ type Token struct {
 	TokenType TokenType
 	Lexeme    string
 	Line      int
-	Literal   any
+	// Column is the 1 based byte column of the first character and Offset
+	// its byte offset in the source.
+	Column  int
+	Offset  int
+	File    string
+	Literal any
+	// Trivia is what came between the previous token and this one, nil
+	// when that was only spaces and single line breaks. It is a pointer so
+	// tokens stay comparable.
+	Trivia *Trivia
+}
+
+// Trivia is the source text the parser does not need but a formatter must
+// keep.
+type Trivia struct {
+	Comments []Comment
+	// BlankLineBefore is set when an empty line comes directly before the
+	// token, after any comments.
+	BlankLineBefore bool
+}
+
+func (t Token) Span() diagnostics.Span {
+	return diagnostics.Span{
+		File:   t.File,
+		Line:   t.Line,
+		Column: t.Column,
+		Offset: t.Offset,
+		Text:   t.Lexeme,
+	}
 }
 
 func (t Token) String() string {
-	return fmt.Sprintf("TokenType: %s, Lexeme: %s, Line: %d, Literal: %v",
-		TokenNames[t.TokenType], t.Lexeme, t.Line, t.Literal)
+	return fmt.Sprintf("TokenType: %s, Lexeme: %s, Line: %d, Column: %d, Literal: %v",
+		TokenNames[t.TokenType], t.Lexeme, t.Line, t.Column, t.Literal)
 }
@@ -0,0 +1,109 @@
+package testrunner
+
+import (
+	"fmt"
+	"strconv"
+
+	"github.com/neet-007/glox/pkg/diagnostics"
+	"github.com/neet-007/glox/pkg/interpreter"
+	"github.com/neet-007/glox/pkg/runtime"
+	"github.com/neet-007/glox/pkg/scanner"
+)
+
+// defineNatives adds the assertions tests use to interpreter's globals.
+func defineNatives(interpreter_ *interpreter.Interpreter) {
+	globals := interpreter_.Globals()
+	globals.Define("assert", assertNative{})
+	globals.Define("assertEqual", assertEqualNative{})
+	globals.Define("assertThrows", assertThrowsNative{})
+}
+
+// failed is an assertion failure. It has no token, the interpreter points
+// it at the call of the assertion.
+func failed(message string) *runtime.RuntimeError {
+	return runtime.NewRuntimeError(scanner.Token{}, diagnostics.AssertionFailed, message)
+}
+
+// show formats a value for a failure message, strings quoted.
+func show(value any) string {
+	if str, ok := value.(string); ok {
+		return strconv.Quote(str)
+	}
+	return interpreter.Stringify(value)
+}
+
+// assert(condition) fails unless condition is truthy.
+type assertNative struct{}
+
+func (assertNative) Arity() int {
+	return 1
+}
+
+func (assertNative) Call(interpreter_ *interpreter.Interpreter, arguments []any) (any, error) {
+	if !interpreter.Truthy(arguments[0]) {
+		return nil, failed(fmt.Sprintf("assertion failed: got %s", show(arguments[0])))
+	}
+	return nil, nil
+}
+
+func (assertNative) Name() string {
+	return "assert"
+}
+
+func (assertNative) String() string {
+	return "<fn native>"
+}
+
+// assertEqual(expected, actual) fails unless the two are equal.
+type assertEqualNative struct{}
+
+func (assertEqualNative) Arity() int {
+	return 2
+}
+
+func (assertEqualNative) Call(interpreter_ *interpreter.Interpreter, arguments []any) (any, error) {
+	if !interpreter.Equal(arguments[0], arguments[1]) {
+		return nil, failed(fmt.Sprintf("expected %s, got %s", show(arguments[0]), show(arguments[1])))
+	}
+	return nil, nil
+}
+
+func (assertEqualNative) Name() string {
+	return "assertEqual"
+}
+
+func (assertEqualNative) String() string {
+	return "<fn native>"
+}
+
+// assertThrows(function) calls function and fails unless it raises a
+// runtime error. It returns the error's message.
+type assertThrowsNative struct{}
+
+func (assertThrowsNative) Arity() int {
+	return 1
+}
+
+func (assertThrowsNative) Call(interpreter_ *interpreter.Interpreter, arguments []any) (any, error) {
+	if _, ok := arguments[0].(interpreter.Callable); !ok {
+		return nil, failed(fmt.Sprintf("assertThrows expects a function, got %s", show(arguments[0])))
+	}
+
+	_, err := interpreter_.Call(arguments[0], nil)
+	if err == nil {
+		return nil, failed("expected an error, got none")
+	}
+	if err.Code == diagnostics.AssertionFailed || err.Code == diagnostics.ExecutionCancelled {
+		// a failing assertion or a timeout is not the error expected
+		return nil, err
+	}
+	return err.Message, nil
+}
+
+func (assertThrowsNative) Name() string {
+	return "assertThrows"
+}
+
+func (assertThrowsNative) String() string {
+	return "<fn native>"
+}
@@ -0,0 +1,226 @@
+package testrunner
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/neet-007/glox/pkg/diagnostics"
+)
+
+// WriteText writes the report the way go test does: failures with where
+// they happened and what the test printed, a line per file and a summary.
+// verbose also lists the tests that passed.
+func WriteText(w io.Writer, report Report, verbose bool) error {
+	for _, file := range report.Files {
+		for _, diagnostic := range file.Errors {
+			fmt.Fprint(w, diagnostics.Pretty(diagnostic, file.Source))
+		}
+
+		for _, test := range file.Tests {
+			if test.Passed() {
+				if verbose {
+					fmt.Fprintf(w, "--- PASS: %s (%s)\n", test.Name, seconds(test.Duration))
+					writeIndented(w, test.Output)
+				}
+				continue
+			}
+
+			fmt.Fprintf(w, "--- FAIL: %s (%s)\n", test.Name, seconds(test.Duration))
+			fmt.Fprintf(w, "    %s\n", failureLine(test.Failure))
+			for _, note := range test.Failure.Notes {
+				fmt.Fprintf(w, "        %s\n", note)
+			}
+			writeIndented(w, test.Output)
+		}
+
+		status := "ok"
+		if file.Failed() {
+			status = "FAIL"
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\n", status, file.File, seconds(file.Duration))
+	}
+
+	passed, failed := report.Counts()
+	status := "PASS"
+	if report.Failed() {
+		status = "FAIL"
+	}
+	_, err := fmt.Fprintf(w, "%s: %d passed, %d failed in %s\n", status, passed, failed, seconds(report.Duration))
+	return err
+}
+
+// failureLine is where a test failed and why, as file:line:col: message.
+func failureLine(failure *diagnostics.Diagnostic) string {
+	location := fmt.Sprintf("%d:%d", failure.Span.Line, failure.Span.Column)
+	if failure.Span.File != "" {
+		location = failure.Span.File + ":" + location
+	}
+	return location + ": " + failure.Message
+}
+
+func writeIndented(w io.Writer, output string) {
+	if output == "" {
+		return
+	}
+	for _, line := range strings.Split(strings.TrimSuffix(output, "\n"), "\n") {
+		fmt.Fprintf(w, "        %s\n", line)
+	}
+}
+
+func seconds(duration time.Duration) string {
+	return fmt.Sprintf("%.3fs", duration.Seconds())
+}
+
+type jsonReport struct {
+	Passed   int        `json:"passed"`
+	Failed   int        `json:"failed"`
+	Duration float64    `json:"duration"`
+	Files    []jsonFile `json:"files"`
+}
+
+type jsonFile struct {
+	File     string                    `json:"file"`
+	Duration float64                   `json:"duration"`
+	Errors   []*diagnostics.Diagnostic `json:"errors,omitempty"`
+	Tests    []jsonTest                `json:"tests"`
+}
+
+type jsonTest struct {
+	Name     string                  `json:"name"`
+	Passed   bool                    `json:"passed"`
+	Duration float64                 `json:"duration"`
+	Failure  *diagnostics.Diagnostic `json:"failure,omitempty"`
+	Output   string                  `json:"output,omitempty"`
+}
+
+// WriteJSON writes the report as one JSON object, durations in seconds.
+func WriteJSON(w io.Writer, report Report) error {
+	out := jsonReport{Duration: report.Duration.Seconds(), Files: []jsonFile{}}
+	out.Passed, out.Failed = report.Counts()
+
+	for _, file := range report.Files {
+		outFile := jsonFile{
+			File:     file.File,
+			Duration: file.Duration.Seconds(),
+			Errors:   file.Errors,
+			Tests:    []jsonTest{},
+		}
+		for _, test := range file.Tests {
+			outFile.Tests = append(outFile.Tests, jsonTest{
+				Name:     test.Name,
+				Passed:   test.Passed(),
+				Duration: test.Duration.Seconds(),
+				Failure:  test.Failure,
+				Output:   test.Output,
+			})
+		}
+		out.Files = append(out.Files, outFile)
+	}
+
+	encoded, err := json.MarshalIndent(out, "", "  ")
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(w, "%s\n", encoded)
+	return err
+}
+
+type junitSuites struct {
+	XMLName  xml.Name     `xml:"testsuites"`
+	Tests    int          `xml:"tests,attr"`
+	Failures int          `xml:"failures,attr"`
+	Errors   int          `xml:"errors,attr"`
+	Time     string       `xml:"time,attr"`
+	Suites   []junitSuite `xml:"testsuite"`
+}
+
+type junitSuite struct {
+	Name     string      `xml:"name,attr"`
+	Tests    int         `xml:"tests,attr"`
+	Failures int         `xml:"failures,attr"`
+	Errors   int         `xml:"errors,attr"`
+	Time     string      `xml:"time,attr"`
+	Cases    []junitCase `xml:"testcase"`
+}
+
+type junitCase struct {
+	Name      string        `xml:"name,attr"`
+	ClassName string        `xml:"classname,attr"`
+	Time      string        `xml:"time,attr"`
+	Failure   *junitMessage `xml:"failure,omitempty"`
+	Error     *junitMessage `xml:"error,omitempty"`
+	SystemOut string        `xml:"system-out,omitempty"`
+}
+
+type junitMessage struct {
+	Message string `xml:"message,attr"`
+	Type    string `xml:"type,attr"`
+	Text    string `xml:",chardata"`
+}
+
+// WriteJUnit writes the report as JUnit XML for CI servers, a test suite
+// per file. A file that did not compile is a test case with an error.
+func WriteJUnit(w io.Writer, report Report) error {
+	out := junitSuites{Time: junitTime(report.Duration)}
+
+	for _, file := range report.Files {
+		suite := junitSuite{Name: file.File, Time: junitTime(file.Duration)}
+
+		for _, diagnostic := range file.Errors {
+			suite.Errors++
+			suite.Cases = append(suite.Cases, junitCase{
+				Name:      "compile",
+				ClassName: file.File,
+				Time:      junitTime(0),
+				Error: &junitMessage{
+					Message: diagnostic.Message,
+					Type:    string(diagnostic.Code),
+					Text:    diagnostic.Error(),
+				},
+			})
+		}
+
+		for _, test := range file.Tests {
+			testCase := junitCase{
+				Name:      test.Name,
+				ClassName: file.File,
+				Time:      junitTime(test.Duration),
+				SystemOut: test.Output,
+			}
+			if !test.Passed() {
+				suite.Failures++
+				testCase.Failure = &junitMessage{
+					Message: test.Failure.Message,
+					Type:    string(test.Failure.Code),
+					Text:    strings.Join(append([]string{failureLine(test.Failure)}, test.Failure.Notes...), "\n"),
+				}
+			}
+			suite.Cases = append(suite.Cases, testCase)
+		}
+
+		suite.Tests = len(suite.Cases)
+		out.Tests += suite.Tests
+		out.Failures += suite.Failures
+		out.Errors += suite.Errors
+		out.Suites = append(out.Suites, suite)
+	}
+
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+	encoder := xml.NewEncoder(w)
+	encoder.Indent("", "  ")
+	if err := encoder.Encode(out); err != nil {
+		return err
+	}
+	_, err := io.WriteString(w, "\n")
+	return err
+}
+
+func junitTime(duration time.Duration) string {
+	return fmt.Sprintf("%.3f", duration.Seconds())
+}
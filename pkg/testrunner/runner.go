@@ -0,0 +1,222 @@
+package testrunner
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/neet-007/glox/pkg/diagnostics"
+	"github.com/neet-007/glox/pkg/interpreter"
+	"github.com/neet-007/glox/pkg/parser"
+	"github.com/neet-007/glox/pkg/resolver"
+	"github.com/neet-007/glox/pkg/scanner"
+	"github.com/neet-007/glox/pkg/utils"
+)
+
+// TestFileSuffix ends the name of every file with tests in it.
+const TestFileSuffix = "_test.lox"
+
+// Result is how one test function went.
+type Result struct {
+	Name     string
+	Duration time.Duration
+	// Failure is why the test failed, nil when it passed.
+	Failure *diagnostics.Diagnostic
+	// Output is what the test printed.
+	Output string
+}
+
+func (r Result) Passed() bool {
+	return r.Failure == nil
+}
+
+// FileResult is how the tests of one file went. Errors are the compile
+// errors that kept them from running.
+type FileResult struct {
+	File     string
+	Source   []byte
+	Errors   []*diagnostics.Diagnostic
+	Tests    []Result
+	Duration time.Duration
+}
+
+func (f FileResult) Failed() bool {
+	if len(f.Errors) > 0 {
+		return true
+	}
+	for _, test := range f.Tests {
+		if !test.Passed() {
+			return true
+		}
+	}
+	return false
+}
+
+// Report is how every file went.
+type Report struct {
+	Files    []FileResult
+	Duration time.Duration
+}
+
+func (r Report) Failed() bool {
+	for _, file := range r.Files {
+		if file.Failed() {
+			return true
+		}
+	}
+	return false
+}
+
+// Counts returns the number of tests that passed and failed, a file that
+// did not compile counting as one failure.
+func (r Report) Counts() (int, int) {
+	passed, failed := 0, 0
+	for _, file := range r.Files {
+		if len(file.Errors) > 0 {
+			failed++
+		}
+		for _, test := range file.Tests {
+			if test.Passed() {
+				passed++
+			} else {
+				failed++
+			}
+		}
+	}
+	return passed, failed
+}
+
+// Discover expands paths into the test files to run. Directories are
+// walked for files ending in TestFileSuffix, files named directly are run
+// whatever they are called.
+func Discover(paths []string) ([]string, error) {
+	if len(paths) == 0 {
+		paths = []string{"."}
+	}
+
+	files := []string{}
+	for _, path := range paths {
+		info, err := os.Stat(path)
+		if err != nil {
+			return nil, err
+		}
+		if !info.IsDir() {
+			files = append(files, path)
+			continue
+		}
+
+		found, err := utils.LoxFiles([]string{path})
+		if err != nil {
+			return nil, err
+		}
+		for _, file := range found {
+			if strings.HasSuffix(filepath.Base(file), TestFileSuffix) {
+				files = append(files, file)
+			}
+		}
+	}
+
+	return files, nil
+}
+
+// Runner runs the tests of lox files. A test is a top level function with
+// a name starting with "test" and no parameters; each runs in a fresh
+// interpreter after the top level statements of its file.
+type Runner struct {
+	// Match, when set, only runs the tests whose names it matches.
+	Match *regexp.Regexp
+}
+
+func (r *Runner) Run(files []string) Report {
+	start := time.Now()
+
+	report := Report{}
+	for _, file := range files {
+		report.Files = append(report.Files, r.RunFile(file))
+	}
+
+	report.Duration = time.Since(start)
+	return report
+}
+
+func (r *Runner) RunFile(file string) (result FileResult) {
+	start := time.Now()
+	result.File = file
+	defer func() {
+		result.Duration = time.Since(start)
+	}()
+
+	source, err := os.ReadFile(file)
+	if err != nil {
+		result.Errors = append(result.Errors, diagnostics.New(diagnostics.PhaseRuntime, "", diagnostics.Span{File: file}, err.Error()))
+		return result
+	}
+	result.Source = source
+
+	tokens, scannerErrors := scanner.NewFileScanner(file, source, false).Scan()
+	stmts, parserErrors := parser.NewParser(tokens, false).Parse()
+	result.Errors = append(scannerErrors, parserErrors...)
+	if len(result.Errors) > 0 {
+		return result
+	}
+
+	for _, diagnostic := range resolver.NewResolver(interpreter.NewInterpreter()).Resolve(stmts) {
+		if diagnostic.Severity == diagnostics.Error {
+			result.Errors = append(result.Errors, diagnostic)
+		}
+	}
+	if len(result.Errors) > 0 {
+		return result
+	}
+
+	for _, stmt := range stmts {
+		function, ok := stmt.(parser.Function)
+		if !ok || !strings.HasPrefix(function.Name.Lexeme, "test") {
+			continue
+		}
+		if r.Match != nil && !r.Match.MatchString(function.Name.Lexeme) {
+			continue
+		}
+
+		result.Tests = append(result.Tests, r.runTest(function, stmts))
+	}
+
+	return result
+}
+
+func (r *Runner) runTest(function parser.Function, stmts []parser.Stmt) (result Result) {
+	start := time.Now()
+	result.Name = function.Name.Lexeme
+	defer func() {
+		result.Duration = time.Since(start)
+	}()
+
+	if len(function.Parameters) > 0 {
+		result.Failure = diagnostics.New(diagnostics.PhaseRuntime, diagnostics.ArityMismatch, function.Name.Span(), "test functions take no parameters")
+		return result
+	}
+
+	interpreter_ := interpreter.NewInterpreter()
+	var output bytes.Buffer
+	interpreter_.Stdout = &output
+	defineNatives(interpreter_)
+	resolver.NewResolver(interpreter_).Resolve(stmts)
+
+	if err := interpreter_.Interpret(stmts); err != nil {
+		result.Failure = err.Diagnostic()
+	} else {
+		test, err := interpreter_.Globals().Get(function.Name)
+		if err == nil {
+			_, err = interpreter_.Call(test, nil)
+		}
+		if err != nil {
+			result.Failure = err.Diagnostic()
+		}
+	}
+
+	result.Output = output.String()
+	return result
+}
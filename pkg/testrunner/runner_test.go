@@ -0,0 +1,220 @@
+package testrunner
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"regexp"
+	"testing"
+
+	"github.com/neet-007/glox/pkg/diagnostics"
+)
+
+// write writes source to a file named name in a temporary directory and
+// returns its path.
+func write(t *testing.T, name string, source string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, []byte(source), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestRunFile(t *testing.T) {
+	file := write(t, "math_test.lox", `fun add(a, b) {
+  return a + b;
+}
+
+fun testPass() {
+  print "adding";
+  assertEqual(3, add(1, 2));
+  assert(true);
+}
+
+fun testEqual() {
+  assertEqual(4, add(1, 2));
+}
+
+fun testAssert() {
+  assert(nil);
+}
+
+fun testStrings() {
+  assertEqual("a", "b");
+}
+
+fun bad() {
+  return 1 - nil;
+}
+
+fun fine() {
+  return 1;
+}
+
+fun testThrows() {
+  var message = assertThrows(bad);
+  assertEqual("Expect operands to be numbers", message);
+}
+
+fun testNoThrow() {
+  assertThrows(fine);
+}
+
+fun testRuntimeError() {
+  print "before";
+  nil();
+  print "after";
+}
+
+fun testParameters(a) {
+}
+
+fun helper() {
+  assert(false);
+}
+`)
+
+	tests := []struct {
+		name    string
+		message string
+		line    int
+		output  string
+	}{
+		{"testPass", "", 0, "adding\n"},
+		{"testEqual", "expected 4, got 3", 12, ""},
+		{"testAssert", "assertion failed: got nil", 16, ""},
+		{"testStrings", `expected "a", got "b"`, 20, ""},
+		{"testThrows", "", 0, ""},
+		{"testNoThrow", "expected an error, got none", 37, ""},
+		{"testRuntimeError", "not callable", 42, "before\n"},
+		{"testParameters", "test functions take no parameters", 46, ""},
+	}
+
+	result := (&Runner{}).RunFile(file)
+	if len(result.Errors) > 0 {
+		t.Fatalf("unexpected errors: %v", result.Errors)
+	}
+	if len(result.Tests) != len(tests) {
+		t.Fatalf("ran %d tests, want %d", len(result.Tests), len(tests))
+	}
+	for j, test := range tests {
+		got := result.Tests[j]
+		t.Run(test.name, func(t *testing.T) {
+			if got.Name != test.name {
+				t.Fatalf("got test %s, want %s", got.Name, test.name)
+			}
+			if got.Output != test.output {
+				t.Errorf("printed %q, want %q", got.Output, test.output)
+			}
+			if test.message == "" {
+				if !got.Passed() {
+					t.Errorf("failed: %v", got.Failure)
+				}
+				return
+			}
+			if got.Passed() {
+				t.Fatalf("passed, want %q", test.message)
+			}
+			if got.Failure.Message != test.message || got.Failure.Span.Line != test.line {
+				t.Errorf("failed with %q on line %d, want %q on line %d", got.Failure.Message, got.Failure.Span.Line, test.message, test.line)
+			}
+		})
+	}
+
+	if !result.Failed() {
+		t.Error("the file did not fail")
+	}
+	report := Report{Files: []FileResult{result}}
+	if passed, failed := report.Counts(); passed != 2 || failed != 6 {
+		t.Errorf("got %d passed and %d failed, want 2 and 6", passed, failed)
+	}
+}
+
+func TestRunFileAssertionCodes(t *testing.T) {
+	file := write(t, "codes_test.lox", `fun testFails() {
+  assert(false);
+}
+`)
+	result := (&Runner{}).RunFile(file)
+	if len(result.Tests) != 1 || result.Tests[0].Passed() {
+		t.Fatalf("got %+v, want one failed test", result.Tests)
+	}
+	if code := result.Tests[0].Failure.Code; code != diagnostics.AssertionFailed {
+		t.Errorf("got code %s, want %s", code, diagnostics.AssertionFailed)
+	}
+}
+
+func TestRunFilePasses(t *testing.T) {
+	file := write(t, "pass_test.lox", `var count = 0;
+fun testOne() {
+  count = count + 1;
+  assertEqual(1, count);
+}
+fun testTwo() {
+  // every test starts from the top level statements again
+  count = count + 1;
+  assertEqual(1, count);
+}
+`)
+	result := (&Runner{}).RunFile(file)
+	if result.Failed() || len(result.Tests) != 2 {
+		t.Errorf("got %+v, want two passing tests", result)
+	}
+}
+
+func TestRunFileCompileError(t *testing.T) {
+	file := write(t, "broken_test.lox", `fun testBroken() {
+  var = 1;
+}
+`)
+	result := (&Runner{}).RunFile(file)
+	if len(result.Errors) == 0 || len(result.Tests) != 0 {
+		t.Fatalf("got %+v, want a compile error and no tests", result)
+	}
+	report := Report{Files: []FileResult{result}}
+	if passed, failed := report.Counts(); passed != 0 || failed != 1 || !report.Failed() {
+		t.Errorf("got %d passed and %d failed, want the file to count as one failure", passed, failed)
+	}
+}
+
+func TestRunMatch(t *testing.T) {
+	file := write(t, "match_test.lox", `fun testA() {}
+fun testB() {}
+fun testAB() {}
+`)
+	result := (&Runner{Match: regexp.MustCompile("A")}).RunFile(file)
+	names := []string{}
+	for _, test := range result.Tests {
+		names = append(names, test.Name)
+	}
+	if want := []string{"testA", "testAB"}; !reflect.DeepEqual(names, want) {
+		t.Errorf("ran %v, want %v", names, want)
+	}
+}
+
+func TestDiscover(t *testing.T) {
+	dir := t.TempDir()
+	for _, name := range []string{"a_test.lox", "b.lox", filepath.Join("sub", "c_test.lox")} {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, nil, 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	files, err := Discover([]string{dir, filepath.Join(dir, "b.lox")})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{filepath.Join(dir, "a_test.lox"), filepath.Join(dir, "sub", "c_test.lox"), filepath.Join(dir, "b.lox")}
+	if !reflect.DeepEqual(files, want) {
+		t.Errorf("got %v, want %v", files, want)
+	}
+
+	if _, err := Discover([]string{filepath.Join(dir, "missing")}); err == nil {
+		t.Error("found tests in a missing directory")
+	}
+}
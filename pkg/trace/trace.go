@@ -0,0 +1,142 @@
+package trace
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Kind is the type of a trace event.
+type Kind int
+
+const (
+	// Statement is a statement about to run, Name is its kind.
+	Statement Kind = iota
+	// Call is a call starting, Name is the function called.
+	Call
+	// Return is a call finishing, Value is what it returned.
+	Return
+	// Define is a variable, function or class declared, Value its value.
+	Define
+	// Assign is a variable assigned, Value is the new value.
+	Assign
+	// Error is a runtime error ending the script, Message says what.
+	Error
+	// Resolve is the resolver binding a variable, Depth is the number of
+	// scopes out it was found, -1 for a global.
+	Resolve
+	// Branch is a branch taken at an if, a while, an and or an or, Name
+	// being the keyword. Value is 0 for the then branch, another time round
+	// the loop or the right operand evaluated and 1 for the else branch,
+	// leaving the loop or a short circuit.
+	Branch
+)
+
+var kindNames = [...]string{
+	Statement: "statement",
+	Call:      "call",
+	Return:    "return",
+	Define:    "define",
+	Assign:    "assign",
+	Error:     "error",
+	Resolve:   "resolve",
+	Branch:    "branch",
+}
+
+func (k Kind) String() string {
+	if k < 0 || int(k) >= len(kindNames) {
+		return fmt.Sprintf("Kind(%d)", int(k))
+	}
+	return kindNames[k]
+}
+
+// Kinds returns every event kind.
+func Kinds() []Kind {
+	kinds := []Kind{}
+	for kind := range kindNames {
+		kinds = append(kinds, Kind(kind))
+	}
+	return kinds
+}
+
+// ParseKinds parses a comma separated list of kind names like
+// "call,return".
+func ParseKinds(list string) ([]Kind, error) {
+	kinds := []Kind{}
+	for _, field := range strings.Split(list, ",") {
+		field = strings.TrimSpace(field)
+		if field == "" {
+			continue
+		}
+
+		found := false
+		for kind, name := range kindNames {
+			if name == field {
+				kinds = append(kinds, Kind(kind))
+				found = true
+				break
+			}
+		}
+		if !found {
+			return nil, fmt.Errorf("unknown trace event %s, expect one of %s", field, strings.Join(kindNames[:], ", "))
+		}
+	}
+
+	return kinds, nil
+}
+
+// Event is something that happened while a script was resolved or run.
+type Event struct {
+	Kind   Kind
+	File   string
+	Line   int
+	Column int
+	// Depth is the call depth the event happened at, or the scope distance
+	// for Resolve.
+	Depth   int
+	Name    string
+	Value   any
+	Message string
+}
+
+// Tracer is told about events as they happen. It is called on the
+// goroutine running the script and should not keep it waiting long.
+type Tracer interface {
+	Trace(event Event)
+}
+
+// Filter passes on to tracer only the events of the given kinds.
+func Filter(tracer Tracer, kinds ...Kind) Tracer {
+	filter := &filter{
+		tracer: tracer,
+	}
+	for _, kind := range kinds {
+		if kind >= 0 && int(kind) < len(filter.kinds) {
+			filter.kinds[kind] = true
+		}
+	}
+	return filter
+}
+
+type filter struct {
+	tracer Tracer
+	kinds  [len(kindNames)]bool
+}
+
+func (f *filter) Trace(event Event) {
+	if event.Kind >= 0 && int(event.Kind) < len(f.kinds) && f.kinds[event.Kind] {
+		f.tracer.Trace(event)
+	}
+}
+
+// Multi sends every event to each of tracers in turn.
+func Multi(tracers ...Tracer) Tracer {
+	return multi(tracers)
+}
+
+type multi []Tracer
+
+func (m multi) Trace(event Event) {
+	for _, tracer := range m {
+		tracer.Trace(event)
+	}
+}
@@ -0,0 +1,108 @@
+package trace
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// Text writes events as lines of text, indented by call depth:
+//
+//	test.lox:7:12 call add
+//	  test.lox:2:3 statement var
+//	  test.lox:2:7 define s = 3
+func Text(w io.Writer) Tracer {
+	return &text{w: w}
+}
+
+type text struct {
+	w io.Writer
+}
+
+func (t *text) Trace(event Event) {
+	var b strings.Builder
+
+	if event.Kind != Resolve {
+		b.WriteString(strings.Repeat("  ", max(event.Depth, 0)))
+	}
+	fmt.Fprintf(&b, "%s %s", location(event), event.Kind)
+	if event.Name != "" {
+		b.WriteString(" " + event.Name)
+	}
+
+	switch event.Kind {
+	case Define, Assign:
+		b.WriteString(" = " + format(event.Value))
+	case Return:
+		b.WriteString(" -> " + format(event.Value))
+	case Branch:
+		fmt.Fprintf(&b, " %v", event.Value)
+	case Resolve:
+		if event.Depth < 0 {
+			b.WriteString(" global")
+		} else {
+			fmt.Fprintf(&b, " depth %d", event.Depth)
+		}
+	}
+	if event.Message != "" {
+		b.WriteString(": " + event.Message)
+	}
+
+	b.WriteString("\n")
+	io.WriteString(t.w, b.String())
+}
+
+// JSON writes events as JSON objects, one per line.
+func JSON(w io.Writer) Tracer {
+	return &jsonLines{encoder: json.NewEncoder(w)}
+}
+
+type jsonLines struct {
+	encoder *json.Encoder
+}
+
+type jsonEvent struct {
+	Event   string  `json:"event"`
+	File    string  `json:"file,omitempty"`
+	Line    int     `json:"line"`
+	Column  int     `json:"column"`
+	Depth   int     `json:"depth"`
+	Name    string  `json:"name,omitempty"`
+	Value   *string `json:"value,omitempty"`
+	Message string  `json:"message,omitempty"`
+}
+
+func (j *jsonLines) Trace(event Event) {
+	encoded := jsonEvent{
+		Event:   event.Kind.String(),
+		File:    event.File,
+		Line:    event.Line,
+		Column:  event.Column,
+		Depth:   event.Depth,
+		Name:    event.Name,
+		Message: event.Message,
+	}
+	switch event.Kind {
+	case Define, Assign, Return, Branch:
+		value := format(event.Value)
+		encoded.Value = &value
+	}
+
+	j.encoder.Encode(encoded)
+}
+
+func location(event Event) string {
+	if event.File == "" {
+		return fmt.Sprintf("%d:%d", event.Line, event.Column)
+	}
+	return fmt.Sprintf("%s:%d:%d", event.File, event.Line, event.Column)
+}
+
+// format shows a value the way print does.
+func format(value any) string {
+	if value == nil {
+		return "nil"
+	}
+	return fmt.Sprintf("%v", value)
+}
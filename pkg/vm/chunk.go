@@ -0,0 +1,85 @@
+package vm
+
+import "github.com/neet-007/glox/pkg/scanner"
+
+type OpCode byte
+
+// Operands follow their opcode as big endian uint16s, constants, slots,
+// jump offsets and argument counts alike.
+const (
+	OpConstant OpCode = iota
+	OpNil
+	OpTrue
+	OpFalse
+	OpPop
+	OpGetLocal
+	OpSetLocal
+	OpGetGlobal
+	OpDefineGlobal
+	OpSetGlobal
+	OpGetUpvalue
+	OpSetUpvalue
+	OpGetProperty
+	OpSetProperty
+	OpGetSuper
+	OpEqual
+	OpGreater
+	OpGreaterEqual
+	OpLess
+	OpLessEqual
+	OpAdd
+	OpSubtract
+	OpMultiply
+	OpDivide
+	OpNot
+	OpNegate
+	OpPrint
+	OpJump
+	OpJumpIfFalse
+	OpLoop
+	OpCall
+	// OpTailCall is OpCall for a call in tail position, the callee taking
+	// over the frame of the function returning its result. The OpReturn
+	// after it only runs with tail calls turned off.
+	OpTailCall
+	// OpClosure is followed by its function's constant and, for every
+	// upvalue, a byte set when it captures a local of the enclosing
+	// function and the index of that local or upvalue.
+	OpClosure
+	OpCloseUpvalue
+	OpReturn
+	OpClass
+	OpInherit
+	OpMethod
+	OpList
+	OpIndexGet
+	OpIndexSet
+)
+
+// Chunk is the bytecode of a function.
+type Chunk struct {
+	Code      []byte
+	Constants []any
+	// positions holds, for every byte of Code, the index in tokens of the
+	// token it was compiled from, for the errors raised running it.
+	positions []int32
+	tokens    []scanner.Token
+}
+
+func (c *Chunk) write(b byte, token int32) {
+	c.Code = append(c.Code, b)
+	c.positions = append(c.positions, token)
+}
+
+// Token is the token the instruction at offset was compiled from.
+func (c *Chunk) Token(offset int) scanner.Token {
+	return c.tokens[c.positions[offset]]
+}
+
+func (c *Chunk) addToken(token scanner.Token) int32 {
+	if n := len(c.tokens); n > 0 && c.tokens[n-1] == token {
+		return int32(n - 1)
+	}
+	c.tokens = append(c.tokens, token)
+	return int32(len(c.tokens) - 1)
+}
@@ -0,0 +1,641 @@
+package vm
+
+import (
+	"math"
+
+	"github.com/neet-007/glox/pkg/diagnostics"
+	"github.com/neet-007/glox/pkg/parser"
+	"github.com/neet-007/glox/pkg/scanner"
+)
+
+type functionKind int
+
+const (
+	kindScript functionKind = iota
+	kindFunction
+	kindMethod
+	kindInitializer
+)
+
+type local struct {
+	name     string
+	depth    int
+	captured bool
+}
+
+type upvalueRef struct {
+	index   uint16
+	isLocal bool
+}
+
+// functionCompiler is the state of a function being compiled. Functions
+// nest, enclosing is the one this one is declared in.
+type functionCompiler struct {
+	enclosing *functionCompiler
+	function  *Function
+	kind      functionKind
+	locals    []local
+	upvalues  []upvalueRef
+	depth     int
+	// names are the constants holding variable and property names.
+	names map[string]uint16
+}
+
+type classCompiler struct {
+	enclosing     *classCompiler
+	hasSuperclass bool
+}
+
+// Compiler turns a resolved program into bytecode. Scoping mistakes are
+// the resolver's to report, the compiler only reports what does not fit
+// in the bytecode.
+type Compiler struct {
+	current *functionCompiler
+	class   *classCompiler
+	// position is the statement being compiled, the instructions that
+	// have no token of their own point at it.
+	position scanner.Token
+	errors   []*diagnostics.Diagnostic
+}
+
+// Compile compiles stmts into the function the VM runs as the script.
+// They must have been resolved without errors.
+func Compile(stmts []parser.Stmt) (*Function, []*diagnostics.Diagnostic) {
+	c := &Compiler{errors: []*diagnostics.Diagnostic{}}
+	c.begin(kindScript, "")
+
+	for _, stmt := range stmts {
+		c.statement(stmt)
+	}
+
+	return c.end(), c.errors
+}
+
+func (c *Compiler) error(token scanner.Token, code diagnostics.Code, message string) {
+	c.errors = append(c.errors, diagnostics.New(diagnostics.PhaseCompiler, code, token.Span(), message))
+}
+
+func (c *Compiler) begin(kind functionKind, name string) {
+	c.current = &functionCompiler{
+		enclosing: c.current,
+		function:  &Function{Name: name},
+		kind:      kind,
+		names:     map[string]uint16{},
+	}
+
+	// slot zero holds the function called, or the instance in methods
+	slot := ""
+	if kind == kindMethod || kind == kindInitializer {
+		slot = "this"
+	}
+	c.current.locals = append(c.current.locals, local{name: slot})
+}
+
+func (c *Compiler) end() *Function {
+	c.emitReturn()
+	function := c.current.function
+	function.UpvalueCount = len(c.current.upvalues)
+	c.current = c.current.enclosing
+	return function
+}
+
+func (c *Compiler) chunk() *Chunk {
+	return &c.current.function.Chunk
+}
+
+// emitAt writes op and its operands, token being where errors raised by
+// the instruction point.
+func (c *Compiler) emitAt(token scanner.Token, op OpCode, operands ...uint16) {
+	chunk := c.chunk()
+	position := chunk.addToken(token)
+	chunk.write(byte(op), position)
+	for _, operand := range operands {
+		chunk.write(byte(operand>>8), position)
+		chunk.write(byte(operand), position)
+	}
+}
+
+func (c *Compiler) emit(op OpCode, operands ...uint16) {
+	c.emitAt(c.position, op, operands...)
+}
+
+func (c *Compiler) emitReturn() {
+	if c.current.kind == kindInitializer {
+		c.emit(OpGetLocal, 0)
+	} else {
+		c.emit(OpNil)
+	}
+	c.emit(OpReturn)
+}
+
+func (c *Compiler) constant(value any) uint16 {
+	chunk := c.chunk()
+	if len(chunk.Constants) > math.MaxUint16 {
+		c.error(c.position, diagnostics.TooManyConstants, "Too many constants in one function")
+		return 0
+	}
+	chunk.Constants = append(chunk.Constants, value)
+	return uint16(len(chunk.Constants) - 1)
+}
+
+// name is the constant holding a variable or property name, shared by
+// every use of it in the function.
+func (c *Compiler) name(name string) uint16 {
+	if index, ok := c.current.names[name]; ok {
+		return index
+	}
+	index := c.constant(name)
+	c.current.names[name] = index
+	return index
+}
+
+// emitJump writes a jump to be patched once its target is known and
+// returns where its offset is.
+func (c *Compiler) emitJump(token scanner.Token, op OpCode) int {
+	c.emitAt(token, op, math.MaxUint16)
+	return len(c.chunk().Code) - 2
+}
+
+func (c *Compiler) patchJump(at int) {
+	jump := len(c.chunk().Code) - at - 2
+	if jump > math.MaxUint16 {
+		c.error(c.position, diagnostics.JumpTooLarge, "Too much code to jump over")
+	}
+	c.chunk().Code[at] = byte(jump >> 8)
+	c.chunk().Code[at+1] = byte(jump)
+}
+
+func (c *Compiler) emitLoop(token scanner.Token, start int) {
+	jump := len(c.chunk().Code) - start + 3
+	if jump > math.MaxUint16 {
+		c.error(token, diagnostics.JumpTooLarge, "Loop body too large")
+	}
+	c.emitAt(token, OpLoop, uint16(jump))
+}
+
+func (c *Compiler) beginScope() {
+	c.current.depth++
+}
+
+func (c *Compiler) endScope() {
+	c.current.depth--
+
+	locals := c.current.locals
+	for len(locals) > 0 && locals[len(locals)-1].depth > c.current.depth {
+		if locals[len(locals)-1].captured {
+			c.emit(OpCloseUpvalue)
+		} else {
+			c.emit(OpPop)
+		}
+		locals = locals[:len(locals)-1]
+	}
+	c.current.locals = locals
+}
+
+// addLocal names the value on top of the stack.
+func (c *Compiler) addLocal(name scanner.Token) {
+	if len(c.current.locals) > math.MaxUint16 {
+		c.error(name, diagnostics.TooManyLocals, "Too many local variables in function")
+		return
+	}
+	c.current.locals = append(c.current.locals, local{name: name.Lexeme, depth: c.current.depth})
+}
+
+// define binds the value on top of the stack to name, a global at the top
+// level and a local anywhere else.
+func (c *Compiler) define(name scanner.Token) {
+	if c.current.depth > 0 {
+		c.addLocal(name)
+		return
+	}
+	c.emitAt(name, OpDefineGlobal, c.name(name.Lexeme))
+}
+
+func resolveLocal(compiler *functionCompiler, name string) int {
+	for j := len(compiler.locals) - 1; j >= 0; j-- {
+		if compiler.locals[j].name == name {
+			return j
+		}
+	}
+	return -1
+}
+
+func (c *Compiler) resolveUpvalue(compiler *functionCompiler, name scanner.Token) int {
+	if compiler.enclosing == nil {
+		return -1
+	}
+
+	if slot := resolveLocal(compiler.enclosing, name.Lexeme); slot != -1 {
+		compiler.enclosing.locals[slot].captured = true
+		return c.addUpvalue(compiler, name, uint16(slot), true)
+	}
+
+	if index := c.resolveUpvalue(compiler.enclosing, name); index != -1 {
+		return c.addUpvalue(compiler, name, uint16(index), false)
+	}
+
+	return -1
+}
+
+func (c *Compiler) addUpvalue(compiler *functionCompiler, name scanner.Token, index uint16, isLocal bool) int {
+	for j, upvalue := range compiler.upvalues {
+		if upvalue.index == index && upvalue.isLocal == isLocal {
+			return j
+		}
+	}
+
+	if len(compiler.upvalues) > math.MaxUint16 {
+		c.error(name, diagnostics.TooManyUpvalues, "Too many closure variables in function")
+		return 0
+	}
+	compiler.upvalues = append(compiler.upvalues, upvalueRef{index: index, isLocal: isLocal})
+	return len(compiler.upvalues) - 1
+}
+
+// variable reads name, or assigns it the value on top of the stack when
+// set is true.
+func (c *Compiler) variable(name scanner.Token, set bool) {
+	getOp, setOp := OpGetGlobal, OpSetGlobal
+	var operand uint16
+
+	if slot := resolveLocal(c.current, name.Lexeme); slot != -1 {
+		getOp, setOp = OpGetLocal, OpSetLocal
+		operand = uint16(slot)
+	} else if index := c.resolveUpvalue(c.current, name); index != -1 {
+		getOp, setOp = OpGetUpvalue, OpSetUpvalue
+		operand = uint16(index)
+	} else {
+		operand = c.name(name.Lexeme)
+	}
+
+	if set {
+		c.emitAt(name, setOp, operand)
+	} else {
+		c.emitAt(name, getOp, operand)
+	}
+}
+
+func (c *Compiler) statement(stmt parser.Stmt) {
+	c.position = parser.StmtToken(stmt)
+	stmt.Accept(c)
+}
+
+func (c *Compiler) expression(expr parser.Expr) {
+	expr.Accept(c)
+}
+
+// function compiles the body of a function or method and leaves its
+// closure on the stack.
+func (c *Compiler) function(stmt parser.Function, kind functionKind) {
+	c.begin(kind, stmt.Name.Lexeme)
+	c.current.function.Arity = len(stmt.Parameters)
+	c.beginScope()
+
+	for _, parameter := range stmt.Parameters {
+		c.addLocal(parameter)
+	}
+	position := c.position
+	for _, body := range stmt.Body {
+		c.statement(body)
+	}
+	c.position = position
+
+	upvalues := c.current.upvalues
+	function := c.end()
+
+	c.emitAt(stmt.Name, OpClosure, c.constant(function))
+	for _, upvalue := range upvalues {
+		isLocal := byte(0)
+		if upvalue.isLocal {
+			isLocal = 1
+		}
+		position := c.chunk().addToken(stmt.Name)
+		c.chunk().write(isLocal, position)
+		c.chunk().write(byte(upvalue.index>>8), position)
+		c.chunk().write(byte(upvalue.index), position)
+	}
+}
+
+func (c *Compiler) VisitClassStmt(stmt parser.Class) (any, error) {
+	c.emitAt(stmt.Name, OpClass, c.name(stmt.Name.Lexeme))
+	c.define(stmt.Name)
+
+	class := &classCompiler{enclosing: c.class}
+	c.class = class
+
+	var noSuperclass parser.Variable
+	if stmt.SuperClass != noSuperclass {
+		c.variable(stmt.SuperClass.Name, false)
+		c.beginScope()
+		c.addLocal(scanner.Token{TokenType: scanner.SUPER, Lexeme: "super"})
+		c.variable(stmt.Name, false)
+		c.emitAt(stmt.Name, OpInherit)
+		class.hasSuperclass = true
+	}
+
+	c.variable(stmt.Name, false)
+	for _, method := range stmt.Methods {
+		kind := kindMethod
+		if method.Name.Lexeme == "init" {
+			kind = kindInitializer
+		}
+		c.function(method, kind)
+		c.emitAt(method.Name, OpMethod, c.name(method.Name.Lexeme))
+	}
+	c.emit(OpPop)
+
+	if class.hasSuperclass {
+		c.endScope()
+	}
+	c.class = class.enclosing
+
+	return nil, nil
+}
+
+func (c *Compiler) VisitReturnStmt(stmt parser.Return) (any, error) {
+	if c.current.kind == kindInitializer {
+		// an initializer always returns the instance
+		if stmt.Value != nil {
+			c.expression(stmt.Value)
+			c.emit(OpPop)
+		}
+		c.emit(OpGetLocal, 0)
+	} else if call, ok := stmt.Value.(parser.Call); ok {
+		c.call(call, OpTailCall)
+	} else if stmt.Value != nil {
+		c.expression(stmt.Value)
+	} else {
+		c.emit(OpNil)
+	}
+	c.emitAt(stmt.Keyword, OpReturn)
+
+	return nil, nil
+}
+
+func (c *Compiler) VisitFunctionStmt(stmt parser.Function) (any, error) {
+	if c.current.depth > 0 {
+		// declared before the body is compiled, so it can call itself
+		c.addLocal(stmt.Name)
+		c.function(stmt, kindFunction)
+		return nil, nil
+	}
+
+	c.function(stmt, kindFunction)
+	c.define(stmt.Name)
+	return nil, nil
+}
+
+func (c *Compiler) VisitVarDeclaration(stmt parser.VarDeclaration) (any, error) {
+	if stmt.Initizlier != nil {
+		c.expression(stmt.Initizlier)
+	} else {
+		c.emit(OpNil)
+	}
+	c.define(stmt.Name)
+
+	return nil, nil
+}
+
+func (c *Compiler) VisitWhileStmt(stmt parser.WhileStmt) (any, error) {
+	start := len(c.chunk().Code)
+	c.expression(stmt.Condition)
+
+	exit := c.emitJump(stmt.Keyword, OpJumpIfFalse)
+	c.emit(OpPop)
+	c.statement(stmt.Body)
+	c.position = stmt.Keyword
+	c.emitLoop(stmt.Keyword, start)
+
+	c.patchJump(exit)
+	c.emit(OpPop)
+
+	return nil, nil
+}
+
+func (c *Compiler) VisitBlockStmt(stmt parser.Block) (any, error) {
+	c.beginScope()
+	for _, inner := range stmt.Statements {
+		c.statement(inner)
+	}
+	c.position = stmt.Brace
+	c.endScope()
+
+	return nil, nil
+}
+
+func (c *Compiler) VisitIfStmt(stmt parser.IfStmt) (any, error) {
+	c.expression(stmt.Condition)
+
+	thenJump := c.emitJump(stmt.Keyword, OpJumpIfFalse)
+	c.emit(OpPop)
+	c.statement(stmt.ThenBranch)
+	c.position = stmt.Keyword
+
+	elseJump := c.emitJump(stmt.Keyword, OpJump)
+	c.patchJump(thenJump)
+	c.emit(OpPop)
+	if stmt.ElseBranch != nil {
+		c.statement(stmt.ElseBranch)
+		c.position = stmt.Keyword
+	}
+	c.patchJump(elseJump)
+
+	return nil, nil
+}
+
+func (c *Compiler) VisitExpressionStmt(stmt parser.ExpressionStmt) (any, error) {
+	c.expression(stmt.Expression)
+	c.emit(OpPop)
+
+	return nil, nil
+}
+
+func (c *Compiler) VisitPrintStmt(stmt parser.PrintStmt) (any, error) {
+	c.expression(stmt.Expression)
+	c.emitAt(stmt.Keyword, OpPrint)
+
+	return nil, nil
+}
+
+func (c *Compiler) VisitListSet(expr parser.ListSet) (any, error) {
+	c.expression(expr.List)
+	c.expression(expr.Index)
+	c.expression(expr.Value)
+	c.emitAt(expr.Token, OpIndexSet)
+
+	return nil, nil
+}
+
+func (c *Compiler) VisitListGet(expr parser.ListGet) (any, error) {
+	c.expression(expr.List)
+	c.expression(expr.Index)
+	c.emitAt(expr.Token, OpIndexGet)
+
+	return nil, nil
+}
+
+func (c *Compiler) VisitListExpr(expr parser.ListExpr) (any, error) {
+	if len(expr.Literals) > math.MaxUint16 {
+		c.error(expr.LeftBracket, diagnostics.TooManyConstants, "Too many items in list literal")
+		return nil, nil
+	}
+
+	for _, literal := range expr.Literals {
+		c.expression(literal)
+	}
+	c.emitAt(expr.LeftBracket, OpList, uint16(len(expr.Literals)))
+
+	return nil, nil
+}
+
+func (c *Compiler) VisitSuperExpr(expr parser.Super) (any, error) {
+	this := expr.Keyword
+	this.TokenType, this.Lexeme = scanner.THIS, "this"
+	c.variable(this, false)
+	c.variable(expr.Keyword, false)
+	c.emitAt(expr.Method, OpGetSuper, c.name(expr.Method.Lexeme))
+
+	return nil, nil
+}
+
+func (c *Compiler) VisitThisExpr(expr parser.This) (any, error) {
+	c.variable(expr.Keyword, false)
+
+	return nil, nil
+}
+
+func (c *Compiler) VisitSetExpr(expr parser.Set) (any, error) {
+	c.expression(expr.Object)
+	c.expression(expr.Value)
+	c.emitAt(expr.Name, OpSetProperty, c.name(expr.Name.Lexeme))
+
+	return nil, nil
+}
+
+func (c *Compiler) VisitGetExpr(expr parser.Get) (any, error) {
+	c.expression(expr.Object)
+	c.emitAt(expr.Name, OpGetProperty, c.name(expr.Name.Lexeme))
+
+	return nil, nil
+}
+
+func (c *Compiler) VisitCallExpr(expr parser.Call) (any, error) {
+	c.call(expr, OpCall)
+
+	return nil, nil
+}
+
+// call compiles expr with op, OpCall or OpTailCall.
+func (c *Compiler) call(expr parser.Call, op OpCode) {
+	c.expression(expr.Callee)
+	for _, argument := range expr.Arguments {
+		c.expression(argument)
+	}
+	c.emitAt(expr.Paren, op, uint16(len(expr.Arguments)))
+}
+
+func (c *Compiler) VisitVariableExpr(expr parser.Variable) (any, error) {
+	c.variable(expr.Name, false)
+
+	return nil, nil
+}
+
+func (c *Compiler) VisitAssignExpr(expr parser.Assign) (any, error) {
+	c.expression(expr.Expr)
+	c.variable(expr.Lexem, true)
+
+	return nil, nil
+}
+
+func (c *Compiler) VisitBinaryExpr(expr parser.Binary) (any, error) {
+	c.expression(expr.Left)
+	c.expression(expr.Right)
+
+	switch expr.Operator.TokenType {
+	case scanner.MINUS:
+		c.emitAt(expr.Operator, OpSubtract)
+	case scanner.STAR:
+		c.emitAt(expr.Operator, OpMultiply)
+	case scanner.SLASH:
+		c.emitAt(expr.Operator, OpDivide)
+	case scanner.PLUS:
+		c.emitAt(expr.Operator, OpAdd)
+	case scanner.GREATER:
+		c.emitAt(expr.Operator, OpGreater)
+	case scanner.GREATER_EQUAL:
+		c.emitAt(expr.Operator, OpGreaterEqual)
+	case scanner.LESS:
+		c.emitAt(expr.Operator, OpLess)
+	case scanner.LESS_EQUAL:
+		c.emitAt(expr.Operator, OpLessEqual)
+	case scanner.EQUAL_EQUAL:
+		c.emitAt(expr.Operator, OpEqual)
+	case scanner.BANG_EQUAL:
+		c.emitAt(expr.Operator, OpEqual)
+		c.emitAt(expr.Operator, OpNot)
+	default:
+		c.error(expr.Operator, diagnostics.UnknownBinaryOperator, "Excpect binray operator to be -, +, *, /")
+	}
+
+	return nil, nil
+}
+
+func (c *Compiler) VisitGroupingExpr(expr parser.Grouping) (any, error) {
+	c.expression(expr.Expr)
+
+	return nil, nil
+}
+
+func (c *Compiler) VisitLiteralExpr(expr parser.Literal) (any, error) {
+	switch value := expr.Value.(type) {
+	case nil:
+		c.emit(OpNil)
+	case bool:
+		if value {
+			c.emit(OpTrue)
+		} else {
+			c.emit(OpFalse)
+		}
+	default:
+		c.emit(OpConstant, c.constant(value))
+	}
+
+	return nil, nil
+}
+
+func (c *Compiler) VisitLogicalExpr(expr parser.Logical) (any, error) {
+	c.expression(expr.Left)
+
+	if expr.Operator.TokenType == scanner.OR {
+		// a truthy left side is the result, skip the right one
+		elseJump := c.emitJump(expr.Operator, OpJumpIfFalse)
+		endJump := c.emitJump(expr.Operator, OpJump)
+		c.patchJump(elseJump)
+		c.emit(OpPop)
+		c.expression(expr.Right)
+		c.patchJump(endJump)
+		return nil, nil
+	}
+
+	endJump := c.emitJump(expr.Operator, OpJumpIfFalse)
+	c.emit(OpPop)
+	c.expression(expr.Right)
+	c.patchJump(endJump)
+
+	return nil, nil
+}
+
+func (c *Compiler) VisitUnaryExpr(expr parser.Unary) (any, error) {
+	c.expression(expr.Right)
+
+	switch expr.Operator.TokenType {
+	case scanner.MINUS:
+		c.emitAt(expr.Operator, OpNegate)
+	case scanner.BANG:
+		c.emitAt(expr.Operator, OpNot)
+	default:
+		c.error(expr.Operator, diagnostics.UnknownUnaryOperator, "Expect unary operator to be -, !")
+	}
+
+	return nil, nil
+}
@@ -0,0 +1,73 @@
+package vm
+
+import (
+	"github.com/neet-007/glox/pkg/diagnostics"
+	"github.com/neet-007/glox/pkg/interpreter"
+	"github.com/neet-007/glox/pkg/runtime"
+	"github.com/neet-007/glox/pkg/scanner"
+)
+
+func limitError(token scanner.Token, limit string, max int) *runtime.RuntimeError {
+	err := &interpreter.LimitError{
+		Limit: limit,
+		Max:   max,
+	}
+	return runtime.WrapRuntimeError(token, diagnostics.LimitExceeded, err.Error(), err)
+}
+
+func (vm *VM) checkContext(token scanner.Token) *runtime.RuntimeError {
+	if !vm.cancelled.Load() {
+		return nil
+	}
+
+	err := vm.ctx.Err()
+	return runtime.WrapRuntimeError(token, diagnostics.ExecutionCancelled, "Execution cancelled: "+err.Error(), err)
+}
+
+func (vm *VM) step(token scanner.Token) *runtime.RuntimeError {
+	vm.steps++
+	if vm.steps > vm.Limits.MaxSteps {
+		return limitError(token, "max steps", vm.Limits.MaxSteps)
+	}
+
+	return nil
+}
+
+func (vm *VM) allocate(token scanner.Token) *runtime.RuntimeError {
+	vm.allocations++
+	if vm.Limits.MaxAllocations > 0 && vm.allocations > vm.Limits.MaxAllocations {
+		return limitError(token, "max allocations", vm.Limits.MaxAllocations)
+	}
+
+	return nil
+}
+
+func (vm *VM) checkListLength(token scanner.Token, length int) *runtime.RuntimeError {
+	if vm.Limits.MaxListLength > 0 && length > vm.Limits.MaxListLength {
+		return limitError(token, "max list length", vm.Limits.MaxListLength)
+	}
+
+	return nil
+}
+
+func (vm *VM) checkStringLength(token scanner.Token, length int) *runtime.RuntimeError {
+	if vm.Limits.MaxStringLength > 0 && length > vm.Limits.MaxStringLength {
+		return limitError(token, "max string length", vm.Limits.MaxStringLength)
+	}
+
+	return nil
+}
+
+// checkCallDepth is called before a call, the script's own frame does not
+// count.
+func (vm *VM) checkCallDepth(token scanner.Token) *runtime.RuntimeError {
+	depth := len(vm.frames) - 1
+	if vm.MaxStackDepth > 0 && depth >= vm.MaxStackDepth {
+		return runtime.NewRuntimeError(token, diagnostics.StackOverflow, "Stack overflow.")
+	}
+	if vm.Limits.MaxCallDepth > 0 && depth >= vm.Limits.MaxCallDepth {
+		return limitError(token, "max call depth", vm.Limits.MaxCallDepth)
+	}
+
+	return nil
+}
@@ -0,0 +1,22 @@
+package vm
+
+import (
+	"time"
+
+	"github.com/neet-007/glox/pkg/diagnostics"
+	"github.com/neet-007/glox/pkg/runtime"
+	"github.com/neet-007/glox/pkg/scanner"
+)
+
+func clockNative(vm *VM, arguments []any) (any, *runtime.RuntimeError) {
+	return float64(time.Now().UnixNano()) / 1e9, nil
+}
+
+func lenNative(vm *VM, arguments []any) (any, *runtime.RuntimeError) {
+	list, ok := arguments[0].(*List)
+	if !ok {
+		return nil, runtime.NewRuntimeError(scanner.Token{TokenType: scanner.Error}, diagnostics.LenNotIterable, "len must be passed 1 argument that is iterable")
+	}
+
+	return float64(len(list.Items)), nil
+}
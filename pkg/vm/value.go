@@ -0,0 +1,125 @@
+package vm
+
+import (
+	"fmt"
+
+	"github.com/neet-007/glox/pkg/runtime"
+	"github.com/neet-007/glox/pkg/scanner"
+)
+
+/*
+ NOTE:
+	values are the same go values the tree-walking interpreter uses for
+	nil, booleans, numbers and strings. everything else is a pointer and
+	prints the way its interpreter counterpart does. equality is the
+	interpreter's too: lists are equal when their items are, everything
+	else only to itself.
+*/
+
+// Function is a compiled function, the script itself included.
+type Function struct {
+	Name         string
+	Arity        int
+	UpvalueCount int
+	Chunk        Chunk
+}
+
+func (f *Function) String() string {
+	if f.Name == "" {
+		return "<script>"
+	}
+	return fmt.Sprintf("<fn %s>", f.Name)
+}
+
+// Closure is a function with the variables it captured.
+type Closure struct {
+	Function *Function
+	upvalues []*upvalue
+}
+
+func (c *Closure) String() string {
+	return c.Function.String()
+}
+
+// upvalue is a captured variable. While open it is still on the stack at
+// slot, once the variable goes out of scope it is closed over and lives
+// in value.
+type upvalue struct {
+	slot   int
+	value  any
+	closed bool
+	// next is the next open upvalue down the stack.
+	next *upvalue
+}
+
+type Class struct {
+	Name       string
+	Methods    map[string]*Closure
+	Superclass *Class
+}
+
+func (c *Class) String() string {
+	return c.Name
+}
+
+type Instance struct {
+	Class  *Class
+	Fields map[string]any
+}
+
+func (i *Instance) String() string {
+	return i.Class.Name + " instance"
+}
+
+// BoundMethod is a method read off an instance, this bound to receiver.
+type BoundMethod struct {
+	Receiver *Instance
+	Method   *Closure
+}
+
+func (b *BoundMethod) String() string {
+	return b.Method.String()
+}
+
+type List struct {
+	Items []any
+	// token is the list's opening bracket, where index errors point.
+	token scanner.Token
+}
+
+func (l *List) String() string {
+	return "<list>"
+}
+
+// equal reports whether a and b are equal the way interpreter.Equal does.
+func equal(a any, b any) bool {
+	left, ok := a.(*List)
+	if !ok {
+		return a == b
+	}
+	right, ok := b.(*List)
+	if !ok || len(left.Items) != len(right.Items) {
+		return false
+	}
+	if left == right {
+		return true
+	}
+	for n := range left.Items {
+		if !equal(left.Items[n], right.Items[n]) {
+			return false
+		}
+	}
+	return true
+}
+
+// Native is a function written in go. A zero token in the error it
+// returns is replaced by the call's.
+type Native struct {
+	Name  string
+	Arity int
+	Fn    func(vm *VM, arguments []any) (any, *runtime.RuntimeError)
+}
+
+func (n *Native) String() string {
+	return "<fn native>"
+}
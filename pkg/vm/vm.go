@@ -0,0 +1,610 @@
+package vm
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"sync/atomic"
+
+	"github.com/neet-007/glox/pkg/diagnostics"
+	"github.com/neet-007/glox/pkg/interpreter"
+	"github.com/neet-007/glox/pkg/runtime"
+	"github.com/neet-007/glox/pkg/scanner"
+)
+
+// callFrame is a call in progress. Its locals start at base on the stack,
+// where the callee itself is.
+type callFrame struct {
+	closure *Closure
+	ip      int
+	base    int
+	// name is what the stack trace calls the frame, the class for the
+	// initializer run by calling it.
+	name string
+}
+
+// VM runs compiled scripts. It reports errors the way the tree-walking
+// interpreter does, with the same messages, codes and stack traces.
+type VM struct {
+	stack        []any
+	frames       []callFrame
+	globals      map[string]any
+	openUpvalues *upvalue
+	ctx          context.Context
+	cancelled    atomic.Bool
+	allocations  int
+	steps        int
+	out          *bufio.Writer
+	// Limits bounds the script as it does the interpreter's. MaxSteps
+	// counts the instructions run rather than statements, so the same
+	// script takes more steps than it does on the interpreter.
+	Limits interpreter.Limits
+	// MaxStackDepth is the call depth that raises "Stack overflow.", zero
+	// turns the check off.
+	MaxStackDepth int
+	// Stdout is where print writes, os.Stdout by default.
+	Stdout io.Writer
+	// TailCalls makes "return f(...)" call f in place of the function
+	// returning, as the interpreter's TailCalls does, so both keep the
+	// same frames.
+	TailCalls bool
+}
+
+func New() *VM {
+	vm := &VM{
+		globals:       map[string]any{},
+		MaxStackDepth: interpreter.DefaultMaxStackDepth,
+		Stdout:        os.Stdout,
+		TailCalls:     true,
+	}
+	vm.Define(&Native{Name: "clock", Arity: 0, Fn: clockNative})
+	vm.Define(&Native{Name: "len", Arity: 1, Fn: lenNative})
+	return vm
+}
+
+// Define binds a native function to a global variable.
+func (vm *VM) Define(native *Native) {
+	vm.globals[native.Name] = native
+}
+
+// Run runs script, a function made by Compile, until it finishes or ctx is
+// done. Globals defined by earlier runs stay defined.
+func (vm *VM) Run(ctx context.Context, script *Function) *runtime.RuntimeError {
+	vm.ctx = ctx
+	vm.cancelled.Store(false)
+	stop := context.AfterFunc(ctx, func() {
+		vm.cancelled.Store(true)
+	})
+	defer stop()
+
+	vm.out = bufio.NewWriter(vm.Stdout)
+	defer vm.out.Flush()
+
+	vm.stack = vm.stack[:0]
+	vm.frames = vm.frames[:0]
+	vm.openUpvalues = nil
+	vm.allocations = 0
+	vm.steps = 0
+
+	closure := &Closure{Function: script}
+	vm.stack = append(vm.stack, closure)
+	vm.frames = append(vm.frames, callFrame{closure: closure})
+
+	return vm.run()
+}
+
+func (vm *VM) run() *runtime.RuntimeError {
+	frame := &vm.frames[len(vm.frames)-1]
+	chunk := &frame.closure.Function.Chunk
+	code := chunk.Code
+
+	readShort := func() int {
+		frame.ip += 2
+		return int(code[frame.ip-2])<<8 | int(code[frame.ip-1])
+	}
+	// fail raises an error at the instruction starting at start.
+	var start int
+	fail := func(code diagnostics.Code, message string) *runtime.RuntimeError {
+		return vm.fail(runtime.NewRuntimeError(chunk.Token(start), code, message))
+	}
+	// reload picks up the frame on top after a call or return.
+	reload := func() {
+		frame = &vm.frames[len(vm.frames)-1]
+		chunk = &frame.closure.Function.Chunk
+		code = chunk.Code
+	}
+
+	for {
+		start = frame.ip
+		if vm.Limits.MaxSteps > 0 {
+			if err := vm.step(chunk.Token(start)); err != nil {
+				return vm.fail(err)
+			}
+		}
+		op := OpCode(code[frame.ip])
+		frame.ip++
+
+		switch op {
+		case OpConstant:
+			vm.push(chunk.Constants[readShort()])
+		case OpNil:
+			vm.push(nil)
+		case OpTrue:
+			vm.push(true)
+		case OpFalse:
+			vm.push(false)
+		case OpPop:
+			vm.pop()
+		case OpGetLocal:
+			vm.push(vm.stack[frame.base+readShort()])
+		case OpSetLocal:
+			vm.stack[frame.base+readShort()] = vm.peek(0)
+		case OpGetGlobal:
+			name := chunk.Constants[readShort()].(string)
+			value, ok := vm.globals[name]
+			if !ok {
+				return fail(diagnostics.UndefinedVariable, "undefiend variable "+name)
+			}
+			vm.push(value)
+		case OpDefineGlobal:
+			vm.globals[chunk.Constants[readShort()].(string)] = vm.pop()
+		case OpSetGlobal:
+			name := chunk.Constants[readShort()].(string)
+			if _, ok := vm.globals[name]; !ok {
+				return fail(diagnostics.UndefinedVariable, "undefiend variable "+name)
+			}
+			vm.globals[name] = vm.peek(0)
+		case OpGetUpvalue:
+			upvalue := frame.closure.upvalues[readShort()]
+			if upvalue.closed {
+				vm.push(upvalue.value)
+			} else {
+				vm.push(vm.stack[upvalue.slot])
+			}
+		case OpSetUpvalue:
+			upvalue := frame.closure.upvalues[readShort()]
+			if upvalue.closed {
+				upvalue.value = vm.peek(0)
+			} else {
+				vm.stack[upvalue.slot] = vm.peek(0)
+			}
+		case OpGetProperty:
+			name := chunk.Constants[readShort()].(string)
+			instance, ok := vm.peek(0).(*Instance)
+			if !ok {
+				return fail(diagnostics.GetOnNonInstance, "Only instances have properties")
+			}
+			if value, ok := instance.Fields[name]; ok {
+				vm.stack[len(vm.stack)-1] = value
+				break
+			}
+			method, ok := instance.Class.Methods[name]
+			if !ok {
+				return fail(diagnostics.UndefinedProperty, "Undefined property '"+name)
+			}
+			vm.stack[len(vm.stack)-1] = &BoundMethod{Receiver: instance, Method: method}
+		case OpSetProperty:
+			name := chunk.Constants[readShort()].(string)
+			instance, ok := vm.peek(1).(*Instance)
+			if !ok {
+				return fail(diagnostics.SetOnNonInstance, "Only instances have properties")
+			}
+			value := vm.pop()
+			instance.Fields[name] = value
+			vm.stack[len(vm.stack)-1] = value
+		case OpGetSuper:
+			name := chunk.Constants[readShort()].(string)
+			superclass := vm.pop().(*Class)
+			method, ok := superclass.Methods[name]
+			if !ok {
+				return fail(diagnostics.SuperMethodNotFound, "method not found")
+			}
+			vm.stack[len(vm.stack)-1] = &BoundMethod{Receiver: vm.peek(0).(*Instance), Method: method}
+		case OpEqual:
+			right := vm.pop()
+			vm.stack[len(vm.stack)-1] = equal(vm.peek(0), right)
+		case OpGreater, OpGreaterEqual, OpLess, OpLessEqual, OpSubtract, OpMultiply, OpDivide:
+			left, lok := vm.peek(1).(float64)
+			right, rok := vm.peek(0).(float64)
+			if !lok || !rok {
+				return fail(diagnostics.OperandsNotNumbers, "Expect operands to be numbers")
+			}
+			vm.pop()
+			vm.stack[len(vm.stack)-1] = arithmetic(op, left, right)
+		case OpAdd:
+			switch left := vm.peek(1).(type) {
+			case float64:
+				if right, ok := vm.peek(0).(float64); ok {
+					vm.pop()
+					vm.stack[len(vm.stack)-1] = left + right
+					continue
+				}
+			case string:
+				if right, ok := vm.peek(0).(string); ok {
+					if err := vm.checkStringLength(chunk.Token(start), len(left)+len(right)); err != nil {
+						return vm.fail(err)
+					}
+					if err := vm.allocate(chunk.Token(start)); err != nil {
+						return vm.fail(err)
+					}
+					vm.pop()
+					vm.stack[len(vm.stack)-1] = left + right
+					continue
+				}
+			}
+			return fail(diagnostics.OperandsNotAddable, "Expect binary operands to be strings")
+		case OpNot:
+			vm.stack[len(vm.stack)-1] = !interpreter.Truthy(vm.peek(0))
+		case OpNegate:
+			value, ok := vm.peek(0).(float64)
+			if !ok {
+				return fail(diagnostics.OperandNotNumber, "Expect operands to be numbers")
+			}
+			vm.stack[len(vm.stack)-1] = -value
+		case OpPrint:
+			fmt.Fprintln(vm.out, interpreter.Stringify(vm.pop()))
+		case OpJump:
+			offset := readShort()
+			frame.ip += offset
+		case OpJumpIfFalse:
+			offset := readShort()
+			if !interpreter.Truthy(vm.peek(0)) {
+				frame.ip += offset
+			}
+		case OpLoop:
+			offset := readShort()
+			if err := vm.checkContext(chunk.Token(start)); err != nil {
+				return vm.fail(err)
+			}
+			frame.ip -= offset
+		case OpCall:
+			argumentCount := readShort()
+			if err := vm.checkContext(chunk.Token(start)); err != nil {
+				return vm.fail(err)
+			}
+			if err := vm.call(vm.peek(argumentCount), argumentCount, chunk.Token(start)); err != nil {
+				return vm.fail(err)
+			}
+			reload()
+		case OpTailCall:
+			argumentCount := readShort()
+			if err := vm.checkContext(chunk.Token(start)); err != nil {
+				return vm.fail(err)
+			}
+			if vm.TailCalls {
+				if err := vm.tailCall(vm.peek(argumentCount), argumentCount, chunk.Token(start)); err != nil {
+					return vm.fail(err)
+				}
+			} else if err := vm.call(vm.peek(argumentCount), argumentCount, chunk.Token(start)); err != nil {
+				return vm.fail(err)
+			}
+			reload()
+		case OpClosure:
+			function := chunk.Constants[readShort()].(*Function)
+			if err := vm.allocate(chunk.Token(start)); err != nil {
+				return vm.fail(err)
+			}
+			closure := &Closure{Function: function, upvalues: make([]*upvalue, function.UpvalueCount)}
+			for j := range closure.upvalues {
+				isLocal := code[frame.ip] == 1
+				frame.ip++
+				index := readShort()
+				if isLocal {
+					closure.upvalues[j] = vm.captureUpvalue(frame.base + index)
+				} else {
+					closure.upvalues[j] = frame.closure.upvalues[index]
+				}
+			}
+			vm.push(closure)
+		case OpCloseUpvalue:
+			vm.closeUpvalues(len(vm.stack) - 1)
+			vm.pop()
+		case OpReturn:
+			result := vm.pop()
+			vm.closeUpvalues(frame.base)
+			vm.stack = vm.stack[:frame.base]
+			vm.frames = vm.frames[:len(vm.frames)-1]
+			if len(vm.frames) == 0 {
+				return nil
+			}
+			vm.push(result)
+			reload()
+		case OpClass:
+			if err := vm.allocate(chunk.Token(start)); err != nil {
+				return vm.fail(err)
+			}
+			vm.push(&Class{Name: chunk.Constants[readShort()].(string), Methods: map[string]*Closure{}})
+		case OpInherit:
+			superclass, ok := vm.peek(1).(*Class)
+			if !ok {
+				return fail(diagnostics.SuperclassNotClass, "Superclass must be a class")
+			}
+			subclass := vm.pop().(*Class)
+			subclass.Superclass = superclass
+			// classes never change once declared, so the inherited methods
+			// can be copied down rather than looked up through superclasses
+			for name, method := range superclass.Methods {
+				subclass.Methods[name] = method
+			}
+		case OpMethod:
+			name := chunk.Constants[readShort()].(string)
+			method := vm.pop().(*Closure)
+			vm.peek(0).(*Class).Methods[name] = method
+		case OpList:
+			count := readShort()
+			if err := vm.checkListLength(chunk.Token(start), count); err != nil {
+				return vm.fail(err)
+			}
+			if err := vm.allocate(chunk.Token(start)); err != nil {
+				return vm.fail(err)
+			}
+			items := make([]any, count)
+			copy(items, vm.stack[len(vm.stack)-count:])
+			vm.stack = vm.stack[:len(vm.stack)-count]
+			vm.push(&List{Items: items, token: chunk.Token(start)})
+		case OpIndexGet:
+			list, ok := vm.peek(1).(*List)
+			if !ok {
+				return fail(diagnostics.IndexOnNonList, "only lists support index")
+			}
+			index, err := vm.index(chunk.Token(start), list, vm.peek(0))
+			if err != nil {
+				return vm.fail(err)
+			}
+			vm.pop()
+			vm.stack[len(vm.stack)-1] = list.Items[index]
+		case OpIndexSet:
+			list, ok := vm.peek(2).(*List)
+			if !ok {
+				return fail(diagnostics.AssignIndexOnNonList, "only lists support index")
+			}
+			index, err := vm.index(chunk.Token(start), list, vm.peek(1))
+			if err != nil {
+				return vm.fail(err)
+			}
+			list.Items[index] = vm.pop()
+			vm.pop()
+			vm.stack[len(vm.stack)-1] = nil
+		default:
+			panic(fmt.Sprintf("unknown opcode %d", op))
+		}
+	}
+}
+
+func arithmetic(op OpCode, left float64, right float64) any {
+	switch op {
+	case OpGreater:
+		return left > right
+	case OpGreaterEqual:
+		return left >= right
+	case OpLess:
+		return left < right
+	case OpLessEqual:
+		return left <= right
+	case OpSubtract:
+		return left - right
+	case OpMultiply:
+		return left * right
+	default:
+		return left / right
+	}
+}
+
+func (vm *VM) push(value any) {
+	vm.stack = append(vm.stack, value)
+}
+
+func (vm *VM) pop() any {
+	value := vm.stack[len(vm.stack)-1]
+	vm.stack = vm.stack[:len(vm.stack)-1]
+	return value
+}
+
+func (vm *VM) peek(distance int) any {
+	return vm.stack[len(vm.stack)-1-distance]
+}
+
+// index checks value is a valid index into list.
+func (vm *VM) index(token scanner.Token, list *List, value any) (int, *runtime.RuntimeError) {
+	f, ok := value.(float64)
+	if !ok || f != float64(int(f)) {
+		return 0, runtime.NewRuntimeError(token, diagnostics.IndexNotInteger, "value is not an integer")
+	}
+
+	index := int(f)
+	if index < 0 || index >= len(list.Items) {
+		return 0, runtime.NewRuntimeError(list.token, diagnostics.IndexOutOfBounds, fmt.Sprintf("index out of bound index %d length %d", index, len(list.Items)))
+	}
+	return index, nil
+}
+
+// call calls callee, which is on the stack below its arguments.
+func (vm *VM) call(callee any, argumentCount int, callSite scanner.Token) *runtime.RuntimeError {
+	switch callee := callee.(type) {
+	case *Closure:
+		return vm.callClosure(callee, argumentCount, callSite, callee.Function.Name)
+	case *BoundMethod:
+		vm.stack[len(vm.stack)-1-argumentCount] = callee.Receiver
+		return vm.callClosure(callee.Method, argumentCount, callSite, callee.Method.Function.Name)
+	case *Class:
+		initializer, ok := callee.Methods["init"]
+		if !ok && argumentCount != 0 {
+			return arityError(callSite, 0, argumentCount)
+		}
+		if ok && argumentCount != initializer.Function.Arity {
+			return arityError(callSite, initializer.Function.Arity, argumentCount)
+		}
+		if err := vm.checkCallDepth(callSite); err != nil {
+			return err
+		}
+		if err := vm.allocate(callSite); err != nil {
+			return vm.nativeError(err, callee.Name, callSite)
+		}
+
+		instance := &Instance{Class: callee, Fields: map[string]any{}}
+		vm.stack[len(vm.stack)-1-argumentCount] = instance
+		if ok {
+			return vm.callClosure(initializer, argumentCount, callSite, callee.Name)
+		}
+		return nil
+	case *Native:
+		if argumentCount != callee.Arity {
+			return arityError(callSite, callee.Arity, argumentCount)
+		}
+		if err := vm.checkCallDepth(callSite); err != nil {
+			return err
+		}
+
+		arguments := vm.stack[len(vm.stack)-argumentCount:]
+		result, err := callee.Fn(vm, arguments)
+		if err != nil {
+			return vm.nativeError(err, callee.Name, callSite)
+		}
+		vm.stack = vm.stack[:len(vm.stack)-argumentCount-1]
+		vm.push(result)
+		return nil
+	default:
+		return runtime.NewRuntimeError(callSite, diagnostics.NotCallable, "not callable")
+	}
+}
+
+// tailCall calls callee in place of the function on top, which returns
+// what callee does. Errors are raised as the interpreter raises them: a
+// callee that cannot be called this way fails in the function making the
+// call, and everything after that has the callee in its place.
+func (vm *VM) tailCall(callee any, argumentCount int, callSite scanner.Token) *runtime.RuntimeError {
+	arity, ok := arity(callee)
+	if !ok {
+		return runtime.NewRuntimeError(callSite, diagnostics.NotCallable, "not callable")
+	}
+	if arity != argumentCount {
+		return arityError(callSite, arity, argumentCount)
+	}
+
+	// the callee and its arguments move down to where the function
+	// returning was
+	frame := vm.frames[len(vm.frames)-1]
+	vm.closeUpvalues(frame.base)
+	n := copy(vm.stack[frame.base:], vm.stack[len(vm.stack)-argumentCount-1:])
+	vm.stack = vm.stack[:frame.base+n]
+	vm.frames = vm.frames[:len(vm.frames)-1]
+
+	if err := vm.call(callee, argumentCount, callSite); err != nil {
+		// a native failing blames the caller's own call, the frame that
+		// made the tail call being gone
+		if len(err.Trace) > 1 {
+			caller := vm.frames[len(vm.frames)-1]
+			err.Trace[1].Line = caller.closure.Function.Chunk.Token(caller.ip - 1).Line
+		}
+		return err
+	}
+	// a callee that returned at once, a native or a class without an
+	// initializer, left its result where the function returning would have
+	return nil
+}
+
+// arity is the number of arguments callee takes, false if it cannot be
+// called.
+func arity(callee any) (int, bool) {
+	switch callee := callee.(type) {
+	case *Closure:
+		return callee.Function.Arity, true
+	case *BoundMethod:
+		return callee.Method.Function.Arity, true
+	case *Class:
+		if initializer, ok := callee.Methods["init"]; ok {
+			return initializer.Function.Arity, true
+		}
+		return 0, true
+	case *Native:
+		return callee.Arity, true
+	default:
+		return 0, false
+	}
+}
+
+func (vm *VM) callClosure(closure *Closure, argumentCount int, callSite scanner.Token, name string) *runtime.RuntimeError {
+	if argumentCount != closure.Function.Arity {
+		return arityError(callSite, closure.Function.Arity, argumentCount)
+	}
+	if err := vm.checkCallDepth(callSite); err != nil {
+		return err
+	}
+
+	vm.frames = append(vm.frames, callFrame{
+		closure: closure,
+		base:    len(vm.stack) - argumentCount - 1,
+		name:    name,
+	})
+	return nil
+}
+
+func arityError(callSite scanner.Token, arity int, argumentCount int) *runtime.RuntimeError {
+	return runtime.NewRuntimeError(callSite, diagnostics.ArityMismatch, fmt.Sprintf("expect %d parameters got %d arguments", arity, argumentCount))
+}
+
+// captureUpvalue returns the open upvalue for the stack slot, making one
+// if no closure has captured it yet.
+func (vm *VM) captureUpvalue(slot int) *upvalue {
+	var previous *upvalue
+	current := vm.openUpvalues
+	for current != nil && current.slot > slot {
+		previous, current = current, current.next
+	}
+	if current != nil && current.slot == slot {
+		return current
+	}
+
+	created := &upvalue{slot: slot, next: current}
+	if previous == nil {
+		vm.openUpvalues = created
+	} else {
+		previous.next = created
+	}
+	return created
+}
+
+// closeUpvalues closes the upvalues of every slot from last up.
+func (vm *VM) closeUpvalues(last int) {
+	for vm.openUpvalues != nil && vm.openUpvalues.slot >= last {
+		upvalue := vm.openUpvalues
+		upvalue.value = vm.stack[upvalue.slot]
+		upvalue.closed = true
+		vm.openUpvalues = upvalue.next
+	}
+}
+
+// fail adds the stack trace to err, innermost call first as the
+// interpreter's are.
+func (vm *VM) fail(err *runtime.RuntimeError) *runtime.RuntimeError {
+	if err.Trace != nil {
+		return err
+	}
+
+	line := err.Token.Line
+	for j := len(vm.frames) - 1; j >= 0; j-- {
+		frame := vm.frames[j]
+		if j < len(vm.frames)-1 {
+			// callers are stopped just past their call
+			line = frame.closure.Function.Chunk.Token(frame.ip - 1).Line
+		}
+		err.Trace = append(err.Trace, runtime.StackFrame{Function: frame.name, Line: line})
+	}
+	return err
+}
+
+// nativeError points an error from a native, or from making an instance,
+// at the call, with a frame for the callee on the trace as the
+// interpreter has.
+func (vm *VM) nativeError(err *runtime.RuntimeError, name string, callSite scanner.Token) *runtime.RuntimeError {
+	if err.Token.Line == 0 {
+		err.Token = callSite
+	}
+	trace := []runtime.StackFrame{{Function: name, Line: err.Token.Line}}
+	err = vm.fail(err)
+	err.Trace = append(trace, err.Trace...)
+	err.Trace[1].Line = callSite.Line
+	return err
+}
@@ -0,0 +1,95 @@
+package vm
+
+import (
+	"context"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/neet-007/glox/pkg/diagnostics"
+	"github.com/neet-007/glox/pkg/parser"
+	"github.com/neet-007/glox/pkg/scanner"
+)
+
+func compile(t *testing.T, source string) *Function {
+	t.Helper()
+	tokens, errs := scanner.NewFileScanner("test.lox", []byte(source), false).Scan()
+	if len(errs) > 0 {
+		t.Fatal(errs[0])
+	}
+	stmts, errs := parser.NewParser(tokens, false).Parse()
+	if len(errs) > 0 {
+		t.Fatal(errs[0])
+	}
+	script, diags := Compile(stmts)
+	if len(diags) > 0 {
+		t.Fatal(diags[0])
+	}
+	return script
+}
+
+func TestMaxSteps(t *testing.T) {
+	vm := New()
+	vm.Limits.MaxSteps = 1000
+	err := vm.Run(context.Background(), compile(t, "var i = 0;\nwhile (true) {\n  i = i + 1;\n}\n"))
+	if err == nil || err.Code != diagnostics.LimitExceeded {
+		t.Fatalf("got %v, want a limit error", err)
+	}
+	if !strings.Contains(err.Error(), "max steps") || err.Token.Line < 2 {
+		t.Errorf("got %v at line %d, want max steps inside the loop", err, err.Token.Line)
+	}
+
+	// the count starts over with each run
+	var out strings.Builder
+	vm.Stdout = &out
+	if err := vm.Run(context.Background(), compile(t, "print 1 + 2;")); err != nil {
+		t.Fatalf("got %v, want the script to run", err)
+	}
+	if out.String() != "3\n" {
+		t.Errorf("got %q, want 3", out.String())
+	}
+}
+
+func TestEqual(t *testing.T) {
+	var out strings.Builder
+	vm := New()
+	vm.Stdout = &out
+	source := `
+class A {}
+var a = A();
+var l = [1, [2, "x"]];
+print l == l;
+print l == [1, [2, "x"]];
+print l != [1, [2, "y"]];
+print l == [1];
+print [a] == [a];
+print a == A();
+print [] == nil;
+`
+	if err := vm.Run(context.Background(), compile(t, source)); err != nil {
+		t.Fatal(err)
+	}
+	if want := "true\ntrue\ntrue\nfalse\ntrue\nfalse\nfalse\n"; out.String() != want {
+		t.Errorf("got\n%s\nwant\n%s", out.String(), want)
+	}
+}
+
+func TestTailCalls(t *testing.T) {
+	source := "fun count(n) {\n  if (n == 0) return 0;\n  return count(n - 1);\n}\nprint count(100);\n"
+
+	vm := New()
+	vm.MaxStackDepth = 10
+	vm.Stdout = io.Discard
+	if err := vm.Run(context.Background(), compile(t, source)); err != nil {
+		t.Fatalf("got %v, want the tail calls to take no frames", err)
+	}
+
+	vm.TailCalls = false
+	err := vm.Run(context.Background(), compile(t, source))
+	if err == nil || err.Code != diagnostics.StackOverflow {
+		t.Fatalf("got %v with tail calls off, want a stack overflow", err)
+	}
+	if len(err.Trace) != vm.MaxStackDepth+1 {
+		t.Errorf("got a trace of %d frames, want every call", len(err.Trace))
+	}
+}